@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var agentsCmd = &cobra.Command{
+	Use:   "agents",
+	Short: "Manage the mappings of Zendesk agents to PSA members",
+}
+
+var agentsMapCmd = &cobra.Command{
+	Use:   "map",
+	Short: "Match Zendesk agents to PSA members by email and save the mappings in the config",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		return migration.MapAgents(opts)
+	},
+}
+
+func init() {
+	agentsCmd.AddCommand(agentsMapCmd)
+	rootCmd.AddCommand(agentsCmd)
+}
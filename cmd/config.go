@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the config file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config and API connections without changing anything",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		return migration.ValidateConfig(opts)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Snapshot the Zendesk orgs, users and tickets for the tags in the config to a local archive",
+	Long: "Snapshot the Zendesk orgs, users and tickets for the tags in the config to a local archive of NDJSON " +
+		"files and attachments. Run the migration from the archive with --archive instead of the Zendesk API.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		dir, err := cmd.Flags().GetString("dir")
+		if err != nil {
+			return fmt.Errorf("getting dir flag: %w", err)
+		}
+
+		return migration.Export(opts, migration.ExportOptions{Dir: dir})
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("dir", "", "directory to write the archive to (default is a new folder in the profile's archives folder)")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var fieldsCmd = &cobra.Command{
+	Use:   "fields",
+	Short: "Manage the Zendesk custom fields that link orgs and users to the PSA",
+}
+
+var fieldsSetupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Find or create the Zendesk custom fields and save their IDs in the config",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		return migration.SetupFields(opts)
+	},
+}
+
+func init() {
+	fieldsCmd.AddCommand(fieldsSetupCmd)
+	rootCmd.AddCommand(fieldsCmd)
+}
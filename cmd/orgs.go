@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var orgsCmd = &cobra.Command{
+	Use:   "orgs",
+	Short: "Run the org phase of the migration on its own",
+}
+
+var orgsMatchCmd = &cobra.Command{
+	Use:   "match",
+	Short: "Match tagged Zendesk orgs to PSA companies by name and link them in Zendesk",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		return migration.MatchOrgs(opts)
+	},
+}
+
+func init() {
+	orgsCmd.AddCommand(orgsMatchCmd)
+	rootCmd.AddCommand(orgsCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manage profiles, which each have their own config, logs, reports and archives",
+}
+
+var profilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every profile with its directory and latest run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := migration.ListProfiles()
+		if err != nil {
+			return fmt.Errorf("listing profiles: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tCONFIG\tRUNS\tLAST RUN\tDIRECTORY")
+		for _, p := range profiles {
+			config := "missing"
+			if p.HasConfig {
+				config = "yes"
+			}
+
+			lastRun := "never"
+			if !p.LastRun.IsZero() {
+				lastRun = p.LastRun.Format("2006-01-02 15:04")
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", p.Name, config, p.Runs, lastRun, p.Dir)
+		}
+
+		return w.Flush()
+	},
+}
+
+func init() {
+	profilesCmd.AddCommand(profilesListCmd)
+	rootCmd.AddCommand(profilesCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var retryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Retry only the orgs, users and tickets that failed in a previous run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		run, err := cmd.Flags().GetString("run")
+		if err != nil {
+			return fmt.Errorf("getting run flag: %w", err)
+		}
+
+		errorClasses, err := cmd.Flags().GetStringSlice("errors")
+		if err != nil {
+			return fmt.Errorf("getting errors flag: %w", err)
+		}
+
+		return migration.Retry(opts, migration.RetryOptions{
+			Run:          run,
+			ErrorClasses: errorClasses,
+		})
+	},
+}
+
+func init() {
+	retryCmd.Flags().String("run", "", "run folder to retry, e.g. 2024-05-01_09-30-00 (default is the latest run with failures)")
+	retryCmd.Flags().StringSlice("errors", nil, "only retry these error classes: rate_limit, server, client, network, cancelled, other (default is all)")
+	rootCmd.AddCommand(retryCmd)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
 	"github.com/spf13/cobra"
@@ -20,14 +21,23 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// exitNotReconciled is the exit code when verify finds tickets that don't match
+const exitNotReconciled = 2
+
 func Execute() {
 	err := rootCmd.Execute()
+	if errors.Is(err, migration.ErrNotReconciled) {
+		os.Exit(exitNotReconciled)
+	}
+
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&migration.CfgFile, "config", "", "config file to use (default is config.json in the profile's directory)")
+	rootCmd.PersistentFlags().String("profile", "", "profile to use, with its own config, logs, reports and archives under ~/ticket-migration/profiles (default is ~/ticket-migration)")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "enable debug logging")
 	rootCmd.PersistentFlags().IntP("ticketLimit", "t", 0, "limit the number of tickets to migrate")
 	rootCmd.PersistentFlags().BoolP("migrateOpen", "o", false, "migrate open and closed tickets (default is closed only)")
@@ -38,6 +48,19 @@ func init() {
 	rootCmd.PersistentFlags().Bool("stopAfterOrgs", false, "stop migration after getting orgs")
 	rootCmd.PersistentFlags().Bool("stopAfterUsers", false, "stop migration after getting users")
 	rootCmd.PersistentFlags().Bool("stopAtError", false, "stop migration after first error")
+	rootCmd.PersistentFlags().StringArray("org", nil, "only migrate this org, by name - can be repeated, and replaces the orgs in the config's org selection")
+	rootCmd.PersistentFlags().Int64Slice("org-id", nil, "only migrate these orgs, by Zendesk ID - replaces the org IDs in the config's org selection")
+	rootCmd.PersistentFlags().StringArray("exclude-org", nil, "never migrate this org, by name or Zendesk ID - can be repeated, and adds to the config's org selection")
+	rootCmd.PersistentFlags().String("archive", "", "read Zendesk data from an archive made with the export command, instead of the Zendesk API")
+	rootCmd.PersistentFlags().String("zendeskExport", "", "read Zendesk data from the unzipped files of a Zendesk account export, instead of the Zendesk API")
+	rootCmd.PersistentFlags().Bool("hashLogEmails", false, "replace customer emails in the log with a hash of each one")
+	rootCmd.PersistentFlags().String("record", "", "record every API request and response, with credentials and personal data redacted, to a cassette file")
+	rootCmd.PersistentFlags().String("replay", "", "serve API responses from a cassette file made with --record, instead of the APIs")
+	rootCmd.PersistentFlags().Int("ticketWorkers", 0, "number of tickets to migrate at once (overrides config)")
+	rootCmd.PersistentFlags().Int("userWorkers", 0, "number of users to migrate at once (overrides config)")
+	rootCmd.PersistentFlags().Int("userBatchSize", 0, "number of orgs to get users for at once (overrides config)")
+	rootCmd.PersistentFlags().Int("zendeskPageSize", 0, "tickets per page when getting Zendesk tickets (overrides config)")
+	rootCmd.PersistentFlags().Int("psaPageSize", 0, "tickets per page when getting ConnectWise PSA tickets (overrides config)")
 }
 
 func parseFlags(cmd *cobra.Command) (migration.CliOptions, error) {
@@ -91,6 +114,46 @@ func parseFlags(cmd *cobra.Command) (migration.CliOptions, error) {
 		return migration.CliOptions{}, fmt.Errorf("getting stop at error flag: %w", err)
 	}
 
+	archive, err := cmd.Flags().GetString("archive")
+	if err != nil {
+		return migration.CliOptions{}, fmt.Errorf("getting archive flag: %w", err)
+	}
+
+	zendeskExport, err := cmd.Flags().GetString("zendeskExport")
+	if err != nil {
+		return migration.CliOptions{}, fmt.Errorf("getting zendesk export flag: %w", err)
+	}
+
+	profile, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return migration.CliOptions{}, fmt.Errorf("getting profile flag: %w", err)
+	}
+
+	hashLogEmails, err := cmd.Flags().GetBool("hashLogEmails")
+	if err != nil {
+		return migration.CliOptions{}, fmt.Errorf("getting hash log emails flag: %w", err)
+	}
+
+	record, err := cmd.Flags().GetString("record")
+	if err != nil {
+		return migration.CliOptions{}, fmt.Errorf("getting record flag: %w", err)
+	}
+
+	replay, err := cmd.Flags().GetString("replay")
+	if err != nil {
+		return migration.CliOptions{}, fmt.Errorf("getting replay flag: %w", err)
+	}
+
+	tuning, err := parseTuningFlags(cmd)
+	if err != nil {
+		return migration.CliOptions{}, err
+	}
+
+	orgs, err := parseOrgFlags(cmd)
+	if err != nil {
+		return migration.CliOptions{}, err
+	}
+
 	return migration.CliOptions{
 		Debug:              debug,
 		TicketLimit:        ticketLimit,
@@ -101,8 +164,61 @@ func parseFlags(cmd *cobra.Command) (migration.CliOptions, error) {
 			Warn:     showWarn,
 			Error:    showError,
 		},
-		StopAfterOrgs:  stopAfterOrgs,
-		StopAfterUsers: stopAfterUsers,
-		StopAtError:    stopAtError,
+		StopAfterOrgs:   stopAfterOrgs,
+		StopAfterUsers:  stopAfterUsers,
+		StopAtError:     stopAtError,
+		Archive:         archive,
+		ZendeskExport:   zendeskExport,
+		Profile:         profile,
+		HashLogEmails:   hashLogEmails,
+		Record:          record,
+		Replay:          replay,
+		TuningOverrides: tuning,
+		OrgOverrides:    orgs,
+	}, nil
+}
+
+func parseTuningFlags(cmd *cobra.Command) (migration.Tuning, error) {
+	var t migration.Tuning
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{
+		{"ticketWorkers", &t.TicketWorkers},
+		{"userWorkers", &t.UserWorkers},
+		{"userBatchSize", &t.UserBatchSize},
+		{"zendeskPageSize", &t.ZendeskPageSize},
+		{"psaPageSize", &t.PsaPageSize},
+	} {
+		v, err := cmd.Flags().GetInt(f.name)
+		if err != nil {
+			return migration.Tuning{}, fmt.Errorf("getting %s flag: %w", f.name, err)
+		}
+		*f.dst = v
+	}
+
+	return t, nil
+}
+
+func parseOrgFlags(cmd *cobra.Command) (migration.OrgSelection, error) {
+	orgs, err := cmd.Flags().GetStringArray("org")
+	if err != nil {
+		return migration.OrgSelection{}, fmt.Errorf("getting org flag: %w", err)
+	}
+
+	orgIds, err := cmd.Flags().GetInt64Slice("org-id")
+	if err != nil {
+		return migration.OrgSelection{}, fmt.Errorf("getting org id flag: %w", err)
+	}
+
+	excludeOrgs, err := cmd.Flags().GetStringArray("exclude-org")
+	if err != nil {
+		return migration.OrgSelection{}, fmt.Errorf("getting exclude org flag: %w", err)
+	}
+
+	return migration.OrgSelection{
+		Orgs:        orgs,
+		OrgIds:      orgIds,
+		ExcludeOrgs: excludeOrgs,
 	}, nil
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var ticketsCmd = &cobra.Command{
+	Use:   "tickets",
+	Short: "Run the ticket phase of the migration on its own",
+}
+
+var ticketsMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the tickets of every org linked to a PSA company",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		return migration.MigrateTickets(opts)
+	},
+}
+
+func init() {
+	ticketsCmd.AddCommand(ticketsMigrateCmd)
+	rootCmd.AddCommand(ticketsCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Run the user phase of the migration on its own",
+}
+
+var usersMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Match or create a PSA contact for every user in the orgs linked to a PSA company",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		return migration.MigrateUsers(opts)
+	},
+}
+
+func init() {
+	usersCmd.AddCommand(usersMigrateCmd)
+	rootCmd.AddCommand(usersCmd)
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/migration"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check every migrated ticket against Zendesk and write a reconciliation report",
+	Long: "Check every migrated ticket against Zendesk and write a reconciliation report. Exits with 0 if everything " +
+		"reconciles, 2 if any ticket is missing, extra or doesn't match, and 1 if verification couldn't run.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := parseFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("parsing flags: %w", err)
+		}
+
+		return migration.Verify(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
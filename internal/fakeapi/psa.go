@@ -0,0 +1,510 @@
+package fakeapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+)
+
+const psaDefaultPageSize = 25
+
+var (
+	nameCondition        = regexp.MustCompile(`name="((?:[^"\\]|\\.)*)"`)
+	emailCondition       = regexp.MustCompile(`communicationItems/value="((?:[^"\\]|\\.)*)"`)
+	customFieldCondition = regexp.MustCompile(`id=(\d+) AND value != null`)
+	companyCondition     = regexp.MustCompile(`company/id=(\d+)`)
+
+	// conditionUnescaper reverses the escaping of quotes and backslashes in a quoted condition value
+	conditionUnescaper = strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+)
+
+type psaData struct {
+	companies []psa.Company
+	contacts  []*psaContact
+	tickets   []*psaTicket
+	boards    []*psaBoard
+	members   []psa.Member
+}
+
+type psaContact struct {
+	contact psa.Contact
+	emails  []string
+	notes   []psa.ContactNote
+}
+
+type psaTicket struct {
+	ticket psa.Ticket
+	notes  []psa.TicketNote
+}
+
+type psaBoard struct {
+	board    psa.Board
+	types    []psa.BoardType
+	statuses []psa.Status
+}
+
+func newPsaData() psaData {
+	return psaData{}
+}
+
+// AddCompany adds a PSA company, which the company lookup matches by exact name
+func (s *Server) AddCompany(co psa.Company) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.psa.companies = append(s.psa.companies, co)
+}
+
+// AddContact adds a PSA contact with email communication items, which the contact lookup matches on
+func (s *Server) AddContact(contact psa.Contact, emails ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.psa.contacts = append(s.psa.contacts, &psaContact{contact: contact, emails: emails})
+}
+
+func (s *Server) AddMember(member psa.Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.psa.members = append(s.psa.members, member)
+}
+
+// AddBoard adds a service board with its types and statuses
+func (s *Server) AddBoard(board psa.Board, types []psa.BoardType, statuses []psa.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.psa.boards = append(s.psa.boards, &psaBoard{board: board, types: types, statuses: statuses})
+}
+
+// Contacts returns every PSA contact, including those created by the migration
+func (s *Server) Contacts() []psa.Contact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var contacts []psa.Contact
+	for _, c := range s.psa.contacts {
+		contacts = append(contacts, c.contact)
+	}
+
+	return contacts
+}
+
+// Tickets returns every PSA ticket, in the order they were created
+func (s *Server) Tickets() []psa.Ticket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tickets []psa.Ticket
+	for _, t := range s.psa.tickets {
+		tickets = append(tickets, t.ticket)
+	}
+
+	return tickets
+}
+
+// Notes returns the notes on a PSA ticket
+func (s *Server) Notes(ticketId int) []psa.TicketNote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t := s.psaTicket(ticketId); t != nil {
+		return slices.Clone(t.notes)
+	}
+
+	return nil
+}
+
+// DeleteTicket deletes a PSA ticket, as if someone deleted it after it was migrated
+func (s *Server) DeleteTicket(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.psa.tickets = slices.DeleteFunc(s.psa.tickets, func(t *psaTicket) bool {
+		return t.ticket.Id == id
+	})
+}
+
+// DeleteNote deletes the last note on a PSA ticket, as if someone deleted it after it was migrated
+func (s *Server) DeleteNote(ticketId int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t := s.psaTicket(ticketId); t != nil && len(t.notes) > 0 {
+		t.notes = t.notes[:len(t.notes)-1]
+	}
+}
+
+func (s *Server) psaRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+PsaPath+"/company/companies", s.getCompanies)
+	mux.HandleFunc("GET "+PsaPath+"/company/contacts", s.getContacts)
+	mux.HandleFunc("POST "+PsaPath+"/company/contacts", s.postContact)
+	mux.HandleFunc("POST "+PsaPath+"/company/contacts/{id}/notes", s.postContactNote)
+	mux.HandleFunc("GET "+PsaPath+"/service/tickets", s.getPsaTickets)
+	mux.HandleFunc("POST "+PsaPath+"/service/tickets", s.postPsaTicket)
+	mux.HandleFunc("GET "+PsaPath+"/service/tickets/count", s.getPsaTicketCount)
+	mux.HandleFunc("GET "+PsaPath+"/service/tickets/{id}", s.getPsaTicket)
+	mux.HandleFunc("PATCH "+PsaPath+"/service/tickets/{id}", s.patchPsaTicket)
+	mux.HandleFunc("POST "+PsaPath+"/service/tickets/{id}/notes", s.postTicketNote)
+	mux.HandleFunc("GET "+PsaPath+"/service/tickets/{id}/notes/count", s.getTicketNoteCount)
+	mux.HandleFunc("GET "+PsaPath+"/service/boards", s.getBoards)
+	mux.HandleFunc("GET "+PsaPath+"/service/boards/{id}/types", s.getBoardTypes)
+	mux.HandleFunc("GET "+PsaPath+"/service/boards/{id}/statuses", s.getBoardStatuses)
+	mux.HandleFunc("GET "+PsaPath+"/system/members", s.getMembers)
+}
+
+// writePage writes a page of items using ConnectWise's page and pageSize parameters, with a Link header for the
+// next page
+func writePage[T any](s *Server, w http.ResponseWriter, r *http.Request, items []T) {
+	size := s.pageSize(r.URL.Query().Get("pageSize"), psaDefaultPageSize)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+
+	start, end, more := pageBounds(len(items), (page-1)*size, size)
+	if more {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, withQuery(r, "page", strconv.Itoa(page+1))))
+	}
+
+	// an empty page is [], not null
+	writeJson(w, append([]T{}, items[start:end]...))
+}
+
+// psaTicket returns the ticket with the ID. The caller must hold the lock.
+func (s *Server) psaTicket(id int) *psaTicket {
+	for _, t := range s.psa.tickets {
+		if t.ticket.Id == id {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) getCompanies(w http.ResponseWriter, r *http.Request) {
+	var name *string
+	if m := nameCondition.FindStringSubmatch(r.URL.Query().Get("conditions")); m != nil {
+		v := conditionUnescaper.Replace(m[1])
+		name = &v
+	}
+
+	s.mu.Lock()
+	var companies []psa.Company
+	for _, co := range s.psa.companies {
+		if name == nil || co.Name == *name {
+			companies = append(companies, co)
+		}
+	}
+	s.mu.Unlock()
+
+	writePage(s, w, r, companies)
+}
+
+// getContacts matches contacts with any of the emails in the child conditions, ignoring case like ConnectWise does
+func (s *Server) getContacts(w http.ResponseWriter, r *http.Request) {
+	var emails []string
+	for _, m := range emailCondition.FindAllStringSubmatch(r.URL.Query().Get("childConditions"), -1) {
+		emails = append(emails, strings.ToLower(conditionUnescaper.Replace(m[1])))
+	}
+
+	s.mu.Lock()
+	var contacts []psa.Contact
+	for _, c := range s.psa.contacts {
+		if len(emails) == 0 || slices.ContainsFunc(c.emails, func(e string) bool {
+			return slices.Contains(emails, strings.ToLower(e))
+		}) {
+			contacts = append(contacts, c.contact)
+		}
+	}
+	s.mu.Unlock()
+
+	writePage(s, w, r, contacts)
+}
+
+func (s *Server) postContact(w http.ResponseWriter, r *http.Request) {
+	var body psa.ContactPostBody
+	if !readJson(w, r, &body) {
+		return
+	}
+
+	var emails []string
+	for _, item := range body.CommunicationItems {
+		if strings.EqualFold(item.Type.Name, "email") {
+			emails = append(emails, item.Value)
+		}
+	}
+
+	s.mu.Lock()
+	co := body.Company
+	contact := psa.Contact{
+		Id:           s.newId(),
+		FirstName:    body.FirstName,
+		LastName:     body.LastName,
+		Title:        body.Title,
+		Company:      &co,
+		InactiveFlag: body.InactiveFlag,
+		Info:         &psa.Info{LastUpdated: time.Now().UTC()},
+	}
+	s.psa.contacts = append(s.psa.contacts, &psaContact{contact: contact, emails: emails})
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, contact)
+}
+
+func (s *Server) postContactNote(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	var note psa.ContactNote
+	if !readJson(w, r, &note) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.psa.contacts {
+		if c.contact.Id == int(id) {
+			note.Id = s.newId()
+			c.notes = append(c.notes, note)
+			w.WriteHeader(http.StatusCreated)
+			writeJson(w, note)
+			return
+		}
+	}
+
+	http.NotFound(w, r)
+}
+
+// getPsaTickets returns every ticket, or with a custom field condition, only those with a value in that field
+func (s *Server) getPsaTickets(w http.ResponseWriter, r *http.Request) {
+	tickets := s.matchingPsaTickets(r)
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].Id < tickets[j].Id })
+	writePage(s, w, r, tickets)
+}
+
+func (s *Server) getPsaTicketCount(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, struct {
+		Count int `json:"count"`
+	}{len(s.matchingPsaTickets(r))})
+}
+
+// matchingPsaTickets returns the tickets matching a company condition and a custom field condition, if the
+// request has them
+func (s *Server) matchingPsaTickets(r *http.Request) []psa.Ticket {
+	fieldId := -1
+	if m := customFieldCondition.FindStringSubmatch(r.URL.Query().Get("customFieldConditions")); m != nil {
+		fieldId, _ = strconv.Atoi(m[1])
+	}
+
+	companyId := -1
+	if m := companyCondition.FindStringSubmatch(r.URL.Query().Get("conditions")); m != nil {
+		companyId, _ = strconv.Atoi(m[1])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tickets []psa.Ticket
+	for _, t := range s.psa.tickets {
+		if companyId != -1 && (t.ticket.Company == nil || t.ticket.Company.Id != companyId) {
+			continue
+		}
+
+		if fieldId == -1 || slices.ContainsFunc(t.ticket.CustomFields, func(f psa.CustomField) bool {
+			return f.Id == fieldId && f.Value != nil
+		}) {
+			tickets = append(tickets, t.ticket)
+		}
+	}
+
+	return tickets
+}
+
+func (s *Server) postPsaTicket(w http.ResponseWriter, r *http.Request) {
+	var ticket psa.Ticket
+	if !readJson(w, r, &ticket) {
+		return
+	}
+
+	s.mu.Lock()
+	ticket.Id = s.newId()
+	ticket = roundTrip(ticket)
+	s.psa.tickets = append(s.psa.tickets, &psaTicket{ticket: ticket})
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, ticket)
+}
+
+func (s *Server) getPsaTicket(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.psaTicket(int(id))
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJson(w, t.ticket)
+}
+
+// patchPsaTicket supports replacing the status, which is the only patch the migrator sends
+func (s *Server) patchPsaTicket(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	var ops psa.PatchPayload
+	if !readJson(w, r, &ops) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.psaTicket(int(id))
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	for _, op := range ops {
+		if op.Op != "replace" || op.Path != "status/id" {
+			http.Error(w, fmt.Sprintf("unsupported patch: %s %s", op.Op, op.Path), http.StatusBadRequest)
+			return
+		}
+
+		statusId, ok := op.Value.(float64)
+		if !ok {
+			http.Error(w, "status id must be a number", http.StatusBadRequest)
+			return
+		}
+
+		t.ticket.Status = &psa.Status{Id: int(statusId)}
+	}
+
+	writeJson(w, t.ticket)
+}
+
+func (s *Server) postTicketNote(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	var note psa.TicketNote
+	if !readJson(w, r, &note) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.psaTicket(int(id))
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	t.notes = append(t.notes, note)
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, note)
+}
+
+func (s *Server) getTicketNoteCount(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.psaTicket(int(id))
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJson(w, struct {
+		Count int `json:"count"`
+	}{len(t.notes)})
+}
+
+func (s *Server) getBoards(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var boards []psa.Board
+	for _, b := range s.psa.boards {
+		boards = append(boards, b.board)
+	}
+	s.mu.Unlock()
+
+	writePage(s, w, r, boards)
+}
+
+// board returns the board with the ID in the path. The caller must hold the lock.
+func (s *Server) board(w http.ResponseWriter, r *http.Request) *psaBoard {
+	id, ok := pathId(w, r)
+	if !ok {
+		return nil
+	}
+
+	for _, b := range s.psa.boards {
+		if b.board.Id == int(id) {
+			return b
+		}
+	}
+
+	http.NotFound(w, r)
+	return nil
+}
+
+func (s *Server) getBoardTypes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	b := s.board(w, r)
+	var types []psa.BoardType
+	if b != nil {
+		types = slices.Clone(b.types)
+	}
+	s.mu.Unlock()
+
+	if b != nil {
+		writePage(s, w, r, types)
+	}
+}
+
+func (s *Server) getBoardStatuses(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	b := s.board(w, r)
+	var statuses []psa.Status
+	if b != nil {
+		statuses = slices.Clone(b.statuses)
+	}
+	s.mu.Unlock()
+
+	if b != nil {
+		writePage(s, w, r, statuses)
+	}
+}
+
+func (s *Server) getMembers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	members := slices.Clone(s.psa.members)
+	s.mu.Unlock()
+
+	writePage(s, w, r, members)
+}
@@ -0,0 +1,238 @@
+// Package fakeapi is an in-memory stand-in for the Zendesk and ConnectWise PSA API endpoints the migrator uses,
+// for end-to-end tests. Both APIs are served from one local server: point the Zendesk client at ZendeskUrl and
+// the PSA client at PsaUrl. Data is seeded with the Add methods, read back with the getters, and any request can
+// be scripted to fail with Fail.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync"
+)
+
+const (
+	// ZendeskPath and PsaPath are the path prefixes of each API, for building Fault paths
+	ZendeskPath = "/api/v2"
+	PsaPath     = "/v4_6_release/apis/3.0"
+)
+
+// Fault makes requests fail with a status code instead of being handled
+type Fault struct {
+	// Method and Path match requests. Path is a path.Match pattern for the whole path, e.g.
+	// PsaPath + "/service/tickets/*/notes".
+	Method string
+	Path   string
+
+	Status int
+
+	// RetryAfter is sent as the Retry-After header, if set
+	RetryAfter string
+
+	// Times is how many matching requests fail. Zero means every one.
+	Times int
+}
+
+// Request is a request the server received
+type Request struct {
+	Method string
+	Path   string
+	Status int
+}
+
+type Server struct {
+	srv *httptest.Server
+
+	// MaxPageSize caps the size of every page, so pagination can be tested with a handful of items
+	MaxPageSize int
+
+	mu       sync.Mutex
+	faults   []*Fault
+	requests []Request
+	nextId   int
+
+	zendesk zendeskData
+	psa     psaData
+}
+
+// NewServer starts a server with no data. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		nextId:  1000,
+		zendesk: newZendeskData(),
+		psa:     newPsaData(),
+	}
+
+	mux := http.NewServeMux()
+	s.zendeskRoutes(mux)
+	s.psaRoutes(mux)
+	s.srv = httptest.NewServer(s.handler(mux))
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// ZendeskUrl is the base URL for the Zendesk client
+func (s *Server) ZendeskUrl() string {
+	return s.srv.URL + ZendeskPath
+}
+
+// PsaUrl is the base URL for the ConnectWise PSA client
+func (s *Server) PsaUrl() string {
+	return s.srv.URL + PsaPath
+}
+
+// Fail adds a fault. Faults are checked in the order they were added.
+func (s *Server) Fail(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// Count returns how many requests matched the method and path pattern, including any that were failed
+func (s *Server) Count(method, pattern string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for _, r := range s.requests {
+		if matches(method, pattern, r.Method, r.Path) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Requests returns every request the server has received, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) handler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f := s.takeFault(r); f != nil {
+			if f.RetryAfter != "" {
+				w.Header().Set("Retry-After", f.RetryAfter)
+			}
+			s.logRequest(r, f.Status)
+			http.Error(w, http.StatusText(f.Status), f.Status)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+		s.logRequest(r, rec.status)
+	})
+}
+
+func (s *Server) takeFault(r *http.Request) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if !matches(f.Method, f.Path, r.Method, r.URL.Path) {
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+
+		return f
+	}
+
+	return nil
+}
+
+func (s *Server) logRequest(r *http.Request, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Status: status})
+}
+
+// newId returns a new ID for anything created through the API. The caller must hold the lock.
+func (s *Server) newId() int {
+	s.nextId++
+	return s.nextId
+}
+
+// pageSize returns the requested page size, or the default, capped at MaxPageSize
+func (s *Server) pageSize(requested string, def int) int {
+	size, err := strconv.Atoi(requested)
+	if err != nil || size <= 0 {
+		size = def
+	}
+
+	if s.MaxPageSize > 0 && size > s.MaxPageSize {
+		size = s.MaxPageSize
+	}
+
+	return size
+}
+
+func matches(method, pattern, reqMethod, reqPath string) bool {
+	if method != "" && method != reqMethod {
+		return false
+	}
+
+	ok, err := path.Match(pattern, reqPath)
+	return err == nil && ok
+}
+
+// pageBounds returns the bounds of the page starting at offset, and whether any items come after it
+func pageBounds(total, offset, size int) (int, int, bool) {
+	start := min(max(offset, 0), total)
+	end := min(start+size, total)
+	return start, end, end < total
+}
+
+// withQuery returns the full URL of the request with a query parameter replaced, for next page links
+func withQuery(r *http.Request, key, value string) string {
+	q := r.URL.Query()
+	q.Set(key, value)
+	return fmt.Sprintf("http://%s%s?%s", r.Host, r.URL.Path, q.Encode())
+}
+
+func writeJson(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func readJson(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// roundTrip returns v as it would be decoded from a response, e.g. with custom field values as float64
+func roundTrip[T any](v T) T {
+	var out T
+	b, _ := json.Marshal(v)
+	_ = json.Unmarshal(b, &out)
+	return out
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,637 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+)
+
+const zendeskDefaultPageSize = 100
+
+type zendeskData struct {
+	orgs       map[int64]*zendeskOrg
+	users      map[int64]*zendeskUser
+	tickets    map[int]*zendeskTicket
+	userFields []zendesk.UserField
+	orgFields  []zendesk.OrganizationField
+}
+
+type zendeskOrg struct {
+	org  zendesk.Organization
+	tags []string
+}
+
+type zendeskUser struct {
+	user       zendesk.User
+	orgIds     []int64
+	identities []zendesk.Identity
+	deleted    bool
+}
+
+type zendeskTicket struct {
+	ticket   zendesk.Ticket
+	comments []zendesk.Comment
+}
+
+func newZendeskData() zendeskData {
+	return zendeskData{
+		orgs:    make(map[int64]*zendeskOrg),
+		users:   make(map[int64]*zendeskUser),
+		tickets: make(map[int]*zendeskTicket),
+	}
+}
+
+// AddOrg adds a Zendesk org with tags, which the org search matches on
+func (s *Server) AddOrg(org zendesk.Organization, tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zendesk.orgs[org.Id] = &zendeskOrg{org: org, tags: tags}
+}
+
+// AddUser adds a Zendesk user, as a member of the org unless orgId is 0. Agents are users with the agent or
+// admin role.
+func (s *Server) AddUser(user zendesk.User, orgId int64, identities ...zendesk.Identity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := &zendeskUser{user: user, identities: identities}
+	if orgId != 0 {
+		u.orgIds = []int64{orgId}
+	}
+	s.zendesk.users[int64(user.Id)] = u
+}
+
+// AddUserToOrg makes an existing user a member of another org as well
+func (s *Server) AddUserToOrg(userId int, orgId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.zendesk.users[int64(userId)]; ok && !slices.Contains(u.orgIds, orgId) {
+		u.orgIds = append(u.orgIds, orgId)
+	}
+}
+
+// AddDeletedUser adds a user that is only returned by the deleted users endpoint
+func (s *Server) AddDeletedUser(user zendesk.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zendesk.users[int64(user.Id)] = &zendeskUser{user: user, deleted: true}
+}
+
+// AddTicket adds a Zendesk ticket with its comments. The ticket search matches on its OrganizationId, CreatedAt
+// and Status.
+func (s *Server) AddTicket(ticket zendesk.Ticket, comments ...zendesk.Comment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zendesk.tickets[ticket.Id] = &zendeskTicket{ticket: ticket, comments: comments}
+}
+
+// AddUserField adds a custom user field, as if it had been created before the migration
+func (s *Server) AddUserField(field zendesk.UserField) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zendesk.userFields = append(s.zendesk.userFields, field)
+}
+
+// AddOrgField adds a custom organization field, as if it had been created before the migration
+func (s *Server) AddOrgField(field zendesk.OrganizationField) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zendesk.orgFields = append(s.zendesk.orgFields, field)
+}
+
+// ZendeskOrg returns an org as it is now, e.g. after the migration linked it to a PSA company
+func (s *Server) ZendeskOrg(id int64) (zendesk.Organization, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.zendesk.orgs[id]
+	if !ok {
+		return zendesk.Organization{}, false
+	}
+	return o.org, true
+}
+
+// ZendeskUser returns a user as they are now, e.g. after the migration linked them to a PSA contact
+func (s *Server) ZendeskUser(id int64) (zendesk.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.zendesk.users[id]
+	if !ok {
+		return zendesk.User{}, false
+	}
+	return u.user, true
+}
+
+// AttachmentUrl is the content URL of a comment attachment. Every attachment URL serves "attachment <id>".
+func (s *Server) AttachmentUrl(id int64, fileName string) string {
+	return fmt.Sprintf("%s/attachments/token/%d/%s", s.srv.URL, id, fileName)
+}
+
+func (s *Server) zendeskRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /attachments/token/{id}/{name}", s.getAttachment)
+	mux.HandleFunc("GET "+ZendeskPath+"/users", s.getZendeskUsers)
+	mux.HandleFunc("GET "+ZendeskPath+"/users/{id}", s.getZendeskUser)
+	mux.HandleFunc("PUT "+ZendeskPath+"/users/{id}", s.putZendeskUser)
+	mux.HandleFunc("GET "+ZendeskPath+"/deleted_users/{id}", s.getDeletedUser)
+	mux.HandleFunc("GET "+ZendeskPath+"/users/{id}/identities", s.getIdentities)
+	mux.HandleFunc("GET "+ZendeskPath+"/organizations/{id}", s.getZendeskOrg)
+	mux.HandleFunc("PUT "+ZendeskPath+"/organizations/{id}", s.putZendeskOrg)
+	mux.HandleFunc("GET "+ZendeskPath+"/organizations/{id}/users", s.getOrgUsers)
+	mux.HandleFunc("GET "+ZendeskPath+"/organizations/{id}/related.json", s.getOrgRelated)
+	mux.HandleFunc("GET "+ZendeskPath+"/search.json", s.searchOrgs)
+	mux.HandleFunc("GET "+ZendeskPath+"/search/export.json", s.exportSearchTickets)
+	mux.HandleFunc("GET "+ZendeskPath+"/search/count.json", s.countSearch)
+	mux.HandleFunc("GET "+ZendeskPath+"/tickets/{id}", s.getZendeskTicket)
+	mux.HandleFunc("GET "+ZendeskPath+"/tickets/{id}/comments.json", s.getComments)
+	mux.HandleFunc("GET "+ZendeskPath+"/user_fields", s.getUserFields)
+	mux.HandleFunc("POST "+ZendeskPath+"/user_fields", s.postUserField)
+	mux.HandleFunc("GET "+ZendeskPath+"/organization_fields", s.getOrgFields)
+	mux.HandleFunc("POST "+ZendeskPath+"/organization_fields", s.postOrgField)
+}
+
+// cursorPage returns a page of items using Zendesk's cursor pagination, where the cursor is the offset
+func cursorPage[T any](s *Server, r *http.Request, items []T) ([]T, zendesk.Meta, zendesk.Links) {
+	size := s.pageSize(r.URL.Query().Get("page[size]"), zendeskDefaultPageSize)
+	offset, _ := strconv.Atoi(r.URL.Query().Get("page[after]"))
+
+	start, end, more := pageBounds(len(items), offset, size)
+	var links zendesk.Links
+	if more {
+		links.Next = withQuery(r, "page[after]", strconv.Itoa(end))
+	}
+
+	return items[start:end], zendesk.Meta{HasMore: more}, links
+}
+
+func (s *Server) getAttachment(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "attachment %d", id)
+}
+
+func pathId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// sortedUsers returns the users matching the filter, sorted by ID. The caller must hold the lock.
+func (s *Server) sortedUsers(filter func(u *zendeskUser) bool) []zendesk.User {
+	var users []zendesk.User
+	for _, u := range s.zendesk.users {
+		if filter(u) {
+			users = append(users, u.user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+	return users
+}
+
+func (s *Server) getZendeskUsers(w http.ResponseWriter, r *http.Request) {
+	roles := r.URL.Query()["role[]"]
+
+	s.mu.Lock()
+	users := s.sortedUsers(func(u *zendeskUser) bool {
+		return !u.deleted && (len(roles) == 0 || slices.Contains(roles, u.user.Role))
+	})
+	s.mu.Unlock()
+
+	page, meta, links := cursorPage(s, r, users)
+	writeJson(w, zendesk.UsersResp{Users: page, Meta: meta, Links: links})
+}
+
+func (s *Server) getZendeskUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.zendesk.users[id]
+	if !ok || u.deleted {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJson(w, zendesk.UserResp{User: u.user})
+}
+
+// putZendeskUser updates the user fields sent, and leaves everything else as it was
+func (s *Server) putZendeskUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		User struct {
+			UserFields json.RawMessage `json:"user_fields"`
+		} `json:"user"`
+	}
+	if !readJson(w, r, &body) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.zendesk.users[id]
+	if !ok || u.deleted {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(body.User.UserFields) > 0 {
+		if err := json.Unmarshal(body.User.UserFields, &u.user.UserFields); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJson(w, zendesk.UserResp{User: u.user})
+}
+
+func (s *Server) getDeletedUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.zendesk.users[id]
+	if !ok || !u.deleted {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJson(w, zendesk.DeletedUserResp{DeletedUser: u.user})
+}
+
+func (s *Server) getIdentities(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	u, ok := s.zendesk.users[id]
+	var identities []zendesk.Identity
+	if ok {
+		identities = slices.Clone(u.identities)
+	}
+	s.mu.Unlock()
+
+	if !ok || u.deleted {
+		http.NotFound(w, r)
+		return
+	}
+
+	page, meta, links := cursorPage(s, r, identities)
+	writeJson(w, zendesk.IdentitiesResp{Identities: page, Meta: meta, Links: links})
+}
+
+type orgBody struct {
+	Organization zendesk.Organization `json:"organization"`
+}
+
+func (s *Server) getZendeskOrg(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.zendesk.orgs[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJson(w, orgBody{Organization: o.org})
+}
+
+func (s *Server) putZendeskOrg(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	var body orgBody
+	if !readJson(w, r, &body) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.zendesk.orgs[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	o.org.Name = body.Organization.Name
+	o.org.OrganizationFields = body.Organization.OrganizationFields
+	writeJson(w, orgBody{Organization: o.org})
+}
+
+func (s *Server) getOrgUsers(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	_, found := s.zendesk.orgs[id]
+	users := s.sortedUsers(func(u *zendeskUser) bool {
+		return !u.deleted && slices.Contains(u.orgIds, id)
+	})
+	s.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	page, meta, links := cursorPage(s, r, users)
+	writeJson(w, zendesk.UsersResp{Users: page, Meta: meta, Links: links})
+}
+
+// getOrgRelated has the org's user and ticket counts
+func (s *Server) getOrgRelated(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	_, found := s.zendesk.orgs[id]
+	users := s.sortedUsers(func(u *zendeskUser) bool {
+		return !u.deleted && slices.Contains(u.orgIds, id)
+	})
+
+	var tickets int
+	for _, t := range s.zendesk.tickets {
+		if t.ticket.OrganizationId == id {
+			tickets++
+		}
+	}
+	s.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJson(w, map[string]any{
+		"organization_related": map[string]int{"users_count": len(users), "tickets_count": tickets},
+	})
+}
+
+// searchQuery is a parsed Zendesk search query, with the terms the migrator uses
+type searchQuery struct {
+	searchType string
+	tags       []string
+	orgId      int64
+	after      string
+	before     string
+	statuses   []string
+}
+
+func parseSearchQuery(q string) searchQuery {
+	var sq searchQuery
+	for _, term := range strings.Fields(q) {
+		switch {
+		case strings.HasPrefix(term, "type:"):
+			sq.searchType = strings.TrimPrefix(term, "type:")
+		case strings.HasPrefix(term, "tags:"):
+			sq.tags = append(sq.tags, strings.TrimPrefix(term, "tags:"))
+		case strings.HasPrefix(term, "organization:"):
+			sq.orgId, _ = strconv.ParseInt(strings.TrimPrefix(term, "organization:"), 10, 64)
+		case strings.HasPrefix(term, "created>"):
+			sq.after = strings.TrimPrefix(term, "created>")
+		case strings.HasPrefix(term, "created<"):
+			sq.before = strings.TrimPrefix(term, "created<")
+		case strings.HasPrefix(term, "status:"):
+			sq.statuses = append(sq.statuses, strings.TrimPrefix(term, "status:"))
+		}
+	}
+
+	return sq
+}
+
+// matchesTicket reports whether a ticket matches the query. Repeated status terms match any of the statuses.
+func (q searchQuery) matchesTicket(t zendesk.Ticket) bool {
+	if q.orgId != 0 && t.OrganizationId != q.orgId {
+		return false
+	}
+
+	created := t.CreatedAt.UTC().Format("2006-01-02")
+	if q.after != "" && created <= q.after {
+		return false
+	}
+
+	if q.before != "" && created >= q.before {
+		return false
+	}
+
+	return len(q.statuses) == 0 || slices.Contains(q.statuses, t.Status)
+}
+
+// searchOrgs is the org search, which uses offset pagination with a next_page URL
+func (s *Server) searchOrgs(w http.ResponseWriter, r *http.Request) {
+	q := parseSearchQuery(r.URL.Query().Get("query"))
+	if q.searchType != string(zendesk.OrgSearchType) {
+		http.Error(w, "only organization searches are supported", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	var orgs []zendesk.Organization
+	for _, o := range s.zendesk.orgs {
+		matched := true
+		for _, tag := range q.tags {
+			if !slices.Contains(o.tags, tag) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			orgs = append(orgs, o.org)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Id < orgs[j].Id })
+
+	size := s.pageSize(r.URL.Query().Get("per_page"), zendeskDefaultPageSize)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+
+	start, end, more := pageBounds(len(orgs), (page-1)*size, size)
+	resp := zendesk.OrgSearchResp{Organizations: orgs[start:end]}
+	if more {
+		resp.NextPage = withQuery(r, "page", strconv.Itoa(page+1))
+	}
+
+	writeJson(w, resp)
+}
+
+// exportSearchTickets is the search export, which uses cursor pagination
+func (s *Server) exportSearchTickets(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("filter[type]") != string(zendesk.TicketSearchType) {
+		http.Error(w, "only ticket exports are supported", http.StatusBadRequest)
+		return
+	}
+
+	q := parseSearchQuery(r.URL.Query().Get("query"))
+
+	s.mu.Lock()
+	var tickets []zendesk.Ticket
+	for _, t := range s.zendesk.tickets {
+		if q.matchesTicket(t.ticket) {
+			tickets = append(tickets, t.ticket)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].Id < tickets[j].Id })
+
+	page, meta, links := cursorPage(s, r, tickets)
+	writeJson(w, zendesk.TicketSearchResp{Tickets: page, Meta: meta, Links: links})
+}
+
+// countSearch is the search count, which only supports ticket searches
+func (s *Server) countSearch(w http.ResponseWriter, r *http.Request) {
+	q := parseSearchQuery(r.URL.Query().Get("query"))
+	if q.searchType != string(zendesk.TicketSearchType) {
+		http.Error(w, "only ticket counts are supported", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	var count int
+	for _, t := range s.zendesk.tickets {
+		if q.matchesTicket(t.ticket) {
+			count++
+		}
+	}
+	s.mu.Unlock()
+
+	writeJson(w, struct {
+		Count int `json:"count"`
+	}{count})
+}
+
+func (s *Server) getZendeskTicket(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.zendesk.tickets[int(id)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJson(w, struct {
+		Ticket zendesk.Ticket `json:"ticket"`
+	}{t.ticket})
+}
+
+func (s *Server) getComments(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathId(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	t, ok := s.zendesk.tickets[int(id)]
+	var comments []zendesk.Comment
+	if ok {
+		comments = slices.Clone(t.comments)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	page, meta, links := cursorPage(s, r, comments)
+	writeJson(w, zendesk.TicketCommentsResp{Comments: page, Meta: meta, Links: links})
+}
+
+func (s *Server) getUserFields(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	fields := slices.Clone(s.zendesk.userFields)
+	s.mu.Unlock()
+
+	page, meta, links := cursorPage(s, r, fields)
+	writeJson(w, zendesk.UserFieldsResp{UserFields: page, Meta: meta, Links: links})
+}
+
+func (s *Server) postUserField(w http.ResponseWriter, r *http.Request) {
+	var body zendesk.PostUserField
+	if !readJson(w, r, &body) {
+		return
+	}
+
+	s.mu.Lock()
+	body.UserField.Id = int64(s.newId())
+	s.zendesk.userFields = append(s.zendesk.userFields, body.UserField)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, body)
+}
+
+func (s *Server) getOrgFields(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	fields := slices.Clone(s.zendesk.orgFields)
+	s.mu.Unlock()
+
+	page, meta, links := cursorPage(s, r, fields)
+	writeJson(w, zendesk.OrganizationFieldsResp{OrganizationFields: page, Meta: meta, Links: links})
+}
+
+func (s *Server) postOrgField(w http.ResponseWriter, r *http.Request) {
+	var body zendesk.PostOrganizationField
+	if !readJson(w, r, &body) {
+		return
+	}
+
+	s.mu.Lock()
+	body.OrganizationField.Id = int64(s.newId())
+	s.zendesk.orgFields = append(s.zendesk.orgFields, body.OrganizationField)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, body)
+}
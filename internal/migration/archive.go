@@ -0,0 +1,581 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	archivesDirName = "archives"
+	archiveVersion  = 1
+
+	archiveManifestName    = "manifest.json"
+	archiveOrgsName        = "organizations.ndjson"
+	archiveUsersName       = "users.ndjson"
+	archiveTicketsName     = "tickets.ndjson"
+	archiveCommentsName    = "comments.ndjson"
+	archiveFieldsName      = "fields.ndjson"
+	archiveUpdatesName     = "updates.ndjson"
+	archiveAttachmentsName = "attachments"
+
+	archiveDateLayout = "2006-01-02"
+)
+
+// archiveManifest describes an archive. It is written last, so an archive without one is incomplete.
+type archiveManifest struct {
+	Version       int          `json:"version"`
+	Subdomain     string       `json:"subdomain"`
+	ExportedAt    time.Time    `json:"exported_at"`
+	Tags          []archiveTag `json:"tags"`
+	Organizations int          `json:"organizations"`
+	Users         int          `json:"users"`
+	Tickets       int          `json:"tickets"`
+	Comments      int          `json:"comments"`
+	Attachments   int          `json:"attachments"`
+}
+
+// archiveTag is a tag and the ticket creation dates that were exported for it
+type archiveTag struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// archivedOrg is an org with the tags it was exported under
+type archivedOrg struct {
+	zendesk.Organization
+	Tags []string `json:"tags"`
+}
+
+// archivedUser is a user with the orgs they're a member of and their identities. Deleted users are requesters or
+// comment authors that were only found with the deleted users endpoint.
+type archivedUser struct {
+	zendesk.User
+	OrganizationIds []int64            `json:"organization_ids,omitempty"`
+	Identities      []zendesk.Identity `json:"identities,omitempty"`
+	Deleted         bool               `json:"deleted,omitempty"`
+}
+
+type archivedComment struct {
+	TicketId int64 `json:"ticket_id"`
+	zendesk.Comment
+}
+
+type archivedField struct {
+	UserField         *zendesk.UserField         `json:"user_field,omitempty"`
+	OrganizationField *zendesk.OrganizationField `json:"organization_field,omitempty"`
+}
+
+// archiveUpdate is a change the migration made while reading from an archive, such as linking a user to a PSA
+// contact. Updates are appended to updates.ndjson and replayed when the archive is opened again.
+type archiveUpdate struct {
+	User              *zendesk.User              `json:"user,omitempty"`
+	Organization      *zendesk.Organization      `json:"organization,omitempty"`
+	UserField         *zendesk.UserField         `json:"user_field,omitempty"`
+	OrganizationField *zendesk.OrganizationField `json:"organization_field,omitempty"`
+}
+
+// attachmentPath is where an attachment's content is saved, relative to the archive directory
+func attachmentPath(a zendesk.Attachment) string {
+	name := filepath.Base(a.FileName)
+	if name == "." || name == string(filepath.Separator) {
+		name = "attachment"
+	}
+
+	return filepath.Join(archiveAttachmentsName, strconv.FormatInt(a.Id, 10), name)
+}
+
+// archiveWriter appends JSON records to the NDJSON files in an archive directory. It is safe for concurrent use.
+type archiveWriter struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+func newArchiveWriter(dir string) (*archiveWriter, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("creating archive directory: %w", err)
+	}
+
+	return &archiveWriter{dir: dir, files: make(map[string]*os.File)}, nil
+}
+
+func (w *archiveWriter) append(name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshaling %s record: %w", name, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	f, ok := w.files[name]
+	if !ok {
+		f, err = os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", name, err)
+		}
+		w.files[name] = f
+	}
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("writing %s record: %w", name, err)
+	}
+
+	return nil
+}
+
+func (w *archiveWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []error
+	for _, f := range w.files {
+		errs = append(errs, f.Close())
+	}
+	w.files = make(map[string]*os.File)
+
+	return errors.Join(errs...)
+}
+
+// readArchiveFile decodes every record in an NDJSON archive file, in order
+func readArchiveFile[T any](dir, name string, fn func(T)) error {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", name, err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		fn(v)
+	}
+}
+
+// archiveSource is a Source that reads from an archive written by the export command instead of the
+// Zendesk API. Everything is held in memory; changes are saved to the archive's updates file, unless it has none.
+type archiveSource struct {
+	dir      string
+	manifest archiveManifest
+
+	// updates is nil for sources that are read only, such as a Zendesk account export
+	updates *archiveWriter
+
+	mu         sync.RWMutex
+	orgs       map[int64]*archivedOrg
+	users      map[int64]*archivedUser
+	tickets    map[int64]zendesk.Ticket
+	comments   map[int64][]zendesk.Comment
+	userFields []zendesk.UserField
+	orgFields  []zendesk.OrganizationField
+}
+
+func newArchiveSource(dir string) *archiveSource {
+	return &archiveSource{
+		dir:      dir,
+		orgs:     make(map[int64]*archivedOrg),
+		users:    make(map[int64]*archivedUser),
+		tickets:  make(map[int64]zendesk.Ticket),
+		comments: make(map[int64][]zendesk.Comment),
+	}
+}
+
+func openArchive(dir string) (*archiveSource, error) {
+	a := newArchiveSource(dir)
+
+	b, err := os.ReadFile(filepath.Join(dir, archiveManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("reading archive manifest - is the export complete?: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &a.manifest); err != nil {
+		return nil, fmt.Errorf("unmarshaling archive manifest: %w", err)
+	}
+
+	if a.manifest.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", a.manifest.Version)
+	}
+
+	err = errors.Join(
+		readArchiveFile(dir, archiveOrgsName, func(o archivedOrg) { a.orgs[o.Id] = &o }),
+		readArchiveFile(dir, archiveUsersName, func(u archivedUser) { a.users[int64(u.Id)] = &u }),
+		readArchiveFile(dir, archiveTicketsName, func(t zendesk.Ticket) { a.tickets[int64(t.Id)] = t }),
+		readArchiveFile(dir, archiveCommentsName, func(c archivedComment) {
+			a.comments[c.TicketId] = append(a.comments[c.TicketId], c.Comment)
+		}),
+		readArchiveFile(dir, archiveFieldsName, func(f archivedField) {
+			if f.UserField != nil {
+				a.userFields = append(a.userFields, *f.UserField)
+			}
+			if f.OrganizationField != nil {
+				a.orgFields = append(a.orgFields, *f.OrganizationField)
+			}
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading archive: %w", err)
+	}
+
+	err = readArchiveFile(dir, archiveUpdatesName, a.applyUpdate)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("loading archive updates: %w", err)
+	}
+
+	a.updates, err = newArchiveWriter(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// applyUpdate applies a saved change to the archive in memory. The caller must hold the write lock, or be
+// opening the archive.
+func (a *archiveSource) applyUpdate(u archiveUpdate) {
+	if u.User != nil {
+		if user, ok := a.users[int64(u.User.Id)]; ok {
+			// only the user fields are written, the same as the Zendesk API client
+			user.UserFields = u.User.UserFields
+		}
+	}
+
+	if u.Organization != nil {
+		if org, ok := a.orgs[u.Organization.Id]; ok {
+			org.Organization = *u.Organization
+		}
+	}
+
+	if u.UserField != nil {
+		a.userFields = append(a.userFields, *u.UserField)
+	}
+
+	if u.OrganizationField != nil {
+		a.orgFields = append(a.orgFields, *u.OrganizationField)
+	}
+}
+
+func (a *archiveSource) update(u archiveUpdate) error {
+	a.applyUpdate(u)
+	if a.updates == nil {
+		return nil
+	}
+
+	if err := a.updates.append(archiveUpdatesName, u); err != nil {
+		return fmt.Errorf("saving archive update: %w", err)
+	}
+
+	return nil
+}
+
+// archiveNotFound is returned for anything not in the archive, as the 404 the Zendesk API would return
+func archiveNotFound(kind string, id int64) error {
+	return fmt.Errorf("%s %d not in archive: %w", kind, id, zendesk.ApiErr{StatusCode: http.StatusNotFound, Status: "404 Not Found"})
+}
+
+func (a *archiveSource) ConnectionTest(ctx context.Context) error {
+	return nil
+}
+
+func (a *archiveSource) GetAgents(ctx context.Context) ([]zendesk.User, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var agents []zendesk.User
+	for _, u := range a.users {
+		if !u.Deleted && (u.Role == "admin" || u.Role == "agent") {
+			agents = append(agents, u.User)
+		}
+	}
+
+	sortUsers(agents)
+	return agents, nil
+}
+
+func (a *archiveSource) GetUserFieldByKey(ctx context.Context, key string) (*zendesk.UserField, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, field := range a.userFields {
+		if field.Key == key {
+			return &field, nil
+		}
+	}
+
+	return nil, fmt.Errorf("user field with key %s not found", key)
+}
+
+func (a *archiveSource) PostUserField(ctx context.Context, fieldType, key, title, description string) (*zendesk.UserField, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var id int64
+	for _, field := range a.userFields {
+		id = max(id, field.Id)
+	}
+
+	field := &zendesk.UserField{Id: id + 1, Type: fieldType, Key: key, Title: title, Description: description, Active: true}
+	if err := a.update(archiveUpdate{UserField: field}); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+func (a *archiveSource) GetOrgFieldByKey(ctx context.Context, key string) (*zendesk.OrganizationField, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, field := range a.orgFields {
+		if field.Key == key {
+			return &field, nil
+		}
+	}
+
+	return nil, fmt.Errorf("ticket field with key %s not found", key)
+}
+
+func (a *archiveSource) PostOrgField(ctx context.Context, fieldType, key, title, description string) (*zendesk.OrganizationField, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var id int64
+	for _, field := range a.orgFields {
+		id = max(id, field.Id)
+	}
+
+	field := &zendesk.OrganizationField{Id: id + 1, Type: fieldType, Key: key, Title: title, Description: description, Active: true}
+	if err := a.update(archiveUpdate{OrganizationField: field}); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+// GetOrganizationsWithQuery returns the orgs exported under all the tags in the query
+func (a *archiveSource) GetOrganizationsWithQuery(ctx context.Context, q zendesk.SearchQuery) ([]zendesk.Organization, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var orgs []zendesk.Organization
+	for _, org := range a.orgs {
+		matched := true
+		for _, tag := range q.Tags {
+			if !slices.Contains(org.Tags, tag) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			orgs = append(orgs, org.Organization)
+		}
+	}
+
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Id < orgs[j].Id })
+	return orgs, nil
+}
+
+func (a *archiveSource) GetOrganization(ctx context.Context, orgId int64) (zendesk.Organization, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	org, ok := a.orgs[orgId]
+	if !ok {
+		return zendesk.Organization{}, archiveNotFound("organization", orgId)
+	}
+
+	return org.Organization, nil
+}
+
+func (a *archiveSource) UpdateOrganization(ctx context.Context, org *zendesk.Organization) (*zendesk.Organization, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.orgs[org.Id]; !ok {
+		return nil, archiveNotFound("organization", org.Id)
+	}
+
+	if err := a.update(archiveUpdate{Organization: org}); err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+func (a *archiveSource) GetOrganizationUsers(ctx context.Context, orgId int64) ([]zendesk.User, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var users []zendesk.User
+	for _, u := range a.users {
+		if !u.Deleted && slices.Contains(u.OrganizationIds, orgId) {
+			users = append(users, u.User)
+		}
+	}
+
+	sortUsers(users)
+	return users, nil
+}
+
+func (a *archiveSource) GetOrganizationUserCount(ctx context.Context, orgId int64) (int, error) {
+	users, err := a.GetOrganizationUsers(ctx, orgId)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(users), nil
+}
+
+func (a *archiveSource) GetUser(ctx context.Context, userId int64) (*zendesk.User, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[userId]
+	if !ok || u.Deleted {
+		return nil, archiveNotFound("user", userId)
+	}
+
+	user := u.User
+	return &user, nil
+}
+
+func (a *archiveSource) GetDeletedUser(ctx context.Context, userId int64) (*zendesk.User, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[userId]
+	if !ok || !u.Deleted {
+		return nil, archiveNotFound("deleted user", userId)
+	}
+
+	user := u.User
+	user.Active = false
+	return &user, nil
+}
+
+func (a *archiveSource) UpdateUser(ctx context.Context, user *zendesk.User) (*zendesk.User, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u, ok := a.users[int64(user.Id)]
+	if !ok {
+		return nil, archiveNotFound("user", int64(user.Id))
+	}
+
+	if err := a.update(archiveUpdate{User: user}); err != nil {
+		return nil, err
+	}
+
+	updated := u.User
+	return &updated, nil
+}
+
+func (a *archiveSource) GetUserIdentities(ctx context.Context, userId int64) ([]zendesk.Identity, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[userId]
+	if !ok {
+		return nil, archiveNotFound("user", userId)
+	}
+
+	return slices.Clone(u.Identities), nil
+}
+
+// GetTicketsWithQuery filters the archived tickets the same way the Zendesk search does. Creation dates are
+// compared in UTC, and tickets have no tags in the archive, so the query's tags are ignored.
+func (a *archiveSource) GetTicketsWithQuery(ctx context.Context, q zendesk.SearchQuery, pageSize int, limit int) ([]zendesk.Ticket, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var tickets []zendesk.Ticket
+	for _, t := range a.tickets {
+		if ticketMatchesQuery(t, q) {
+			tickets = append(tickets, t)
+		}
+	}
+
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].Id < tickets[j].Id })
+	if limit > 0 && len(tickets) > limit {
+		tickets = tickets[:limit]
+	}
+
+	return tickets, nil
+}
+
+func (a *archiveSource) CountTicketsWithQuery(ctx context.Context, q zendesk.SearchQuery) (int, error) {
+	tickets, err := a.GetTicketsWithQuery(ctx, q, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(tickets), nil
+}
+
+func ticketMatchesQuery(t zendesk.Ticket, q zendesk.SearchQuery) bool {
+	if q.TicketsOrganizationId != 0 && t.OrganizationId != q.TicketsOrganizationId {
+		return false
+	}
+
+	created := t.CreatedAt.UTC().Format(archiveDateLayout)
+	if !q.TicketCreatedAfter.IsZero() && created <= q.TicketCreatedAfter.Format(archiveDateLayout) {
+		return false
+	}
+
+	if !q.TicketCreatedBefore.IsZero() && created >= q.TicketCreatedBefore.Format(archiveDateLayout) {
+		return false
+	}
+
+	if !q.GetOpenTickets && t.Status != "closed" && t.Status != "solved" {
+		return false
+	}
+
+	return true
+}
+
+func (a *archiveSource) GetTicket(ctx context.Context, ticketId int64) (zendesk.Ticket, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	t, ok := a.tickets[ticketId]
+	if !ok {
+		return zendesk.Ticket{}, archiveNotFound("ticket", ticketId)
+	}
+
+	return t, nil
+}
+
+func (a *archiveSource) GetAllTicketComments(ctx context.Context, ticketId int64) ([]zendesk.Comment, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if _, ok := a.tickets[ticketId]; !ok {
+		return nil, archiveNotFound("ticket", ticketId)
+	}
+
+	return slices.Clone(a.comments[ticketId]), nil
+}
+
+func sortUsers(users []zendesk.User) {
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+}
@@ -0,0 +1,425 @@
+package migration
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// cassetteHeaders are the only response headers kept in a cassette. Request headers, which hold the API
+// credentials, are never recorded.
+var cassetteHeaders = []string{"Content-Type", "Link", "Retry-After"}
+
+// structuralKeys are the JSON keys, in either API, whose string values are kept in a cassette because the utility
+// relies on them: IDs, statuses, types, roles, tags, custom field keys, patch operations and page cursors. Every
+// other string is replaced with a pseudonym, apart from dates and the URLs under urlKeys.
+var structuralKeys = map[string]bool{
+	"id":                true,
+	"type":              true,
+	"status":            true,
+	"role":              true,
+	"tags":              true,
+	"key":               true,
+	"op":                true,
+	"path":              true,
+	"channel":           true,
+	"locale":            true,
+	"result_type":       true,
+	"recordtype":        true,
+	"communicationtype": true,
+	"after_cursor":      true,
+	"before_cursor":     true,
+}
+
+// urlKeys are the JSON keys whose values are API links used to page through results. They're kept, with their
+// host and any redacted values in their query replaced.
+var urlKeys = map[string]bool{
+	"url":           true,
+	"next":          true,
+	"prev":          true,
+	"next_page":     true,
+	"previous_page": true,
+	"after_url":     true,
+	"before_url":    true,
+}
+
+// coordinateKeys are the JSON keys of numbers that locate a person, which are zeroed rather than kept like other
+// numbers
+var coordinateKeys = map[string]bool{
+	"latitude":  true,
+	"longitude": true,
+}
+
+// datePattern matches a date or timestamp, which is kept whatever its key
+var datePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?)?$`)
+
+// redactedPattern matches a value that is already redacted, so redacting is idempotent
+var redactedPattern = regexp.MustCompile(`^(redacted-[0-9a-f]{12}|r-[0-9a-f]{12}@redacted\.invalid|https://redacted\.invalid/[0-9a-f]{12})$`)
+
+// interaction is one request and its response in a cassette. The URL has no scheme or host, so a cassette
+// replays whatever subdomain or region the client is configured with.
+type interaction struct {
+	Method      string              `json:"method"`
+	Url         string              `json:"url"`
+	RequestBody json.RawMessage     `json:"request_body,omitempty"`
+	Status      int                 `json:"status"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        json.RawMessage     `json:"body,omitempty"`
+}
+
+// key identifies requests that should get the same response, whatever order their query parameters are in
+func (i interaction) key() string {
+	u, err := url.Parse(i.Url)
+	if err != nil {
+		return i.Method + " " + i.Url
+	}
+
+	q := u.Query()
+	params := make([]string, 0, len(q))
+	for k, v := range q {
+		params = append(params, k+"="+strings.Join(v, ","))
+	}
+	sort.Strings(params)
+
+	return i.Method + " " + u.Path + "?" + strings.Join(params, "&")
+}
+
+// redactor replaces personal data with pseudonyms. The same value always gets the same pseudonym, so users
+// still match contacts by email and URLs built from redacted values still match when replayed. Pseudonyms are
+// salted per recording, so they can't be reversed by hashing known emails.
+type redactor struct {
+	salt []byte
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newRedactor() *redactor {
+	salt := make([]byte, 16)
+	_, _ = rand.Read(salt)
+	return &redactor{salt: salt, values: make(map[string]string)}
+}
+
+func (r *redactor) pseudonym(v string) string {
+	if v == "" || redactedPattern.MatchString(v) {
+		return v
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.values[v]; ok {
+		return p
+	}
+
+	sum := sha256.Sum256(append(r.salt, v...))
+	h := hex.EncodeToString(sum[:])[:12]
+
+	var p string
+	switch {
+	case strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://"):
+		p = "https://redacted.invalid/" + h
+	case strings.Contains(v, "@"):
+		p = "r-" + h + "@redacted.invalid"
+	default:
+		p = "redacted-" + h
+	}
+
+	r.values[v] = p
+	return p
+}
+
+// redactJson redacts every string in a JSON body but the structural values the utility relies on. Bodies that aren't JSON are dropped entirely.
+func (r *redactor) redactJson(b []byte) json.RawMessage {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+
+	// numbers are kept as they are, so large IDs aren't rounded
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return json.RawMessage(`"[non-json body removed]"`)
+	}
+
+	out, err := json.Marshal(r.redactValue("", v))
+	if err != nil {
+		return nil
+	}
+
+	return out
+}
+
+func (r *redactor) redactValue(key string, v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			val[k] = r.redactValue(k, child)
+		}
+		return val
+	case []any:
+		for i, child := range val {
+			val[i] = r.redactValue(key, child)
+		}
+		return val
+	case string:
+		k := strings.ToLower(key)
+		switch {
+		case structuralKeys[k] || strings.HasSuffix(k, "_id"):
+			return r.redactEmails(val)
+		case urlKeys[k]:
+			// next page links can have searched values in their query
+			return r.redactUrl(val)
+		case datePattern.MatchString(val):
+			return val
+		default:
+			return r.pseudonym(val)
+		}
+	case json.Number:
+		if coordinateKeys[strings.ToLower(key)] {
+			return json.Number("0")
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redactUrl replaces any value already redacted in a body with its pseudonym in the query of a URL, raw or
+// escaped, e.g. an email in a contact search or a company name in a lookup. The host of an absolute URL, which
+// has the Zendesk subdomain, is replaced too.
+func (r *redactor) redactUrl(u string) string {
+	path, query, found := strings.Cut(u, "?")
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		if parsed, err := url.Parse(path); err == nil {
+			parsed.Scheme = "https"
+			parsed.Host = "redacted.invalid"
+			path = parsed.String()
+		}
+	}
+
+	if !found {
+		return path
+	}
+
+	r.mu.Lock()
+	for v, p := range r.values {
+		if len(v) < 3 {
+			continue
+		}
+
+		query = strings.ReplaceAll(query, v, p)
+		query = strings.ReplaceAll(query, url.QueryEscape(v), url.QueryEscape(p))
+		query = strings.ReplaceAll(query, url.PathEscape(v), url.PathEscape(p))
+	}
+	r.mu.Unlock()
+
+	// emails that weren't in any body yet are found in the unescaped query
+	if q, err := url.ParseQuery(query); err == nil {
+		var changed bool
+		for _, vals := range q {
+			for i, v := range vals {
+				if out := r.redactEmails(v); out != v {
+					vals[i] = out
+					changed = true
+				}
+			}
+		}
+
+		if changed {
+			query = q.Encode()
+		}
+	}
+
+	return path + "?" + query
+}
+
+// redactEmails replaces every email in a string with its pseudonym, so an email is never kept whatever key or
+// URL it's in
+func (r *redactor) redactEmails(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, r.pseudonym)
+}
+
+// recordingTransport sends requests with the next transport, and writes each request and response to a cassette
+type recordingTransport struct {
+	next     http.RoundTripper
+	redactor *redactor
+	writer   *archiveWriter
+	name     string
+}
+
+func newRecordingTransport(next http.RoundTripper, path string) (*recordingTransport, error) {
+	w, err := newArchiveWriter(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("creating cassette directory: %w", err)
+	}
+
+	return &recordingTransport{next: next, redactor: newRedactor(), writer: w, name: filepath.Base(path)}, nil
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("reading request body to record: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response body to record: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	// the response is redacted first, so values it introduces are known when the URL is redacted
+	i := interaction{
+		Status: res.StatusCode,
+		Body:   t.redactor.redactJson(resBody),
+		Header: make(map[string][]string),
+	}
+	i.RequestBody = t.redactor.redactJson(reqBody)
+	i.Method = req.Method
+	i.Url = t.redactor.redactUrl(req.URL.RequestURI())
+
+	for _, h := range cassetteHeaders {
+		if v := res.Header.Values(h); len(v) > 0 {
+			for _, hv := range v {
+				i.Header[h] = append(i.Header[h], t.redactor.redactUrl(hv))
+			}
+		}
+	}
+
+	if err := t.writer.append(t.name, i); err != nil {
+		slog.Error("recordingTransport: writing to cassette", "error", err)
+	}
+
+	return res, nil
+}
+
+func (t *recordingTransport) close() error {
+	return t.writer.close()
+}
+
+// replayTransport serves responses from a cassette instead of sending requests. A request gets the first unused
+// response recorded for the same request and body, then for the same request with any body, so concurrent
+// requests replay in any order. Once every matching response is used, the last one is repeated.
+type replayTransport struct {
+	redactor *redactor
+
+	mu    sync.Mutex
+	byKey map[string][]*replayed
+}
+
+type replayed struct {
+	interaction
+	used bool
+}
+
+func newReplayTransport(path string) (*replayTransport, error) {
+	t := &replayTransport{redactor: newRedactor(), byKey: make(map[string][]*replayed)}
+
+	var count int
+	err := readArchiveFile(filepath.Dir(path), filepath.Base(path), func(i interaction) {
+		t.byKey[i.key()] = append(t.byKey[i.key()], &replayed{interaction: i})
+		count++
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+
+	if count == 0 {
+		return nil, errors.New("cassette has no recorded requests")
+	}
+
+	slog.Info("loaded cassette for replay", "path", path, "requests", count)
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody json.RawMessage
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading request body to replay: %w", err)
+		}
+
+		// a replayed run only has redacted values, so this only normalizes the JSON
+		reqBody = t.redactor.redactJson(b)
+	}
+
+	key := interaction{Method: req.Method, Url: req.URL.RequestURI()}.key()
+	i := t.take(key, reqBody)
+	if i == nil {
+		slog.Warn("replayTransport: no recorded response", "method", req.Method, "url", req.URL.String())
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL.RequestURI())
+	}
+
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(i.Body)),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}
+
+	for h, v := range i.Header {
+		res.Header[h] = v
+	}
+
+	return res, nil
+}
+
+func (t *replayTransport) take(key string, body json.RawMessage) *interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recorded := t.byKey[key]
+	if len(recorded) == 0 {
+		return nil
+	}
+
+	for _, r := range recorded {
+		if !r.used && bytes.Equal(r.RequestBody, body) {
+			r.used = true
+			return &r.interaction
+		}
+	}
+
+	for _, r := range recorded {
+		if !r.used {
+			r.used = true
+			return &r.interaction
+		}
+	}
+
+	return &recorded[len(recorded)-1].interaction
+}
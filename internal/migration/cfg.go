@@ -11,9 +11,11 @@ import (
 	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
 	"github.com/spf13/viper"
 	"log/slog"
+	"maps"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -21,6 +23,7 @@ const (
 )
 
 var (
+	// CfgFile is a config file to use instead of the profile's config.json, set with --config
 	CfgFile string
 )
 
@@ -29,8 +32,34 @@ type Config struct {
 	Zendesk       ZendeskConfig           `mapstructure:"zendesk" json:"zendesk"`
 	Connectwise   ConnectwiseConfig       `mapstructure:"connectwise" json:"connectwise"`
 	AgentMappings map[string]AgentMapping `mapstructure:"agent_mappings" json:"agent_mappings"`
+	OrgSelection  OrgSelection            `mapstructure:"org_selection" json:"org_selection"`
+
+	Tuning `mapstructure:",squash"`
 
 	CliOptions
+
+	// fileCreds has the config file's value for each credential that was set from somewhere else
+	fileCreds map[string]string
+}
+
+// loggedConfig is a Config without its LogValue method, so LogValue can log a redacted copy
+type loggedConfig Config
+
+// LogValue logs the config with the API credentials redacted
+func (cfg Config) LogValue() slog.Value {
+	c := loggedConfig(cfg)
+	c.Zendesk.Creds.Token = redactIfSet(c.Zendesk.Creds.Token)
+	c.Connectwise.Creds.PublicKey = redactIfSet(c.Connectwise.Creds.PublicKey)
+	c.Connectwise.Creds.PrivateKey = redactIfSet(c.Connectwise.Creds.PrivateKey)
+	return slog.AnyValue(c)
+}
+
+func redactIfSet(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
 }
 
 type CliOptions struct {
@@ -41,6 +70,31 @@ type CliOptions struct {
 	StopAfterOrgs      bool
 	StopAfterUsers     bool
 	StopAtError        bool
+
+	// Archive is a directory written by the export command to read Zendesk data from, instead of the Zendesk API
+	Archive string
+
+	// ZendeskExport is a directory with the files of a Zendesk account export to read from, instead of the Zendesk API
+	ZendeskExport string
+
+	// Record is a cassette file to record every API request and response to, redacted, for reproducing issues
+	Record string
+
+	// Replay is a cassette file to serve API responses from, instead of the APIs
+	Replay string
+
+	// Profile is the name of the profile to use, which has its own config, logs, reports and archives. Empty
+	// means the default profile.
+	Profile string
+
+	// HashLogEmails replaces customer emails in the log with a hash of each one
+	HashLogEmails bool
+
+	// TuningOverrides holds the tuning values set with CLI flags
+	TuningOverrides Tuning
+
+	// OrgOverrides holds the orgs picked with CLI flags
+	OrgOverrides OrgSelection
 }
 
 type OutputLevels struct {
@@ -56,6 +110,10 @@ type ZendeskConfig struct {
 	FieldIds        ZendeskFieldIds `mapstructure:"field_ids" json:"field_ids"`
 	MasterStartDate string          `mapstructure:"start_date" json:"start_date"`
 	MasterEndDate   string          `mapstructure:"end_date" json:"end_date"`
+
+	// ExcludeInactiveUsers stops contacts being created for suspended or deleted Zendesk users - their tickets
+	// are skipped unless a matching contact already exists in ConnectWise PSA
+	ExcludeInactiveUsers bool `mapstructure:"exclude_inactive_users" json:"exclude_inactive_users"`
 }
 
 type TagDetails struct {
@@ -71,6 +129,10 @@ type ConnectwiseConfig struct {
 	TicketType         int                 `mapstructure:"ticket_type" json:"ticket_type"`
 	DestinationBoardId int                 `mapstructure:"destination_board_id" json:"destination_board_id"`
 	FieldIds           ConnectwiseFieldIds `mapstructure:"field_ids" json:"field_ids"`
+
+	// DuplicateContactPolicy decides which contact to use when more than one matches a Zendesk user's email -
+	// see duplicateContactPolicies for the options
+	DuplicateContactPolicy string `mapstructure:"duplicate_contact_policy" json:"duplicate_contact_policy"`
 }
 
 type ZendeskFieldIds struct {
@@ -125,6 +187,10 @@ func InitConfig(dir string) (*Config, error) {
 		return nil, fmt.Errorf("unmarshaling config: %w", err)
 	}
 
+	if err := cfg.loadCredSources(); err != nil {
+		return nil, fmt.Errorf("loading credentials: %w", err)
+	}
+
 	return cfg, nil
 }
 
@@ -141,25 +207,8 @@ func (cfg *Config) validatePreClient() error {
 		valid = false
 	}
 
-	if len(cfg.Zendesk.TagsToMigrate) == 0 {
-		slog.Warn("no zendesk tags to migrate set")
+	if !cfg.validateZendeskTags() {
 		valid = false
-
-		fmt.Println("\nNo Zendesk tags to migrate set in config - enter at least one")
-	} else {
-		for _, tag := range cfg.Zendesk.TagsToMigrate {
-			if tag.Name == "" {
-				slog.Warn("tag name is empty")
-				valid = false
-				fmt.Println("\nOne or more tag names are empty - please enter a name for each tag")
-				break
-			} else if tag.Name == exampleTag1.Name || tag.Name == exampleTag2.Name {
-				slog.Warn("example tags are present in config")
-				valid = false
-				fmt.Println("\nExample tags are present in config - replace them with your own tags")
-				break
-			}
-		}
 	}
 
 	if cfg.Connectwise.FieldIds.ZendeskTicketId == 0 {
@@ -177,6 +226,45 @@ func (cfg *Config) validatePreClient() error {
 
 	}
 
+	if !cfg.Tuning.validate() {
+		valid = false
+	}
+
+	if !validDuplicateContactPolicy(cfg.Connectwise.DuplicateContactPolicy) {
+		slog.Warn("invalid duplicate contact policy", "policy", cfg.Connectwise.DuplicateContactPolicy)
+		valid = false
+
+		fmt.Printf("\nInvalid ConnectWise duplicate contact policy in config - valid options are: %s\n", strings.Join(duplicateContactPolicyNames(), ", "))
+	}
+
+	if !valid {
+		return errors.New("one or more config values are invalid - see above")
+	}
+
+	return nil
+}
+
+// validateExportConfig validates the config values the export command needs, which only uses the Zendesk API
+func (cfg *Config) validateExportConfig() error {
+	valid := true
+	if err := checkRequiredCreds(cfg.zendeskCredFields()); err != nil {
+		slog.Error("missing required config values", "error", err)
+		valid = false
+	}
+
+	if err := cfg.validateZendeskDates(); err != nil {
+		slog.Error("zendesk master dates are invalid", "error", err)
+		valid = false
+	}
+
+	if !cfg.validateZendeskTags() {
+		valid = false
+	}
+
+	if !cfg.Tuning.validate() {
+		valid = false
+	}
+
 	if !valid {
 		return errors.New("one or more config values are invalid - see above")
 	}
@@ -184,6 +272,28 @@ func (cfg *Config) validatePreClient() error {
 	return nil
 }
 
+func (cfg *Config) validateZendeskTags() bool {
+	if len(cfg.Zendesk.TagsToMigrate) == 0 {
+		slog.Warn("no zendesk tags to migrate set")
+		fmt.Println("\nNo Zendesk tags to migrate set in config - enter at least one")
+		return false
+	}
+
+	for _, tag := range cfg.Zendesk.TagsToMigrate {
+		if tag.Name == "" {
+			slog.Warn("tag name is empty")
+			fmt.Println("\nOne or more tag names are empty - please enter a name for each tag")
+			return false
+		} else if tag.Name == exampleTag1.Name || tag.Name == exampleTag2.Name {
+			slog.Warn("example tags are present in config")
+			fmt.Println("\nExample tags are present in config - replace them with your own tags")
+			return false
+		}
+	}
+
+	return true
+}
+
 // validatePostClient runs after the Client has been created, since we need valid API connections
 // to validate these fields
 func (c *Client) validatePostClient(ctx context.Context) error {
@@ -200,7 +310,10 @@ func (c *Client) validatePostClient(ctx context.Context) error {
 		return fmt.Errorf("processing agent mappings: %w", err)
 	}
 
-	if err := c.Cfg.validateZendeskCustomFields(); err != nil {
+	// an account export can't be written to, so the links to PSA contacts and companies aren't saved in Zendesk
+	if c.Cfg.ZendeskExport != "" {
+		slog.Info("reading from a zendesk export - skipping zendesk custom fields")
+	} else if err := c.Cfg.validateZendeskCustomFields(); err != nil {
 		proceed, err := confirmProcessZendeskFields()
 		if err != nil {
 			return err
@@ -240,19 +353,39 @@ func (c *Client) validatePostClient(ctx context.Context) error {
 }
 
 func (cfg *Config) validateCreds() error {
-	slog.Debug("validating required fields")
-	var missing []string
+	// a replayed run never reaches the APIs
+	if cfg.Replay != "" {
+		slog.Info("replaying a cassette - skipping api credentials")
+		return nil
+	}
 
 	requiredFields := map[string]string{
-		"Zendesk API Token":           cfg.Zendesk.Creds.Token,
-		"Zendesk API Username":        cfg.Zendesk.Creds.Username,
-		"Zendesk API Subdomain":       cfg.Zendesk.Creds.Subdomain,
 		"ConnectWise API Company ID":  cfg.Connectwise.Creds.CompanyId,
 		"ConnectWise API Public Key":  cfg.Connectwise.Creds.PublicKey,
 		"ConnectWise API Private Key": cfg.Connectwise.Creds.PrivateKey,
 		"ConnectWise API client ID":   cfg.Connectwise.Creds.ClientId,
 	}
 
+	// the Zendesk API isn't used when reading from an archive or account export
+	if cfg.Archive == "" && cfg.ZendeskExport == "" {
+		maps.Copy(requiredFields, cfg.zendeskCredFields())
+	}
+
+	return checkRequiredCreds(requiredFields)
+}
+
+func (cfg *Config) zendeskCredFields() map[string]string {
+	return map[string]string{
+		"Zendesk API Token":     cfg.Zendesk.Creds.Token,
+		"Zendesk API Username":  cfg.Zendesk.Creds.Username,
+		"Zendesk API Subdomain": cfg.Zendesk.Creds.Subdomain,
+	}
+}
+
+func checkRequiredCreds(requiredFields map[string]string) error {
+	slog.Debug("validating required fields")
+	var missing []string
+
 	for k, v := range requiredFields {
 		if v == "" {
 			slog.Warn("missing required config value", "key", k)
@@ -381,7 +514,7 @@ func (c *Client) processAgentMappings(ctx context.Context) error {
 	}
 
 	viper.Set("agent_mappings", c.Cfg.AgentMappings)
-	if err := viper.WriteConfig(); err != nil {
+	if err := c.Cfg.writeConfig(); err != nil {
 		return fmt.Errorf("writing agent mappings to config file: %w", err)
 	}
 
@@ -430,7 +563,7 @@ func (c *Client) processZendeskPsaFields(ctx context.Context) error {
 	viper.Set("zendesk.field_ids.psa_contact_id", uf.Id)
 	viper.Set("zendesk.field_ids.psa_company_id", cf.Id)
 
-	if err := viper.WriteConfig(); err != nil {
+	if err := c.Cfg.writeConfig(); err != nil {
 		return fmt.Errorf("writing config file: %w", err)
 	}
 	return nil
@@ -484,7 +617,7 @@ func (c *Client) runBoardForm(ctx context.Context) error {
 	c.Cfg.Connectwise.DestinationBoardId = boardsMap[s]
 
 	viper.Set("connectwise.destination_board_id", boardsMap[s])
-	if err := viper.WriteConfig(); err != nil {
+	if err := c.Cfg.writeConfig(); err != nil {
 		return fmt.Errorf("writing config file: %w", err)
 	}
 
@@ -541,7 +674,7 @@ func (c *Client) runTicketTypeForm(ctx context.Context, boardId int) error {
 	c.Cfg.Connectwise.TicketType = typeMap[tp]
 
 	viper.Set("connectwise.ticket_type", typeMap[tp])
-	if err := viper.WriteConfig(); err != nil {
+	if err := c.Cfg.writeConfig(); err != nil {
 		return fmt.Errorf("writing config file: %w", err)
 	}
 
@@ -609,7 +742,7 @@ func (c *Client) runBoardStatusForm(ctx context.Context, boardId int) error {
 
 	viper.Set("connectwise.open_status_id", statusMap[op])
 	viper.Set("connectwise.closed_status_id", statusMap[cl])
-	if err := viper.WriteConfig(); err != nil {
+	if err := c.Cfg.writeConfig(); err != nil {
 		return fmt.Errorf("writing config file: %w", err)
 	}
 
@@ -655,10 +788,17 @@ func setCfgDefaults() {
 	slog.Debug("setting config defaults")
 	viper.SetDefault("ticket_limit", 0)
 	viper.SetDefault("migrate_open_tickets", false)
+	tuning := defaultTuning()
+	viper.SetDefault("ticket_workers", tuning.TicketWorkers)
+	viper.SetDefault("user_workers", tuning.UserWorkers)
+	viper.SetDefault("user_batch_size", tuning.UserBatchSize)
+	viper.SetDefault("zendesk_page_size", tuning.ZendeskPageSize)
+	viper.SetDefault("psa_page_size", tuning.PsaPageSize)
 	viper.SetDefault("time_zone", "America/Chicago")
 	viper.SetDefault("zendesk", ZendeskConfig{TagsToMigrate: []TagDetails{exampleTag1, exampleTag2}})
-	viper.SetDefault("connectwise", ConnectwiseConfig{})
+	viper.SetDefault("connectwise", ConnectwiseConfig{DuplicateContactPolicy: string(duplicatePolicyError)})
 	viper.SetDefault("output_levels", defaultOutputLevels)
+	viper.SetDefault("org_selection", OrgSelection{Orgs: []string{}, OrgIds: []int64{}, ExcludeOrgs: []string{}})
 }
 
 func runConfigSpinner(title string, action func(context.Context) error) error {
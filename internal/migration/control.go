@@ -0,0 +1,173 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"log/slog"
+	"sync"
+)
+
+// dispatchGate controls whether new tickets are sent out to be migrated. Pausing or stopping it never touches
+// tickets that are already in flight - they always run to completion. The zero value is open.
+type dispatchGate struct {
+	mu      sync.Mutex
+	paused  bool
+	stopped bool
+	resume  chan struct{}
+}
+
+func (g *dispatchGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused || g.stopped {
+		return
+	}
+
+	g.paused = true
+	g.resume = make(chan struct{})
+}
+
+func (g *dispatchGate) unpause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		return
+	}
+
+	g.paused = false
+	close(g.resume)
+}
+
+// stop permanently closes the gate, releasing anything waiting on a pause
+func (g *dispatchGate) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stopped {
+		return
+	}
+
+	g.stopped = true
+	if g.paused {
+		g.paused = false
+		close(g.resume)
+	}
+}
+
+func (g *dispatchGate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+func (g *dispatchGate) isStopped() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stopped
+}
+
+// wait blocks while the gate is paused, and reports whether dispatching can continue
+func (g *dispatchGate) wait(ctx context.Context) bool {
+	for {
+		g.mu.Lock()
+		stopped, paused, resume := g.stopped, g.paused, g.resume
+		g.mu.Unlock()
+
+		if stopped || ctx.Err() != nil {
+			return false
+		}
+
+		if !paused {
+			return true
+		}
+
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+type quitChoice string
+
+const (
+	quitFinishInFlight quitChoice = "finish"
+	quitAbortNow       quitChoice = "abort"
+	quitKeepRunning    quitChoice = "keep"
+)
+
+// migrationRunning reports whether the migration is past org selection and still making changes, where
+// quitting immediately could leave half-built tickets behind
+func (m *Model) migrationRunning() bool {
+	switch m.status {
+	case gettingUsers, migratingUsers, gettingPsaTickets, migratingTickets:
+		return true
+	}
+
+	return false
+}
+
+func (m *Model) quitForm() *huh.Form {
+	m.quitChoice = quitFinishInFlight
+	return huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[quitChoice]().
+				Title("A migration is in progress").
+				Description("Exiting now may leave incomplete tickets in ConnectWise PSA").
+				Options(
+					huh.NewOption("Finish in-flight tickets, then exit", quitFinishInFlight),
+					huh.NewOption("Abort now", quitAbortNow),
+					huh.NewOption("Keep migrating", quitKeepRunning),
+				).
+				Value(&m.quitChoice),
+		),
+	).WithHeight(m.verticalLeftForMainView).WithShowHelp(false).WithTheme(customFormTheme())
+}
+
+// handleQuitChoice acts on the answer to the quit prompt
+func (m *Model) handleQuitChoice() tea.Cmd {
+	switch m.quitChoice {
+	case quitFinishInFlight:
+		slog.Info("quit requested: finishing in-flight tickets before exiting")
+		m.writeToOutput(warnYellowOutput("STOPPING", "no new tickets will be started - exiting once in-flight tickets are complete"), warnOutput)
+		m.quitAfterFinish = true
+		m.dispatch.stop()
+		if m.status == done || m.status == errored {
+			return m.quit()
+		}
+
+	case quitAbortNow:
+		slog.Warn("quit requested: aborting migration")
+		return m.quit()
+
+	default:
+		slog.Debug("quit cancelled - continuing migration")
+	}
+
+	return nil
+}
+
+// quit cancels anything still running and exits the program
+func (m *Model) quit() tea.Cmd {
+	m.quitting = true
+	m.cancel()
+	m.finishRun()
+	return tea.Quit
+}
+
+func (m *Model) pauseStatus() string {
+	if m.dispatch.isStopped() {
+		return fmt.Sprintf("Stopping - waiting for %d in-flight tickets to finish", m.ticketsInFlight.get())
+	}
+
+	if m.dispatch.isPaused() {
+		return fmt.Sprintf("Paused - %d in-flight tickets finishing. Press %s to resume.", m.ticketsInFlight.get(), textBlue("R"))
+	}
+
+	return ""
+}
@@ -0,0 +1,262 @@
+package migration
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// throughputWindow is how far back the dashboard looks to work out tickets and notes per minute, so the rates and
+	// ETA follow the current pace rather than the average since the start
+	throughputWindow = time.Minute
+
+	dashboardTickInterval = time.Second
+
+	// maxDashboardOrgName is the longest org name shown in the dashboard's org table before it's cut short
+	maxDashboardOrgName = 30
+)
+
+var dashboardLabelStyle = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "236", Dark: "248"})
+
+type dashboardTickMsg time.Time
+
+// dashboardTick samples the statistics for the dashboard once a second
+func dashboardTick() tea.Cmd {
+	return tea.Tick(dashboardTickInterval, func(t time.Time) tea.Msg {
+		return dashboardTickMsg(t)
+	})
+}
+
+// throughputSample is the tickets processed and notes created at a point in time
+type throughputSample struct {
+	at      time.Time
+	tickets int
+	notes   int
+}
+
+// throughput keeps the samples taken within the throughput window. It's only used from Update and View, so it
+// doesn't need a lock.
+type throughput struct {
+	samples []throughputSample
+}
+
+func (t *throughput) add(s throughputSample) {
+	t.samples = append(t.samples, s)
+
+	cutoff := s.at.Add(-throughputWindow)
+	i := 0
+	for i < len(t.samples)-2 && !t.samples[i+1].at.After(cutoff) {
+		i++
+	}
+	t.samples = t.samples[i:]
+}
+
+func (t *throughput) reset() {
+	t.samples = nil
+}
+
+// perMinute returns the tickets and notes per minute between the oldest and newest samples, or 0 for both until
+// there are two samples to compare
+func (t *throughput) perMinute() (float64, float64) {
+	if len(t.samples) < 2 {
+		return 0, 0
+	}
+
+	first, last := t.samples[0], t.samples[len(t.samples)-1]
+	minutes := last.at.Sub(first.at).Minutes()
+	if minutes <= 0 {
+		return 0, 0
+	}
+
+	return float64(last.tickets-first.tickets) / minutes, float64(last.notes-first.notes) / minutes
+}
+
+// eta estimates how long the remaining tickets will take at the current rate. It's false if there's no rate yet.
+func eta(remaining int, ticketsPerMinute float64) (time.Duration, bool) {
+	if ticketsPerMinute <= 0 {
+		return 0, false
+	}
+
+	d := time.Duration(float64(remaining) / ticketsPerMinute * float64(time.Minute))
+	return d.Round(time.Second), true
+}
+
+// dashboardView shows the run's counters beside the live metrics: throughput and ETA, worker use, API calls by host
+// and, while tickets are migrating, a progress table of the orgs in the migration
+func (m *Model) dashboardView(height int) string {
+	counters := fmt.Sprintf("Users Processed: %d\n"+
+		"New Users Created: %d\n"+
+		"Tickets Processed: %d\n"+
+		"New Tickets Created: %d\n"+
+		"Notes Created: %d\n"+
+		"Orgs Complete: %d/%d\n"+
+		"Orgs Not in PSA: %d\n"+
+		"User Migration Errors: %d\n"+
+		"Ticket Migration Errors: %d",
+		m.usersProcessed.get(),
+		m.newUsersCreated.get(),
+		m.ticketsProcessed.get(),
+		m.newTicketsCreated.get(),
+		m.notesCreated.get(),
+		m.ticketOrgsProcessed.get(), len(m.data.SelectedOrgs),
+		m.orgsNotInPsa.get(),
+		m.userMigrationErrors.get(),
+		m.ticketMigrationErrors.get())
+
+	panel := m.metricsView()
+	if m.status == migratingTickets {
+		if orgs := m.orgProgressTable(height - lipgloss.Height(panel) - 1); orgs != "" {
+			panel += "\n\n" + orgs
+		}
+	}
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, counters, "    ", panel)
+}
+
+// metricsView shows the rates, ETA, worker use and API calls
+func (m *Model) metricsView() string {
+	ticketsPerMin, notesPerMin := m.throughput.perMinute()
+
+	remaining, loading := m.ticketsRemaining()
+	etaText := "calculating"
+	if d, ok := eta(remaining, ticketsPerMin); ok {
+		etaText = d.String()
+	}
+	if loading > 0 {
+		etaText += fmt.Sprintf(" (%d orgs still loading)", loading)
+	}
+
+	workers := m.client.Cfg.TicketWorkers
+	busy := m.ticketsInFlight.get()
+	var utilization float64
+	if workers > 0 {
+		utilization = float64(busy) / float64(workers) * 100
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s %.1f tickets/min, %.1f notes/min", dashboardLabelStyle.Render("Throughput:"), ticketsPerMin, notesPerMin),
+		fmt.Sprintf("%s %s", dashboardLabelStyle.Render("ETA:"), etaText),
+		fmt.Sprintf("%s %d/%d busy (%.0f%%)", dashboardLabelStyle.Render("Ticket Workers:"), busy, workers, utilization),
+	}
+
+	hosts := m.client.metrics.snapshot()
+	if len(hosts) == 0 {
+		lines = append(lines, dashboardLabelStyle.Render("API Calls:")+" none yet")
+	} else {
+		lines = append(lines, dashboardLabelStyle.Render("API Calls:"))
+	}
+
+	for _, h := range hosts {
+		line := fmt.Sprintf("  %s: %d", h.Host, h.Calls)
+		if h.RateLimited > 0 {
+			line += " " + textYellow(fmt.Sprintf("(%d rate limited)", h.RateLimited))
+		} else {
+			line += " (0 rate limited)"
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ticketsRemaining returns the tickets left to migrate in orgs whose tickets have been fetched, and how many orgs
+// are still being fetched, since their tickets aren't known yet
+func (m *Model) ticketsRemaining() (int, int) {
+	p := m.ticketProgress.Load()
+	if p == nil {
+		return 0, 0
+	}
+
+	var remaining, loading int
+	for _, o := range *p {
+		switch o.getStatus() {
+		case ticketStatusGetting:
+			loading++
+		case ticketStatusMigrating:
+			remaining += o.remaining.get()
+		}
+	}
+
+	return remaining, loading
+}
+
+// orgProgressTable shows each org's ticket progress, with the orgs being migrated first, then those still being
+// fetched, then those done. Orgs that don't fit in the height are summarized in the last line.
+func (m *Model) orgProgressTable(height int) string {
+	p := m.ticketProgress.Load()
+	if p == nil || len(*p) == 0 {
+		return ""
+	}
+
+	order := map[ticketStatus]int{ticketStatusMigrating: 0, ticketStatusGetting: 1, ticketStatusDone: 2}
+	orgs := make([]*orgTicketMigration, len(*p))
+	copy(orgs, *p)
+	sort.SliceStable(orgs, func(i, j int) bool {
+		return order[orgs[i].getStatus()] < order[orgs[j].getStatus()]
+	})
+
+	// the header and its border take 2 lines, and the summary line 1
+	rows := height - 3
+	if rows < 1 {
+		return ""
+	}
+
+	var more int
+	if len(orgs) > rows {
+		more = len(orgs) - rows
+		orgs = orgs[:rows]
+	}
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderTop(false).
+		BorderBottom(false).
+		BorderLeft(false).
+		BorderRight(false).
+		BorderColumn(false).
+		Headers("Org", "Status", "Tickets", "Progress").
+		Wrap(false).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return pickerHeaderStyle
+			}
+			return pickerCellStyle
+		})
+
+	for _, o := range orgs {
+		name := o.org.ZendeskOrg.Name
+		if r := []rune(name); len(r) > maxDashboardOrgName {
+			name = string(r[:maxDashboardOrgName-1]) + "…"
+		}
+
+		status, tickets, progress := "getting tickets", "", ""
+		switch o.getStatus() {
+		case ticketStatusMigrating, ticketStatusDone:
+			done, total := o.ticketsProcessed.get(), o.ticketsToProcess.get()
+			status = "migrating"
+			if o.getStatus() == ticketStatusDone {
+				status = "done"
+			}
+
+			tickets = fmt.Sprintf("%d/%d", done, total)
+			progress = "100%"
+			if total > 0 {
+				progress = fmt.Sprintf("%.0f%%", float64(done)/float64(total)*100)
+			}
+		}
+
+		t = t.Row(name, status, tickets, progress)
+	}
+
+	s := t.Render()
+	if more > 0 {
+		s += fmt.Sprintf("\n...and %d more orgs", more)
+	}
+
+	return s
+}
@@ -3,31 +3,45 @@ package migration
 import (
 	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
 	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
-	"strings"
+	"sync"
 	"time"
 )
 
+// Data holds the state of the migration. The maps are read and written by many goroutines at once, so they are
+// all safeMaps.
 type Data struct {
-	AllOrgs       map[string]*orgMigrationDetails
-	UsersInPsa    map[string]*userMigrationDetails
-	ExternalUsers map[string]*zendesk.User
-	TicketsInPsa  map[string]int
+	AllOrgs       *safeMap[*orgMigrationDetails]
+	UsersInPsa    *safeMap[*userMigrationDetails]
+	ExternalUsers *safeMap[*zendesk.User]
+	TicketsInPsa  *safeMap[int]
+
+	// RequestersNotInPsa holds why each ticket requester outside the selected orgs couldn't be linked to a PSA
+	// contact, keyed by Zendesk user ID, so each one is only looked up once per run
+	RequestersNotInPsa *safeMap[string]
+
+	// ContactsByEmail holds every contact matched or created during the run, keyed by lowercase email
+	ContactsByEmail *safeMap[*psa.Contact]
 
 	PsaInfo        PsaInfo
 	Tags           []tagDetails
 	SelectedOrgs   []*orgMigrationDetails
-	UsersToMigrate map[string]*userMigrationDetails
+	UsersToMigrate *safeMap[*userMigrationDetails]
+
+	DuplicateContacts []duplicateContactRecord
 
-	Output strings.Builder
+	Output outputBuffer
 }
 
 func (c *Client) newData() *Data {
 	return &Data{
-		AllOrgs:        make(map[string]*orgMigrationDetails),
-		UsersInPsa:     make(map[string]*userMigrationDetails),
-		ExternalUsers:  make(map[string]*zendesk.User),
-		TicketsInPsa:   make(map[string]int),
-		UsersToMigrate: make(map[string]*userMigrationDetails),
+		AllOrgs:       newSafeMap[*orgMigrationDetails](),
+		UsersInPsa:    newSafeMap[*userMigrationDetails](),
+		ExternalUsers: newSafeMap[*zendesk.User](),
+		TicketsInPsa:  newSafeMap[int](),
+
+		RequestersNotInPsa: newSafeMap[string](),
+		ContactsByEmail:    newSafeMap[*psa.Contact](),
+		UsersToMigrate:     newSafeMap[*userMigrationDetails](),
 
 		PsaInfo: PsaInfo{
 			Board:                  &psa.Board{Id: c.Cfg.Connectwise.DestinationBoardId},
@@ -39,6 +53,59 @@ func (c *Client) newData() *Data {
 	}
 }
 
+// safeMap is a string keyed map that is safe for concurrent use
+type safeMap[V any] struct {
+	mu sync.RWMutex
+	m  map[string]V
+}
+
+func newSafeMap[V any]() *safeMap[V] {
+	return &safeMap[V]{m: make(map[string]V)}
+}
+
+func (s *safeMap[V]) load(key string) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[key]
+	return v, ok
+}
+
+func (s *safeMap[V]) store(key string, v V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = v
+}
+
+// loadOrStore returns the existing value for the key if there is one, otherwise it stores and returns v
+func (s *safeMap[V]) loadOrStore(key string, v V) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.m[key]; ok {
+		return existing, true
+	}
+
+	s.m[key] = v
+	return v, false
+}
+
+func (s *safeMap[V]) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.m)
+}
+
+// snapshot returns a copy of the map, so it can be ranged over while other goroutines write to the original
+func (s *safeMap[V]) snapshot() map[string]V {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c := make(map[string]V, len(s.m))
+	for k, v := range s.m {
+		c[k] = v
+	}
+
+	return c
+}
+
 type orgMigrationDetails struct {
 	ZendeskOrg *zendesk.Organization `json:"zendesk_org"`
 	PsaOrg     *psa.Company          `json:"psa_org"`
@@ -49,6 +116,16 @@ type orgMigrationDetails struct {
 
 	TicketsAlreadyInPSA int
 	MigrationSelected   bool `json:"migration_selected"`
+
+	// ZendeskTickets are counted when the org is checked. ZendeskUsers and PsaTickets are only counted for the
+	// org picker, when it's shown. PsaTickets are the matched company's tickets migrated from any Zendesk org. A
+	// count that couldn't be found is -1.
+	ZendeskTickets int `json:"zendesk_tickets"`
+	ZendeskUsers   int `json:"zendesk_users"`
+	PsaTickets     int `json:"psa_tickets"`
+
+	// NotReadyReason is why the org can't be migrated, shown in the org picker
+	NotReadyReason string `json:"not_ready_reason,omitempty"`
 }
 
 type tagDetails struct {
@@ -61,6 +138,9 @@ type userMigrationDetails struct {
 	ZendeskUser  *zendesk.User `json:"zendesk_user"`
 	PsaContact   *psa.Contact  `json:"psa_contact"`
 	PsaCompany   *psa.Company
+	Org          *orgMigrationDetails
+	Identities   []zendesk.Identity
+	Deleted      bool
 	UserMigrated bool `json:"migrated"`
 
 	HasTickets bool `json:"has_tickets"`
@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"context"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+)
+
+// Destination is everything the migration reads from and writes to the PSA being migrated to. The ConnectWise
+// PSA client is the implementation; another PSA can be added by implementing it, converting to and from the psa
+// package types.
+type Destination interface {
+	ConnectionTest(ctx context.Context) error
+
+	// Members are matched to Zendesk agents by email
+	GetMembers(ctx context.Context) ([]psa.Member, error)
+
+	// Companies
+	GetCompanyByName(ctx context.Context, name string) (*psa.Company, error)
+
+	// Contacts. GetContactByEmail returns a psa.NoUserFoundErr if no contact matches, or a
+	// psa.MultipleContactsErr if more than one does.
+	GetContactByEmail(ctx context.Context, emails ...string) (*psa.Contact, error)
+	PostContact(ctx context.Context, payload *psa.ContactPostBody) (*psa.Contact, error)
+	PostContactNote(ctx context.Context, contactId int, note *psa.ContactNote) error
+
+	// Tickets and notes. Migrated tickets are those with a Zendesk ticket ID in the given custom field.
+	GetMigratedTickets(ctx context.Context, zendeskIdFieldId int, pageSize int) ([]psa.Ticket, error)
+	CountMigratedTickets(ctx context.Context, zendeskIdFieldId int, companyId int) (int, error)
+	PostTicket(ctx context.Context, ticket *psa.Ticket) (*psa.Ticket, error)
+	UpdateTicketStatus(ctx context.Context, ticket *psa.Ticket, newStatusId int) error
+	PostTicketNote(ctx context.Context, ticketId int, note *psa.TicketNote) error
+	GetTicketNoteCount(ctx context.Context, ticketId int) (int, error)
+
+	// Boards and statuses, for choosing where tickets go
+	GetBoards(ctx context.Context) ([]psa.Board, error)
+	GetBoardTypes(ctx context.Context, boardId int) ([]psa.BoardType, error)
+	GetBoardStatuses(ctx context.Context, boardId int) ([]psa.Status, error)
+}
+
+var _ Destination = (*psa.Client)(nil)
@@ -0,0 +1,294 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+type duplicateContactPolicy string
+
+const (
+	duplicatePolicyError         duplicateContactPolicy = "error"
+	duplicatePolicyTargetCompany duplicateContactPolicy = "target_company"
+	duplicatePolicyActive        duplicateContactPolicy = "active"
+	duplicatePolicyRecent        duplicateContactPolicy = "recent"
+	duplicatePolicyPrompt        duplicateContactPolicy = "prompt"
+)
+
+var duplicateContactPolicies = []duplicateContactPolicy{
+	duplicatePolicyError,
+	duplicatePolicyTargetCompany,
+	duplicatePolicyActive,
+	duplicatePolicyRecent,
+	duplicatePolicyPrompt,
+}
+
+type duplicateContactRecord struct {
+	ZendeskUserId int    `json:"zendesk_user_id"`
+	UserName      string `json:"user_name"`
+	Email         string `json:"email"`
+	ContactIds    []int  `json:"psa_contact_ids"`
+	ChosenId      int    `json:"chosen_psa_contact_id"`
+	Resolution    string `json:"resolution"`
+}
+
+// contactPrompt is sent to the TUI when the duplicate contact policy is "prompt", and the migrating goroutine
+// waits on response for the chosen contact ID (0 to skip the user).
+type contactPrompt struct {
+	user     *userMigrationDetails
+	contacts []psa.Contact
+	response chan int
+}
+
+type contactPromptMsg *contactPrompt
+
+// errContactSkipped is returned when "Skip this user" is chosen at the duplicate contact prompt, so the user is
+// skipped rather than failed
+var errContactSkipped = errors.New("skipped at the duplicate contact prompt")
+
+type DuplicateContactsErr struct {
+	Count int
+}
+
+func (e DuplicateContactsErr) Error() string {
+	return fmt.Sprintf("%d contacts match this user and the duplicate contact policy couldn't choose one", e.Count)
+}
+
+func validDuplicateContactPolicy(p string) bool {
+	if p == "" {
+		return true
+	}
+
+	for _, policy := range duplicateContactPolicies {
+		if duplicateContactPolicy(p) == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+func duplicateContactPolicyNames() []string {
+	var names []string
+	for _, policy := range duplicateContactPolicies {
+		names = append(names, string(policy))
+	}
+
+	return names
+}
+
+// resolveDuplicateContacts picks one of several contacts matching a user based on the configured policy. Every
+// duplicate is recorded for the end of run report, whether it was resolved or not.
+func (m *Model) resolveDuplicateContacts(user *userMigrationDetails, contacts []psa.Contact) (*psa.Contact, error) {
+	policy := duplicateContactPolicy(m.client.Cfg.Connectwise.DuplicateContactPolicy)
+	if policy == "" {
+		policy = duplicatePolicyError
+	}
+
+	slog.Info("resolveDuplicateContacts: multiple contacts match user", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "count", len(contacts), "policy", policy)
+
+	var chosen *psa.Contact
+	var promptErr error
+	switch policy {
+	case duplicatePolicyTargetCompany:
+		var inCompany []psa.Contact
+		for _, c := range contacts {
+			if c.Company != nil && user.PsaCompany != nil && c.Company.Id == user.PsaCompany.Id {
+				inCompany = append(inCompany, c)
+			}
+		}
+		chosen = mostRecentContact(inCompany)
+
+	case duplicatePolicyActive:
+		var active []psa.Contact
+		for _, c := range contacts {
+			if !c.InactiveFlag {
+				active = append(active, c)
+			}
+		}
+
+		if len(active) == 0 {
+			active = contacts
+		}
+		chosen = mostRecentContact(active)
+
+	case duplicatePolicyRecent:
+		chosen = mostRecentContact(contacts)
+
+	case duplicatePolicyPrompt:
+		var id int
+		id, promptErr = m.promptForContact(user, contacts)
+		for _, c := range contacts {
+			if c.Id == id {
+				chosen = &c
+				break
+			}
+		}
+	}
+
+	record := duplicateContactRecord{
+		ZendeskUserId: user.ZendeskUser.Id,
+		UserName:      user.ZendeskUser.Name,
+		Email:         user.ZendeskUser.Email,
+		Resolution:    fmt.Sprintf("unresolved (policy: %s)", policy),
+	}
+
+	for _, c := range contacts {
+		record.ContactIds = append(record.ContactIds, c.Id)
+	}
+
+	if chosen != nil {
+		record.ChosenId = chosen.Id
+		record.Resolution = fmt.Sprintf("used contact %d (policy: %s)", chosen.Id, policy)
+	}
+
+	skipped := policy == duplicatePolicyPrompt && promptErr == nil && chosen == nil
+	switch {
+	case promptErr != nil:
+		record.Resolution = fmt.Sprintf("unresolved (policy: %s): %s", policy, promptErr)
+	case skipped:
+		record.Resolution = fmt.Sprintf("user skipped (policy: %s)", policy)
+	}
+
+	m.mu.Lock()
+	m.data.DuplicateContacts = append(m.data.DuplicateContacts, record)
+	m.mu.Unlock()
+
+	if promptErr != nil {
+		return nil, promptErr
+	}
+
+	if skipped {
+		return nil, errContactSkipped
+	}
+
+	if chosen == nil {
+		return nil, DuplicateContactsErr{Count: len(contacts)}
+	}
+
+	slog.Info("resolveDuplicateContacts: chose contact", "userEmail", user.ZendeskUser.Email, "psaContactId", chosen.Id, "policy", policy)
+	return chosen, nil
+}
+
+func mostRecentContact(contacts []psa.Contact) *psa.Contact {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	sorted := make([]psa.Contact, len(contacts))
+	copy(sorted, contacts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return contactLastUpdated(sorted[i]).After(contactLastUpdated(sorted[j]))
+	})
+
+	return &sorted[0]
+}
+
+func contactLastUpdated(c psa.Contact) (t time.Time) {
+	if c.Info != nil {
+		return c.Info.LastUpdated
+	}
+
+	return t
+}
+
+// promptForContact hands the choice to the TUI and waits for the answer
+func (m *Model) promptForContact(user *userMigrationDetails, contacts []psa.Contact) (int, error) {
+	if m.contactPrompts == nil {
+		return 0, errors.New("duplicate contact prompt is not available in this mode")
+	}
+
+	p := &contactPrompt{user: user, contacts: contacts, response: make(chan int, 1)}
+	select {
+	case m.contactPrompts <- p:
+	case <-m.ctx.Done():
+		return 0, m.ctx.Err()
+	}
+
+	select {
+	case id := <-p.response:
+		return id, nil
+	case <-m.ctx.Done():
+		return 0, m.ctx.Err()
+	}
+}
+
+func (m *Model) waitForContactPrompt() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case p := <-m.contactPrompts:
+			return contactPromptMsg(p)
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Model) contactPromptForm(p *contactPrompt) *huh.Form {
+	m.promptChoice = 0
+	var options []huh.Option[int]
+	for _, c := range p.contacts {
+		options = append(options, huh.NewOption(contactOptionLabel(c), c.Id))
+	}
+	options = append(options, huh.NewOption("Skip this user", 0))
+
+	return huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[int]().
+				Title(fmt.Sprintf("%d ConnectWise PSA contacts match %s (%s)", len(p.contacts), p.user.ZendeskUser.Name, p.user.ZendeskUser.Email)).
+				Description("Choose the contact to use for this user").
+				Options(options...).
+				Value(&m.promptChoice),
+		),
+	).WithHeight(m.verticalLeftForMainView).WithShowHelp(false).WithTheme(customFormTheme())
+}
+
+func contactOptionLabel(c psa.Contact) string {
+	label := fmt.Sprintf("%d: %s", c.Id, strings.TrimSpace(c.FirstName+" "+c.LastName))
+	if c.Company != nil && c.Company.Name != "" {
+		label += fmt.Sprintf(" - %s", c.Company.Name)
+	}
+
+	if c.InactiveFlag {
+		label += " (inactive)"
+	}
+
+	if c.Info != nil && !c.Info.LastUpdated.IsZero() {
+		label += fmt.Sprintf(" - updated %s", c.Info.LastUpdated.Format("2006-01-02"))
+	}
+
+	return label
+}
+
+// writeDuplicateContactsReport adds a section to the results listing every duplicate contact found during the run
+func (m *Model) writeDuplicateContactsReport() {
+	m.mu.Lock()
+	records := make([]duplicateContactRecord, len(m.data.DuplicateContacts))
+	copy(records, m.data.DuplicateContacts)
+	m.mu.Unlock()
+
+	if len(records) == 0 {
+		return
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Email < records[j].Email
+	})
+
+	m.writeToOutput(warnYellowOutput("DUPLICATE CONTACTS", fmt.Sprintf("%d Zendesk users matched more than one ConnectWise PSA contact:", len(records))), warnOutput)
+	for _, r := range records {
+		var ids []string
+		for _, id := range r.ContactIds {
+			ids = append(ids, fmt.Sprintf("%d", id))
+		}
+
+		m.writeToOutput(warnYellowOutput("DUPLICATE", fmt.Sprintf("%s (%d) <%s>: contacts %s - %s", r.UserName, r.ZendeskUserId, r.Email, strings.Join(ids, ", "), r.Resolution)), warnOutput)
+	}
+}
@@ -0,0 +1,797 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/fakeapi"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+)
+
+const (
+	e2eOrgId         = 1
+	e2eNoCompanyId   = 2
+	e2eNoTicketsId   = 3
+	e2eCompanyId     = 10
+	e2eUserCount     = 7
+	e2eTicketCount   = 12
+	e2eCommentCount  = 2
+	e2eExistingEmail = "user1@example.com"
+)
+
+// newE2EServer starts a fake server with three tagged orgs: one with users and tickets and a PSA company of the
+// same name, one with tickets but no PSA company, and one with no tickets. One user already has a PSA contact.
+func newE2EServer(t *testing.T) *fakeapi.Server {
+	t.Helper()
+
+	s := fakeapi.NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddOrg(zendesk.Organization{Id: e2eOrgId, Name: "Acme"}, "test")
+	s.AddOrg(zendesk.Organization{Id: e2eNoCompanyId, Name: "Globex"}, "test")
+	s.AddOrg(zendesk.Organization{Id: e2eNoTicketsId, Name: "Initech"}, "test")
+	s.AddOrg(zendesk.Organization{Id: 4, Name: "Untagged"})
+	s.AddCompany(psa.Company{Id: e2eCompanyId, Name: "Acme"})
+	s.AddCompany(psa.Company{Id: 11, Name: "Initech"})
+	s.AddContact(psa.Contact{Id: 50, FirstName: "Test", LastName: "User1", Company: &psa.Company{Id: e2eCompanyId}}, e2eExistingEmail)
+
+	s.AddUser(zendesk.User{Id: testAgentId, Name: "Agent", Email: "agent@example.com", Role: "agent", Active: true}, 0)
+	for i := 1; i <= e2eUserCount; i++ {
+		s.AddUser(zendesk.User{
+			Id:     i,
+			Name:   fmt.Sprintf("Test User%d", i),
+			Email:  fmt.Sprintf("user%d@example.com", i),
+			Role:   "end-user",
+			Active: true,
+		}, e2eOrgId)
+	}
+
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= e2eTicketCount; i++ {
+		ticket := zendesk.Ticket{
+			Id:             i,
+			CreatedAt:      created,
+			UpdatedAt:      created,
+			Subject:        fmt.Sprintf("Ticket %d", i),
+			Status:         "closed",
+			RequesterId:    int64(i%e2eUserCount + 1),
+			AssigneeId:     testAgentId,
+			OrganizationId: e2eOrgId,
+		}
+
+		var comments []zendesk.Comment
+		for c, author := range []int64{ticket.RequesterId, testAgentId} {
+			comments = append(comments, zendesk.Comment{
+				Id:        int64(i*10 + c),
+				AuthorId:  author,
+				Body:      fmt.Sprintf("comment %d on ticket %d", c, i),
+				Public:    true,
+				CreatedAt: created.Add(time.Duration(c) * time.Hour),
+			})
+		}
+
+		s.AddTicket(ticket, comments...)
+	}
+
+	// open tickets aren't migrated, and Globex's ticket stops it being skipped before the company lookup
+	s.AddTicket(zendesk.Ticket{Id: 100, CreatedAt: created, Status: "open", OrganizationId: e2eOrgId})
+	s.AddTicket(zendesk.Ticket{Id: 101, CreatedAt: created, Status: "solved", OrganizationId: e2eNoCompanyId})
+
+	return s
+}
+
+// newE2EModel returns a model with real API clients pointed at the fake server
+func newE2EModel(t *testing.T, s *fakeapi.Server) *Model {
+	t.Helper()
+
+	client := &Client{
+		ZendeskClient: zendesk.NewClient(zendesk.Creds{BaseUrl: s.ZendeskUrl()}, http.DefaultClient),
+		CwClient:      psa.NewClient(psa.Creds{BaseUrl: s.PsaUrl()}, http.DefaultClient),
+		Cfg:           testConfig(),
+	}
+
+	m := newTestModelWithClient(t, client)
+
+	var err error
+	m.report, err = newRunReport(t.TempDir(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// runE2E runs every step of a migration the way the TUI does, selecting all orgs ready for migration
+func runE2E(t *testing.T, m *Model) {
+	t.Helper()
+
+	if msg := m.getTagDetails()(); msg != switchStatusMsg(gettingZendeskOrgs) {
+		t.Fatalf("getTagDetails returned %v", msg)
+	}
+
+	if msg := m.getOrgs()(); msg != switchStatusMsg(comparingOrgs) {
+		t.Fatalf("getOrgs returned %v", msg)
+	}
+
+	m.data.SelectedOrgs = nil
+	for _, org := range m.data.AllOrgs.snapshot() {
+		m.checkOrg(org)()
+		if org.Migrated {
+			m.data.SelectedOrgs = append(m.data.SelectedOrgs, org)
+		}
+	}
+
+	for _, org := range m.data.SelectedOrgs {
+		m.getUsersToMigrate(org)()
+	}
+
+	if msg := m.migrateUsers(m.data.UsersToMigrate)(); msg != switchStatusMsg(gettingPsaTickets) {
+		t.Fatalf("migrateUsers returned %v", msg)
+	}
+
+	if msg := m.getAlreadyMigrated()(); msg != switchStatusMsg(migratingTickets) {
+		t.Fatalf("getAlreadyMigrated returned %v", msg)
+	}
+
+	if msg := m.runTicketMigration(m.data.SelectedOrgs)(); msg != switchStatusMsg(done) {
+		t.Fatalf("runTicketMigration returned %v", msg)
+	}
+}
+
+// TestEndToEnd migrates the fake server's data with pages small enough that every list is paginated, then runs
+// again to check nothing is duplicated and verifies the result
+func TestEndToEnd(t *testing.T) {
+	s := newE2EServer(t)
+	s.MaxPageSize = 5
+
+	m := newE2EModel(t, s)
+	runE2E(t, m)
+
+	if err := m.capturedErr(); err != nil {
+		t.Fatalf("migration captured an error: %v", err)
+	}
+
+	if len(m.data.SelectedOrgs) != 1 || m.data.SelectedOrgs[0].ZendeskOrg.Id != e2eOrgId {
+		t.Fatalf("selected orgs = %v, want only Acme", m.data.SelectedOrgs)
+	}
+
+	if got := m.orgsNotInPsa.get(); got != 1 {
+		t.Errorf("orgsNotInPsa = %d, want 1", got)
+	}
+
+	org, _ := s.ZendeskOrg(e2eOrgId)
+	if org.OrganizationFields.PSACompanyId != e2eCompanyId {
+		t.Errorf("zendesk org linked to company %d, want %d", org.OrganizationFields.PSACompanyId, e2eCompanyId)
+	}
+
+	for i := 1; i <= e2eUserCount; i++ {
+		user, _ := s.ZendeskUser(int64(i))
+		if user.UserFields.PSAContactId == 0 {
+			t.Errorf("user %d not linked to a psa contact", i)
+		}
+	}
+
+	user, _ := s.ZendeskUser(1)
+	if user.UserFields.PSAContactId != 50 {
+		t.Errorf("user 1 linked to contact %d, want the existing contact 50", user.UserFields.PSAContactId)
+	}
+
+	if got := len(s.Contacts()); got != e2eUserCount {
+		t.Errorf("got %d psa contacts, want %d", got, e2eUserCount)
+	}
+
+	tickets := s.Tickets()
+	if len(tickets) != e2eTicketCount {
+		t.Fatalf("got %d psa tickets, want %d", len(tickets), e2eTicketCount)
+	}
+
+	for _, ticket := range tickets {
+		if got := len(s.Notes(ticket.Id)); got != e2eCommentCount {
+			t.Errorf("psa ticket %d has %d notes, want %d", ticket.Id, got, e2eCommentCount)
+		}
+
+		if ticket.Company == nil || ticket.Company.Id != e2eCompanyId {
+			t.Errorf("psa ticket %d has company %v, want %d", ticket.Id, ticket.Company, e2eCompanyId)
+		}
+	}
+
+	if got := s.Count(http.MethodGet, fakeapi.ZendeskPath+"/search/export.json"); got < 3 {
+		t.Errorf("ticket search made %d requests, want it paginated", got)
+	}
+
+	identityLookups := s.Count(http.MethodGet, fakeapi.ZendeskPath+"/users/*/identities")
+	second := newE2EModel(t, s)
+	runE2E(t, second)
+
+	// every user is linked by the first run, so their contacts are used without looking them up again
+	if got := s.Count(http.MethodGet, fakeapi.ZendeskPath+"/users/*/identities") - identityLookups; got != 0 {
+		t.Errorf("second run looked up identities %d times, want 0", got)
+	}
+
+	if got := len(s.Tickets()); got != e2eTicketCount {
+		t.Errorf("second run left %d psa tickets, want %d", got, e2eTicketCount)
+	}
+
+	if got := len(s.Contacts()); got != e2eUserCount {
+		t.Errorf("second run left %d psa contacts, want %d", got, e2eUserCount)
+	}
+
+	if got := second.newTicketsCreated.get(); got != 0 {
+		t.Errorf("second run created %d tickets, want 0", got)
+	}
+
+	// the first run found no tickets in the psa, the second has three pages of them
+	if got := s.Count(http.MethodGet, fakeapi.PsaPath+"/service/tickets"); got < 4 {
+		t.Errorf("psa ticket list made %d requests, want it paginated", got)
+	}
+
+	report, err := newE2EModel(t, s).verifyMigration(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Reconciled {
+		t.Errorf("verify after migration: orgs = %+v, unlinked = %v", report.Orgs, report.UnlinkedOrgs)
+	}
+}
+
+// TestEndToEndRetries fails requests with rate limits and server errors that clear up, and checks the clients
+// retry them without losing or duplicating anything
+func TestEndToEndRetries(t *testing.T) {
+	s := newE2EServer(t)
+	s.Fail(fakeapi.Fault{Method: http.MethodGet, Path: fakeapi.ZendeskPath + "/search/export.json", Status: http.StatusTooManyRequests, RetryAfter: "0", Times: 2})
+	s.Fail(fakeapi.Fault{Method: http.MethodPut, Path: fakeapi.ZendeskPath + "/users/*", Status: http.StatusTooManyRequests, RetryAfter: "0", Times: 1})
+	s.Fail(fakeapi.Fault{Method: http.MethodPost, Path: fakeapi.PsaPath + "/service/tickets", Status: http.StatusServiceUnavailable, RetryAfter: "0", Times: 2})
+	s.Fail(fakeapi.Fault{Method: http.MethodPost, Path: fakeapi.PsaPath + "/service/tickets/*/notes", Status: http.StatusTooManyRequests, RetryAfter: "0", Times: 2})
+
+	m := newE2EModel(t, s)
+	runE2E(t, m)
+
+	if err := m.capturedErr(); err != nil {
+		t.Fatalf("migration captured an error: %v", err)
+	}
+
+	if got := m.ticketMigrationErrors.get(); got != 0 {
+		t.Errorf("ticketMigrationErrors = %d, want 0", got)
+	}
+
+	tickets := s.Tickets()
+	if len(tickets) != e2eTicketCount {
+		t.Fatalf("got %d psa tickets, want %d", len(tickets), e2eTicketCount)
+	}
+
+	for _, ticket := range tickets {
+		if ticket.Summary == "" {
+			t.Errorf("psa ticket %d was created without a summary", ticket.Id)
+		}
+
+		if got := len(s.Notes(ticket.Id)); got != e2eCommentCount {
+			t.Errorf("psa ticket %d has %d notes, want %d", ticket.Id, got, e2eCommentCount)
+		}
+	}
+}
+
+// TestEndToEndServerError fails every ticket creation with a server error, and checks each ticket is recorded as
+// failed with the server error class
+func TestEndToEndServerError(t *testing.T) {
+	s := newE2EServer(t)
+	s.Fail(fakeapi.Fault{Method: http.MethodPost, Path: fakeapi.PsaPath + "/service/tickets", Status: http.StatusInternalServerError, RetryAfter: "0"})
+
+	m := newE2EModel(t, s)
+	runE2E(t, m)
+
+	if got := m.ticketMigrationErrors.get(); got != e2eTicketCount {
+		t.Errorf("ticketMigrationErrors = %d, want %d", got, e2eTicketCount)
+	}
+
+	if got := len(s.Tickets()); got != 0 {
+		t.Errorf("got %d psa tickets, want 0", got)
+	}
+
+	var failed int
+	for _, rec := range m.report.records {
+		if rec.Entity != ticketEntity || rec.Action != actionFailed {
+			continue
+		}
+
+		failed++
+		if rec.ErrorClass != errClassServer {
+			t.Errorf("ticket %d failed with class %s, want %s", rec.ZendeskId, rec.ErrorClass, errClassServer)
+		}
+	}
+
+	if failed != e2eTicketCount {
+		t.Errorf("recorded %d failed tickets, want %d", failed, e2eTicketCount)
+	}
+}
+
+// newCassetteModel returns a model with clients built by newClient, so they record or replay as the options say
+func newCassetteModel(t *testing.T, s *fakeapi.Server, opts CliOptions) *Model {
+	t.Helper()
+
+	cfg := testConfig()
+	cfg.CliOptions = opts
+
+	client, err := newClient(zendesk.Creds{BaseUrl: s.ZendeskUrl()}, psa.Creds{BaseUrl: s.PsaUrl()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.close)
+
+	m := newTestModelWithClient(t, client)
+	m.report, err = newRunReport(t.TempDir(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// TestRecordReplay records a migration to a cassette, checks the cassette has no credentials or personal data,
+// then replays it with the server stopped and checks the replayed run does the same work
+func TestRecordReplay(t *testing.T) {
+	s := newE2EServer(t)
+	s.MaxPageSize = 5
+	s.Fail(fakeapi.Fault{Method: http.MethodPost, Path: fakeapi.PsaPath + "/service/tickets", Status: http.StatusTooManyRequests, RetryAfter: "0", Times: 1})
+	cassette := filepath.Join(t.TempDir(), "run.cassette")
+
+	recorded := newCassetteModel(t, s, CliOptions{Record: cassette})
+	runE2E(t, recorded)
+	recorded.client.close()
+	s.Close()
+
+	b, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"example.com", "Acme", "Test User", "comment 0", "Authorization", "127.0.0.1"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+
+	replayed := newCassetteModel(t, s, CliOptions{Replay: cassette})
+	runE2E(t, replayed)
+
+	if err := replayed.capturedErr(); err != nil {
+		t.Fatalf("replay captured an error: %v", err)
+	}
+
+	if len(replayed.data.SelectedOrgs) != 1 {
+		t.Errorf("replay selected %d orgs, want 1", len(replayed.data.SelectedOrgs))
+	}
+
+	if got, want := replayed.newTicketsCreated.get(), recorded.newTicketsCreated.get(); got != want || got != e2eTicketCount {
+		t.Errorf("replay created %d tickets, recording created %d, want %d", got, want, e2eTicketCount)
+	}
+
+	if got, want := replayed.newUsersCreated.get(), recorded.newUsersCreated.get(); got != want {
+		t.Errorf("replay created %d users, recording created %d", got, want)
+	}
+}
+
+// TestRedactJson checks a cassette keeps only the structural values in a body, with fields the fake APIs never
+// return, and that an email is redacted whatever key or URL it's in
+func TestRedactJson(t *testing.T) {
+	body := `{
+		"ticket": {
+			"id": 123,
+			"status": "closed",
+			"type": "incident",
+			"tags": ["migrate"],
+			"created_at": "2024-01-02T03:04:05Z",
+			"description": "my printer is broken",
+			"raw_subject": "Printer",
+			"recipient": "support@acme.test",
+			"external_id": "note about jane@acme.test",
+			"email_ccs": [{"user_id": 7, "user_email": "cc@acme.test"}],
+			"via": {"channel": "email", "source": {"from": {"address": "jane@acme.test", "name": "Jane Doe"}, "to": {"address": "help@acme.test"}}},
+			"metadata": {"system": {"ip_address": "203.0.113.9", "location": "Springfield, IL", "latitude": 39.78, "longitude": -89.65}},
+			"is_public": true
+		},
+		"next_page": "https://acme.zendesk.com/api/v2/search?query=email%3Abob%40acme.test"
+	}`
+
+	got := string(newRedactor().redactJson([]byte(body)))
+
+	for _, pii := range []string{"printer", "Printer", "acme.test", "jane", "Jane", "203.0.113.9", "Springfield", "39.78", "89.65", "bob", "acme.zendesk.com"} {
+		if strings.Contains(got, pii) {
+			t.Errorf("redacted body contains %q: %s", pii, got)
+		}
+	}
+
+	for _, kept := range []string{`"id":123`, `"status":"closed"`, `"type":"incident"`, `"tags":["migrate"]`, `"created_at":"2024-01-02T03:04:05Z"`, `"channel":"email"`, `"user_id":7`, `"is_public":true`} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("redacted body is missing %s: %s", kept, got)
+		}
+	}
+}
+
+// TestQuotedValues looks up a company and a contact whose name and email have quotes in them, and creates a contact
+// whose title is too long in multibyte characters
+func TestQuotedValues(t *testing.T) {
+	s := newE2EServer(t)
+	m := newE2EModel(t, s)
+
+	const company, email = `Acme "West" \ Co`, `"john smith"@example.com`
+	s.AddCompany(psa.Company{Id: 20, Name: company})
+	s.AddContact(psa.Contact{Id: 60, FirstName: "John"}, email)
+
+	co, err := m.client.CwClient.GetCompanyByName(m.ctx, company)
+	if err != nil || co.Id != 20 {
+		t.Errorf("GetCompanyByName(%q) = %v, %v, want company 20", company, co, err)
+	}
+
+	contact, err := m.client.CwClient.GetContactByEmail(m.ctx, email)
+	if err != nil || contact.Id != 60 {
+		t.Errorf("GetContactByEmail(%q) = %v, %v, want contact 60", email, contact, err)
+	}
+
+	user := &userMigrationDetails{
+		ZendeskUser: &zendesk.User{Id: 900, Name: "Long Title", Email: "title@example.com", Active: true},
+		PsaCompany:  &psa.Company{Id: e2eCompanyId},
+	}
+	user.ZendeskUser.UserFields.Title = strings.Repeat("é", psaTitleMaxLength+10)
+
+	created, err := m.createPsaContact(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range s.Contacts() {
+		if c.Id == created.Id && (c.Title != strings.Repeat("é", psaTitleMaxLength) || !utf8.ValidString(c.Title)) {
+			t.Errorf("contact title = %q, want it cut to %d characters", c.Title, psaTitleMaxLength)
+		}
+	}
+}
+
+// TestSharedEmails migrates two orgs whose users share emails - in a different case, through a secondary email, and
+// a user in both orgs - with many workers, and checks each email gets exactly one PSA contact
+func TestSharedEmails(t *testing.T) {
+	s := fakeapi.NewServer()
+	t.Cleanup(s.Close)
+
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"North", "South"} {
+		orgId, companyId := int64(i+1), 10+i
+		s.AddOrg(zendesk.Organization{Id: orgId, Name: name}, "test")
+		s.AddCompany(psa.Company{Id: companyId, Name: name})
+		s.AddTicket(zendesk.Ticket{Id: i + 1, CreatedAt: created, Subject: name, Status: "closed", RequesterId: int64(i + 1), AssigneeId: testAgentId, OrganizationId: orgId})
+	}
+
+	s.AddUser(zendesk.User{Id: testAgentId, Name: "Agent", Email: "agent@example.com", Role: "agent", Active: true}, 0)
+	s.AddUser(zendesk.User{Id: 1, Name: "North User", Email: "shared@example.com", Active: true}, 1)
+	s.AddUser(zendesk.User{Id: 2, Name: "South User", Email: "SHARED@example.com", Active: true}, 2)
+	s.AddUser(zendesk.User{Id: 3, Name: "Both Orgs", Email: "both@example.com", Active: true}, 1)
+	s.AddUserToOrg(3, 2)
+	s.AddUser(zendesk.User{Id: 4, Name: "Two Emails", Email: "first@example.com", Active: true}, 1,
+		zendesk.Identity{Id: 40, UserId: 4, Type: zendesk.EmailIdentityType, Value: "first@example.com", Verified: true, Primary: true},
+		zendesk.Identity{Id: 41, UserId: 4, Type: zendesk.EmailIdentityType, Value: "second@example.com", Verified: true})
+	s.AddUser(zendesk.User{Id: 5, Name: "Second Email", Email: "second@example.com", Active: true}, 2)
+
+	m := newE2EModel(t, s)
+	m.client.Cfg.UserWorkers = 10
+	runE2E(t, m)
+
+	if err := m.capturedErr(); err != nil {
+		t.Fatalf("migration captured an error: %v", err)
+	}
+
+	// shared@, both@, and first@ with second@
+	if got := len(s.Contacts()); got != 3 {
+		t.Errorf("created %d psa contacts, want 3: %+v", got, s.Contacts())
+	}
+
+	contactOf := func(id int) int {
+		u, ok := m.data.UsersInPsa.load(strconv.Itoa(id))
+		if !ok || u.PsaContact == nil {
+			t.Fatalf("user %d isn't in psa", id)
+		}
+		return u.PsaContact.Id
+	}
+
+	if contactOf(1) != contactOf(2) {
+		t.Errorf("users 1 and 2 share an email but have contacts %d and %d", contactOf(1), contactOf(2))
+	}
+
+	if contactOf(4) != contactOf(5) {
+		t.Errorf("users 4 and 5 share an email but have contacts %d and %d", contactOf(4), contactOf(5))
+	}
+}
+
+// TestDuplicateContactSkipped checks a user skipped at the duplicate contact prompt is reported as skipped rather
+// than failed, so it isn't counted as an error or retried
+func TestDuplicateContactSkipped(t *testing.T) {
+	s := newE2EServer(t)
+	s.AddContact(psa.Contact{Id: 51, FirstName: "Test", LastName: "User1", Company: &psa.Company{Id: e2eCompanyId}}, e2eExistingEmail)
+
+	m := newE2EModel(t, s)
+	m.client.Cfg.Connectwise.DuplicateContactPolicy = string(duplicatePolicyPrompt)
+	go func() {
+		p := <-m.contactPrompts
+		p.response <- 0
+	}()
+
+	runE2E(t, m)
+
+	if got := m.userMigrationErrors.get(); got != 0 {
+		t.Errorf("userMigrationErrors = %d, want 0", got)
+	}
+
+	var skipped []reportRecord
+	for _, rec := range m.report.records {
+		if rec.Entity == userEntity && rec.Action != actionCreated {
+			skipped = append(skipped, rec)
+		}
+	}
+
+	if len(skipped) != 1 || skipped[0].Action != actionSkipped || skipped[0].Reason != errContactSkipped.Error() {
+		t.Errorf("users not created = %+v, want one skipped at the prompt", skipped)
+	}
+}
+
+// TestOrgPickerCounts checks the org picker's user and migrated ticket counts are only fetched once it's about to be
+// shown, and only once however many times the form is started
+func TestOrgPickerCounts(t *testing.T) {
+	s := newE2EServer(t)
+	m := newE2EModel(t, s)
+
+	m.getTagDetails()()
+	m.getOrgs()()
+	for _, org := range m.data.AllOrgs.snapshot() {
+		m.checkOrg(org)()
+	}
+
+	countPaths := []string{fakeapi.ZendeskPath + "/organizations/*/related.json", fakeapi.PsaPath + "/service/tickets/count"}
+	for _, p := range countPaths {
+		if got := s.Count(http.MethodGet, p); got != 0 {
+			t.Errorf("%d requests to %s while checking orgs, want none until the picker is shown", got, p)
+		}
+	}
+
+	_, cmd := m.Update(switchStatusMsg(initOrgForm))
+	if _, again := m.Update(switchStatusMsg(initOrgForm)); again != nil {
+		t.Error("the org form started counting twice")
+	}
+
+	msg := cmd()
+	if _, ok := msg.(orgDetailsCountedMsg); !ok {
+		t.Fatalf("initOrgForm returned %T, want orgDetailsCountedMsg", msg)
+	}
+
+	m.Update(msg)
+	if m.orgPicker == nil {
+		t.Fatal("org picker not created after counting")
+	}
+
+	for _, p := range countPaths {
+		if got := s.Count(http.MethodGet, p); got != 1 {
+			t.Errorf("%d requests to %s, want 1 for the only org ready for migration", got, p)
+		}
+	}
+
+	acme, _ := m.data.AllOrgs.load(strconv.Itoa(e2eOrgId))
+	if acme.ZendeskUsers != e2eUserCount || acme.PsaTickets != 0 {
+		t.Errorf("Acme counted %d users and %d psa tickets, want %d and 0", acme.ZendeskUsers, acme.PsaTickets, e2eUserCount)
+	}
+}
+
+// TestPhases runs the org, user and ticket phases on their own, each with a fresh model as if run on a different
+// day, and checks each only does its own part
+func TestPhases(t *testing.T) {
+	s := newE2EServer(t)
+
+	orgs := newE2EModel(t, s)
+	if err := orgs.matchOrgs(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(orgs.data.SelectedOrgs) != 1 || orgs.data.SelectedOrgs[0].ZendeskOrg.Id != e2eOrgId {
+		t.Fatalf("matched orgs = %v, want only Acme", orgs.data.SelectedOrgs)
+	}
+
+	if got := len(s.Contacts()); got != 1 {
+		t.Errorf("orgs phase left %d psa contacts, want only the existing one", got)
+	}
+
+	users := newE2EModel(t, s)
+	if err := users.migrateLinkedUsers(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := users.usersProcessed.get(); got != e2eUserCount {
+		t.Errorf("users phase processed %d users, want %d", got, e2eUserCount)
+	}
+
+	if got := len(s.Contacts()); got != e2eUserCount {
+		t.Errorf("users phase left %d psa contacts, want %d", got, e2eUserCount)
+	}
+
+	if got := len(s.Tickets()); got != 0 {
+		t.Errorf("users phase created %d psa tickets, want 0", got)
+	}
+
+	tickets := newE2EModel(t, s)
+	if err := tickets.migrateLinkedTickets(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tickets.capturedErr(); err != nil {
+		t.Fatalf("tickets phase captured an error: %v", err)
+	}
+
+	if got := len(s.Tickets()); got != e2eTicketCount {
+		t.Errorf("tickets phase left %d psa tickets, want %d", got, e2eTicketCount)
+	}
+
+	if got := len(s.Contacts()); got != e2eUserCount {
+		t.Errorf("tickets phase left %d psa contacts, want %d", got, e2eUserCount)
+	}
+
+	if err := tickets.migrationFailures(); err != nil {
+		t.Errorf("tickets phase with no failures returned %v", err)
+	}
+
+	tickets.ticketMigrationErrors.inc()
+	if err := tickets.migrationFailures(); !errors.Is(err, ErrMigrationFailures) {
+		t.Errorf("tickets phase with a failure returned %v, want ErrMigrationFailures", err)
+	}
+}
+
+// TestOrgSelection checks excluded orgs are never checked, listed orgs are the only ones checked, and listed orgs
+// that aren't found are warned about
+func TestOrgSelection(t *testing.T) {
+	s := newE2EServer(t)
+
+	excluded := newE2EModel(t, s)
+	excluded.client.Cfg.OrgSelection = OrgSelection{}.withOverrides(OrgSelection{ExcludeOrgs: []string{"acme", "3"}})
+	if err := excluded.matchOrgs(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := excluded.data.AllOrgs.len(); got != 1 {
+		t.Errorf("with acme and initech excluded got %d orgs, want only globex", got)
+	}
+
+	if len(excluded.data.SelectedOrgs) != 0 {
+		t.Errorf("with acme excluded selected %v, want none", excluded.data.SelectedOrgs)
+	}
+
+	listed := newE2EModel(t, s)
+	listed.client.Cfg.OutputLevels.Warn = true
+	listed.client.Cfg.OrgSelection = OrgSelection{Orgs: []string{"Nowhere"}}.withOverrides(OrgSelection{Orgs: []string{"Missing"}, OrgIds: []int64{e2eOrgId}})
+	if err := listed.matchOrgs(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := listed.data.AllOrgs.len(); got != 1 {
+		t.Errorf("with only acme listed got %d orgs, want 1", got)
+	}
+
+	if len(listed.data.SelectedOrgs) != 1 || listed.data.SelectedOrgs[0].ZendeskOrg.Id != e2eOrgId {
+		t.Errorf("with only acme listed selected %v, want acme", listed.data.SelectedOrgs)
+	}
+
+	out := listed.data.Output.String()
+	if !strings.Contains(out, "org Missing is in the org selection") {
+		t.Errorf("output doesn't warn about the missing org:\n%s", out)
+	}
+
+	if strings.Contains(out, "Nowhere") {
+		t.Errorf("org from the config should be replaced by the flag:\n%s", out)
+	}
+}
+
+// TestDashboard checks the dashboard's API metrics and throughput against a real migration, with one rate limited
+// request
+func TestDashboard(t *testing.T) {
+	s := newE2EServer(t)
+	s.Fail(fakeapi.Fault{Method: http.MethodGet, Path: fakeapi.ZendeskPath + "/search/export.json", Status: http.StatusTooManyRequests, RetryAfter: "0", Times: 1})
+
+	metrics := newApiMetrics()
+	httpClient := &http.Client{Transport: &metricsTransport{next: http.DefaultTransport, metrics: metrics}}
+	client := &Client{
+		ZendeskClient: zendesk.NewClient(zendesk.Creds{BaseUrl: s.ZendeskUrl()}, httpClient),
+		CwClient:      psa.NewClient(psa.Creds{BaseUrl: s.PsaUrl()}, httpClient),
+		Cfg:           testConfig(),
+		metrics:       metrics,
+	}
+
+	m := newTestModelWithClient(t, client)
+	start := time.Now()
+	m.throughput.add(throughputSample{at: start})
+	runE2E(t, m)
+	m.throughput.add(throughputSample{at: start.Add(30 * time.Second), tickets: m.ticketsProcessed.get(), notes: m.notesCreated.get()})
+
+	if got, want := m.notesCreated.get(), e2eTicketCount*e2eCommentCount; got != want {
+		t.Errorf("notesCreated = %d, want %d", got, want)
+	}
+
+	hosts := metrics.snapshot()
+	var calls, rateLimited int
+	for _, h := range hosts {
+		calls += h.Calls
+		rateLimited += h.RateLimited
+	}
+
+	if calls == 0 || rateLimited != 1 {
+		t.Errorf("api metrics = %+v, want calls with 1 rate limited", hosts)
+	}
+
+	ticketsPerMin, notesPerMin := m.throughput.perMinute()
+	if ticketsPerMin != float64(e2eTicketCount*2) || notesPerMin != float64(e2eTicketCount*e2eCommentCount*2) {
+		t.Errorf("throughput = %.1f tickets/min, %.1f notes/min, want %d and %d", ticketsPerMin, notesPerMin, e2eTicketCount*2, e2eTicketCount*e2eCommentCount*2)
+	}
+
+	// samples older than the window are dropped, so the rate follows the current pace
+	m.throughput.add(throughputSample{at: start.Add(2 * time.Minute), tickets: m.ticketsProcessed.get(), notes: m.notesCreated.get()})
+	if ticketsPerMin, _ := m.throughput.perMinute(); ticketsPerMin != 0 {
+		t.Errorf("throughput after a minute with no tickets = %.1f tickets/min, want 0", ticketsPerMin)
+	}
+
+	if d, ok := eta(90, 30); !ok || d != 3*time.Minute {
+		t.Errorf("eta for 90 tickets at 30/min = %s, %t, want 3m0s", d, ok)
+	}
+
+	if _, ok := eta(90, 0); ok {
+		t.Error("eta with no throughput is known, want calculating")
+	}
+
+	m.status = migratingTickets
+	view := m.dashboardView(30)
+	for _, want := range []string{"Notes Created: 24", "1 rate limited", "Acme", "done", "100%", "calculating"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("dashboard doesn't show %q:\n%s", want, view)
+		}
+	}
+}
+
+// TestRequesterLookups checks each requester outside the org is looked up once, however many tickets they have: a
+// deleted requester is linked to a new contact, and an active one is left alone
+func TestRequesterLookups(t *testing.T) {
+	const deletedId, activeId, ticketsEach = 800, 801, 4
+
+	s := newE2EServer(t)
+	s.AddDeletedUser(zendesk.User{Id: deletedId, Name: "Gone User", Email: "gone@example.com", Role: "end-user"})
+	s.AddUser(zendesk.User{Id: activeId, Name: "Other User", Email: "other@example.com", Role: "end-user", Active: true}, 0)
+
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, requester := range []int64{deletedId, activeId} {
+		for j := 0; j < ticketsEach; j++ {
+			id := 200 + i*ticketsEach + j
+			s.AddTicket(zendesk.Ticket{Id: id, CreatedAt: created, UpdatedAt: created, Subject: "outside requester", Status: "closed", RequesterId: requester, AssigneeId: testAgentId, OrganizationId: e2eOrgId},
+				zendesk.Comment{Id: int64(id * 10), AuthorId: testAgentId, Body: "reply", Public: true, CreatedAt: created})
+		}
+	}
+
+	m := newE2EModel(t, s)
+	runE2E(t, m)
+
+	for _, path := range []string{"/users/800", "/deleted_users/800", "/users/801"} {
+		if got := s.Count(http.MethodGet, fakeapi.ZendeskPath+path); got != 1 {
+			t.Errorf("GET %s made %d times, want 1", path, got)
+		}
+	}
+
+	if _, ok := m.data.UsersInPsa.load("800"); !ok {
+		t.Error("deleted requester isn't in UsersInPsa")
+	}
+
+	if reason, _ := m.data.RequestersNotInPsa.load("801"); reason != "active user outside the selected orgs" {
+		t.Errorf("active requester not in psa reason = %q", reason)
+	}
+
+	if got := m.newTicketsCreated.get(); got != e2eTicketCount+ticketsEach {
+		t.Errorf("created %d tickets, want %d - only the active requester's should be skipped", got, e2eTicketCount+ticketsEach)
+	}
+}
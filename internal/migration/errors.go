@@ -1,5 +1,13 @@
 package migration
 
+import (
+	"context"
+	"errors"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+	"net/url"
+)
+
 type apiErrMsg struct {
 	Err error
 }
@@ -12,3 +20,66 @@ type fatalErrMsg struct {
 	Msg string
 	Err error
 }
+
+// errorClass is the broad type of an error, recorded against failures in the run report so retries can be
+// limited to failures that are likely to succeed a second time
+type errorClass string
+
+const (
+	errClassRateLimit errorClass = "rate_limit"
+	errClassServer    errorClass = "server"
+	errClassClient    errorClass = "client"
+	errClassNetwork   errorClass = "network"
+	errClassCancelled errorClass = "cancelled"
+	errClassOther     errorClass = "other"
+)
+
+var errorClasses = []errorClass{errClassRateLimit, errClassServer, errClassClient, errClassNetwork, errClassCancelled, errClassOther}
+
+func classifyErr(err error) errorClass {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return errClassCancelled
+	}
+
+	if errors.As(err, &psa.RateLimitErr{}) || errors.As(err, &zendesk.RateLimitErr{}) {
+		return errClassRateLimit
+	}
+
+	if errors.As(err, &psa.BadGatewayErr{}) {
+		return errClassServer
+	}
+
+	var psaErr psa.ApiErr
+	if errors.As(err, &psaErr) {
+		return statusClass(psaErr.StatusCode)
+	}
+
+	var zdErr zendesk.ApiErr
+	if errors.As(err, &zdErr) {
+		return statusClass(zdErr.StatusCode)
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return errClassNetwork
+	}
+
+	return errClassOther
+}
+
+func statusClass(statusCode int) errorClass {
+	switch {
+	case statusCode == 429:
+		return errClassRateLimit
+	case statusCode >= 500:
+		return errClassServer
+	case statusCode >= 400:
+		return errClassClient
+	}
+
+	return errClassOther
+}
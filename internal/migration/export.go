@@ -0,0 +1,458 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExportOptions are the options for the export command
+type ExportOptions struct {
+	// Dir is the directory to write the archive to. Defaults to a new folder under the profile's archives folder.
+	Dir string
+}
+
+// Export snapshots the Zendesk data for every tag in the config to a local archive, which the migration can then
+// be run from with the --archive flag. Only the Zendesk API is used.
+func Export(opts CliOptions, exportOpts ExportOptions) error {
+	if opts.Archive != "" || opts.ZendeskExport != "" {
+		return errors.New("export reads from the Zendesk API, and can't be run from an archive or zendesk export")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, client, err := exportStartup(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	m, err := newModel(ctx, client)
+	if err != nil {
+		return fmt.Errorf("initializing export: %w", err)
+	}
+
+	archiveDir := exportOpts.Dir
+	if archiveDir == "" {
+		archiveDir = filepath.Join(dir, archivesDirName, time.Now().Format(runDirTimeLayout))
+	}
+
+	fmt.Printf("Exporting Zendesk data to %s\n", archiveDir)
+	manifest, err := m.exportArchive(archiveDir)
+	if err != nil {
+		slog.Error("exporting zendesk data", "dir", archiveDir, "error", err)
+		return fmt.Errorf("exporting zendesk data: %w", err)
+	}
+
+	fmt.Printf("Exported %d orgs, %d users, %d tickets, %d comments and %d attachments\n",
+		manifest.Organizations, manifest.Users, manifest.Tickets, manifest.Comments, manifest.Attachments)
+	fmt.Printf("Run the migration from it with: --archive %s\n", archiveDir)
+	return nil
+}
+
+// exporter writes the Zendesk data for an archive. Users are collected as they're found and written at the end,
+// once every org they belong to is known.
+type exporter struct {
+	ctx      context.Context
+	zc       *zendesk.Client
+	w        *archiveWriter
+	pageSize int
+	workers  int
+
+	mu       sync.Mutex
+	users    map[int64]*archivedUser
+	wanted   map[int64]bool
+	manifest archiveManifest
+}
+
+// exportArchive writes the orgs under every tag in the config to an archive, along with their users, and all of
+// the tickets (open or closed) created in each tag's date range with their comments and attachments. The
+// migration flags decide which tickets are migrated when it's loaded.
+func (m *Model) exportArchive(dir string) (*archiveManifest, error) {
+	zc, ok := m.client.ZendeskClient.(*zendesk.Client)
+	if !ok {
+		return nil, errors.New("export needs the Zendesk API client")
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("archive directory %s is not empty", dir)
+	}
+
+	if msg, ok := m.getTagDetails()().(timeConvertErrMsg); ok {
+		return nil, fmt.Errorf("getting tag details: %w", msg.Err)
+	}
+
+	w, err := newArchiveWriter(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer w.close()
+
+	e := &exporter{
+		ctx:      m.ctx,
+		zc:       zc,
+		w:        w,
+		pageSize: m.client.Cfg.ZendeskPageSize,
+		workers:  m.client.Cfg.TicketWorkers,
+		users:    make(map[int64]*archivedUser),
+		wanted:   make(map[int64]bool),
+		manifest: archiveManifest{
+			Version:    archiveVersion,
+			Subdomain:  m.client.Cfg.Zendesk.Creds.Subdomain,
+			ExportedAt: time.Now(),
+		},
+	}
+
+	for _, tag := range m.data.Tags {
+		e.manifest.Tags = append(e.manifest.Tags, archiveTag{
+			Name:      tag.Name,
+			StartDate: tag.StartDate.Format(archiveDateLayout),
+			EndDate:   tag.EndDate.Format(archiveDateLayout),
+		})
+	}
+
+	if err := e.run(m.data.Tags); err != nil {
+		return nil, err
+	}
+
+	b, err := json.MarshalIndent(e.manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling archive manifest: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, archiveManifestName), b, 0644); err != nil {
+		return nil, fmt.Errorf("writing archive manifest: %w", err)
+	}
+
+	return &e.manifest, nil
+}
+
+func (e *exporter) run(tags []tagDetails) error {
+	if err := e.exportFields(); err != nil {
+		return err
+	}
+
+	agents, err := e.zc.GetAgents(e.ctx)
+	if err != nil {
+		return fmt.Errorf("getting agents: %w", err)
+	}
+
+	for _, agent := range agents {
+		e.addUser(agent, 0, false)
+	}
+
+	orgs, err := e.exportOrgs(tags)
+	if err != nil {
+		return err
+	}
+
+	var tickets []zendesk.Ticket
+	for _, org := range orgs {
+		orgTickets, err := e.getOrgTickets(org, tags)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: %d tickets\n", org.Name, len(orgTickets))
+		tickets = append(tickets, orgTickets...)
+	}
+
+	if err := forEachConcurrent(tickets, e.workers, e.exportTicket); err != nil {
+		return err
+	}
+
+	if err := e.getWantedUsers(); err != nil {
+		return err
+	}
+
+	return e.exportUsers()
+}
+
+func (e *exporter) exportFields() error {
+	userFields, err := e.zc.GetUserFields(e.ctx)
+	if err != nil {
+		return fmt.Errorf("getting user fields: %w", err)
+	}
+
+	for _, f := range userFields {
+		if err := e.w.append(archiveFieldsName, archivedField{UserField: &f}); err != nil {
+			return err
+		}
+	}
+
+	orgFields, err := e.zc.GetOrgFields(e.ctx)
+	if err != nil {
+		return fmt.Errorf("getting organization fields: %w", err)
+	}
+
+	for _, f := range orgFields {
+		if err := e.w.append(archiveFieldsName, archivedField{OrganizationField: &f}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportOrgs writes every org under each tag, and returns them sorted by ID with the tags they were found under
+func (e *exporter) exportOrgs(tags []tagDetails) ([]*archivedOrg, error) {
+	byId := make(map[int64]*archivedOrg)
+	for _, tag := range tags {
+		orgs, err := e.zc.GetOrganizationsWithQuery(e.ctx, zendesk.SearchQuery{Tags: []string{tag.Name}})
+		if err != nil {
+			return nil, fmt.Errorf("getting orgs for tag %s: %w", tag.Name, err)
+		}
+
+		for _, org := range orgs {
+			if _, ok := byId[org.Id]; !ok {
+				byId[org.Id] = &archivedOrg{Organization: org}
+			}
+			byId[org.Id].Tags = append(byId[org.Id].Tags, tag.Name)
+		}
+	}
+
+	var orgs []*archivedOrg
+	for _, org := range byId {
+		orgs = append(orgs, org)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Id < orgs[j].Id })
+
+	for _, org := range orgs {
+		if err := e.w.append(archiveOrgsName, org); err != nil {
+			return nil, err
+		}
+	}
+
+	e.manifest.Organizations = len(orgs)
+	return orgs, nil
+}
+
+// getOrgTickets gets an org's users, and its tickets created in the date range of each tag it's under
+func (e *exporter) getOrgTickets(org *archivedOrg, tags []tagDetails) ([]zendesk.Ticket, error) {
+	users, err := e.zc.GetOrganizationUsers(e.ctx, org.Id)
+	if err != nil {
+		return nil, fmt.Errorf("getting users for org %s: %w", org.Name, err)
+	}
+
+	for _, user := range users {
+		e.addUser(user, org.Id, false)
+	}
+
+	seen := make(map[int]bool)
+	var tickets []zendesk.Ticket
+	for _, tag := range tags {
+		if !slices.Contains(org.Tags, tag.Name) {
+			continue
+		}
+
+		q := zendesk.SearchQuery{
+			TicketsOrganizationId: org.Id,
+			TicketCreatedAfter:    tag.StartDate,
+			TicketCreatedBefore:   tag.EndDate,
+			GetOpenTickets:        true,
+		}
+
+		tagTickets, err := e.zc.GetTicketsWithQuery(e.ctx, q, e.pageSize, 0)
+		if err != nil {
+			return nil, fmt.Errorf("getting tickets for org %s: %w", org.Name, err)
+		}
+
+		for _, t := range tagTickets {
+			if seen[t.Id] {
+				continue
+			}
+			seen[t.Id] = true
+
+			if t.OrganizationId == 0 {
+				t.OrganizationId = org.Id
+			}
+			tickets = append(tickets, t)
+		}
+	}
+
+	return tickets, nil
+}
+
+// exportTicket writes a ticket with its comments, and saves the comment attachments
+func (e *exporter) exportTicket(ticket zendesk.Ticket) error {
+	comments, err := e.zc.GetAllTicketComments(e.ctx, int64(ticket.Id))
+	if err != nil {
+		return fmt.Errorf("getting comments for ticket %d: %w", ticket.Id, err)
+	}
+
+	var attachments int
+	for _, comment := range comments {
+		for _, a := range comment.Attachments {
+			if err := e.saveAttachment(a); err != nil {
+				return fmt.Errorf("saving attachment %d on ticket %d: %w", a.Id, ticket.Id, err)
+			}
+			attachments++
+		}
+
+		if err := e.w.append(archiveCommentsName, archivedComment{TicketId: int64(ticket.Id), Comment: comment}); err != nil {
+			return err
+		}
+	}
+
+	if err := e.w.append(archiveTicketsName, ticket); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.wanted[ticket.RequesterId] = true
+	for _, comment := range comments {
+		e.wanted[comment.AuthorId] = true
+	}
+
+	e.manifest.Tickets++
+	e.manifest.Comments += len(comments)
+	e.manifest.Attachments += attachments
+	return nil
+}
+
+func (e *exporter) saveAttachment(a zendesk.Attachment) error {
+	path := filepath.Join(e.w.dir, attachmentPath(a))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("creating attachment directory: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating attachment file: %w", err)
+	}
+	defer f.Close()
+
+	if err := e.zc.DownloadAttachment(e.ctx, a.ContentUrl, f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// getWantedUsers gets the ticket requesters and comment authors that aren't agents or members of an exported
+// org. Users that have been deleted are saved from the deleted users endpoint, and any that can't be found at all
+// are left out, as they would be when migrating from the API.
+func (e *exporter) getWantedUsers() error {
+	var ids []int64
+	e.mu.Lock()
+	for id := range e.wanted {
+		if _, ok := e.users[id]; !ok && id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	e.mu.Unlock()
+
+	return forEachConcurrent(ids, e.workers, func(id int64) error {
+		user, err := e.zc.GetUser(e.ctx, id)
+		if err == nil {
+			e.addUser(*user, 0, false)
+			return nil
+		}
+
+		user, deletedErr := e.zc.GetDeletedUser(e.ctx, id)
+		if deletedErr == nil {
+			e.addUser(*user, 0, true)
+			return nil
+		}
+
+		slog.Warn("exporter.getWantedUsers: user not found", "userId", id, "error", err, "deletedError", deletedErr)
+		return nil
+	})
+}
+
+// exportUsers gets the identities of each user, and writes them all sorted by ID
+func (e *exporter) exportUsers() error {
+	var users []*archivedUser
+	for _, u := range e.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+
+	err := forEachConcurrent(users, e.workers, func(u *archivedUser) error {
+		if u.Deleted {
+			return nil
+		}
+
+		identities, err := e.zc.GetUserIdentities(e.ctx, int64(u.Id))
+		if err != nil {
+			return fmt.Errorf("getting identities for user %d: %w", u.Id, err)
+		}
+
+		u.Identities = identities
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		if err := e.w.append(archiveUsersName, u); err != nil {
+			return err
+		}
+	}
+
+	e.manifest.Users = len(users)
+	return nil
+}
+
+func (e *exporter) addUser(user zendesk.User, orgId int64, deleted bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	u, ok := e.users[int64(user.Id)]
+	if !ok {
+		u = &archivedUser{User: user, Deleted: deleted}
+		e.users[int64(user.Id)] = u
+	}
+
+	if orgId != 0 && !slices.Contains(u.OrganizationIds, orgId) {
+		u.OrganizationIds = append(u.OrganizationIds, orgId)
+	}
+}
+
+// forEachConcurrent runs fn for each item, up to workers at a time, and returns the first error. No more items
+// are started once one has failed.
+func forEachConcurrent[T any](items []T, workers int, fn func(T) error) error {
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(workers, 1))
+
+	for _, item := range items {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item T) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(item); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"sort"
+	"sync"
+)
+
+// keyedMutex holds a separate lock per key, so work on different keys can run concurrently while work on the
+// same key is serialized. Locks are removed once nothing holds or waits on them. The zero value is ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks all the given keys and returns a function to unlock them. Keys are locked in sorted order so two
+// callers sharing more than one key can't deadlock.
+func (k *keyedMutex) lock(keys ...string) func() {
+	sorted := uniqueSorted(keys)
+
+	var held []*keyedLock
+	for _, key := range sorted {
+		k.mu.Lock()
+		if k.locks == nil {
+			k.locks = make(map[string]*keyedLock)
+		}
+
+		l, ok := k.locks[key]
+		if !ok {
+			l = &keyedLock{}
+			k.locks[key] = l
+		}
+		l.refs++
+		k.mu.Unlock()
+
+		l.mu.Lock()
+		held = append(held, l)
+	}
+
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].mu.Unlock()
+
+			k.mu.Lock()
+			held[i].refs--
+			if held[i].refs == 0 {
+				delete(k.locks, sorted[i])
+			}
+			k.mu.Unlock()
+		}
+	}
+}
+
+func uniqueSorted(keys []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+
+	sort.Strings(unique)
+	return unique
+}
@@ -1,28 +1,193 @@
 package migration
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"log/slog"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 )
 
-func setLogger(file *os.File, debug bool) error {
+const redacted = "[REDACTED]"
+
+var (
+	// authHeaderPattern matches the value of an Authorization header, e.g. in a logged request dump
+	authHeaderPattern = regexp.MustCompile(`(?i)\b(basic|bearer)\s+[A-Za-z0-9+/=._~-]{8,}`)
+
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// sensitiveLogKeys are attribute keys whose values are always redacted
+	sensitiveLogKeys = map[string]bool{
+		"authorization": true,
+		"token":         true,
+		"password":      true,
+		"private_key":   true,
+		"privatekey":    true,
+		"public_key":    true,
+		"publickey":     true,
+	}
+)
+
+// logSecrets are values scrubbed from every log attribute, such as API keys, added once the config is loaded
+var logSecrets = &secretSet{}
+
+type secretSet struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// add adds secrets to scrub from the logs, ignoring any too short to scrub without mangling other values
+func (s *secretSet) add(secrets ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range secrets {
+		if len(v) >= 4 {
+			s.values = append(s.values, v)
+		}
+	}
+}
+
+func (s *secretSet) scrub(str string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, v := range s.values {
+		str = strings.ReplaceAll(str, v, redacted)
+	}
+
+	return str
+}
+
+// addConfigSecrets adds the API credentials in the config to the secrets scrubbed from the logs
+func addConfigSecrets(cfg *Config) {
+	logSecrets.add(
+		cfg.Zendesk.Creds.Token,
+		cfg.Connectwise.Creds.PublicKey,
+		cfg.Connectwise.Creds.PrivateKey,
+	)
+}
+
+// redactingHandler wraps a handler to scrub secrets from every attribute before it is written: known secrets and
+// Authorization header values anywhere, any value under a sensitive key, and optionally customer emails, which
+// are replaced with a hash so the same email can still be followed through the log.
+type redactingHandler struct {
+	next       slog.Handler
+	secrets    *secretSet
+	hashEmails bool
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.scrub(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = h.redactAttr(a)
+	}
+
+	return &redactingHandler{next: h.next.WithAttrs(redactedAttrs), secrets: h.secrets, hashEmails: h.hashEmails}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), secrets: h.secrets, hashEmails: h.hashEmails}
+}
+
+func (h *redactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if sensitiveLogKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.scrub(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redactedGroup := make([]any, len(group))
+		for i, ga := range group {
+			redactedGroup[i] = h.redactAttr(ga)
+		}
+		return slog.Group(a.Key, redactedGroup...)
+	case slog.KindAny:
+		return slog.Attr{Key: a.Key, Value: h.redactAny(a.Value.Any())}
+	default:
+		return a
+	}
+}
+
+// redactAny scrubs errors as their message, and anything else as the JSON it would be logged as
+func (h *redactingHandler) redactAny(v any) slog.Value {
+	if err, ok := v.(error); ok {
+		return slog.StringValue(h.scrub(err.Error()))
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return slog.StringValue(h.scrub(fmt.Sprintf("%+v", v)))
+	}
+
+	scrubbed := h.scrub(string(b))
+	if scrubbed == string(b) {
+		return slog.AnyValue(v)
+	}
+
+	// the replacements have no quotes or escapes, so the scrubbed JSON is still valid
+	return slog.AnyValue(json.RawMessage(scrubbed))
+}
+
+func (h *redactingHandler) scrub(s string) string {
+	s = h.secrets.scrub(s)
+	s = authHeaderPattern.ReplaceAllString(s, "$1 "+redacted)
+
+	if h.hashEmails {
+		s = emailPattern.ReplaceAllStringFunc(s, hashEmail)
+	}
+
+	return s
+}
+
+// hashEmail replaces an email with a short hash of it, the same in every run, so it can be matched against a
+// known address without being readable in the log
+func hashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "email:" + hex.EncodeToString(sum[:])[:12]
+}
+
+func setLogger(file *os.File, debug, hashEmails bool) error {
 	level := slog.LevelInfo
 	if debug {
 		level = slog.LevelDebug
 	}
 
-	logger := slog.New(slog.NewJSONHandler(&lumberjack.Logger{
+	handler := slog.NewJSONHandler(&lumberjack.Logger{
 		Filename:   file.Name(),
 		MaxSize:    5,
 		MaxBackups: 5,
 		Compress:   true,
 	}, &slog.HandlerOptions{
 		Level: level,
-	}))
+	})
 
-	slog.SetDefault(logger)
+	slog.SetDefault(slog.New(&redactingHandler{next: handler, secrets: logSecrets, hashEmails: hashEmails}))
 	slog.Debug("DEBUGGING ENABLED")
 	return nil
 }
@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// hostMetrics are the API calls made to one host, and how many were rate limited
+type hostMetrics struct {
+	Host        string
+	Calls       int
+	RateLimited int
+}
+
+// apiMetrics counts the API calls made by both clients, by host. It's safe to use from many goroutines.
+type apiMetrics struct {
+	mu     sync.Mutex
+	byHost map[string]*hostMetrics
+}
+
+func newApiMetrics() *apiMetrics {
+	return &apiMetrics{byHost: make(map[string]*hostMetrics)}
+}
+
+func (a *apiMetrics) record(host string, rateLimited bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	h, ok := a.byHost[host]
+	if !ok {
+		h = &hostMetrics{Host: host}
+		a.byHost[host] = h
+	}
+
+	h.Calls++
+	if rateLimited {
+		h.RateLimited++
+	}
+}
+
+// snapshot returns a copy of the metrics for every host, sorted by host. A client made without metrics has none.
+func (a *apiMetrics) snapshot() []hostMetrics {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	hosts := make([]hostMetrics, 0, len(a.byHost))
+	for _, h := range a.byHost {
+		hosts = append(hosts, *h)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// metricsTransport sends requests with the next transport, counting each one and each 429 response by host. Retries
+// are sent through it too, so every attempt is counted.
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics *apiMetrics
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	t.metrics.record(req.URL.Host, res != nil && res.StatusCode == http.StatusTooManyRequests)
+	return res, err
+}
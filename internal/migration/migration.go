@@ -2,6 +2,7 @@ package migration
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
@@ -9,7 +10,6 @@ import (
 	"github.com/spf13/viper"
 	"log/slog"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -24,58 +24,110 @@ const (
 )
 
 type Client struct {
-	ZendeskClient *zendesk.Client
-	CwClient      *psa.Client
+	ZendeskClient Source
+	CwClient      Destination
 	Cfg           *Config
+
+	transport http.RoundTripper
+	metrics   *apiMetrics
 }
 
 func Run(opts CliOptions) error {
-	ctx := context.Background()
-	dir, err := makeMigrationDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, client, err := startup(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("creating migration directory: %w", err)
+		return err
 	}
+	defer client.close()
 
-	logFile, err := openLogFile(filepath.Join(dir, "migration.log"))
+	model, err := newModel(ctx, client)
+	if err != nil {
+		return fmt.Errorf("initializing terminal interface: %w", err)
+	}
+
+	model.migrationDir = dir
+	model.report, err = newRunReport(dir, time.Now())
 	if err != nil {
-		return fmt.Errorf("opening log file: %w", err)
+		return fmt.Errorf("creating run report: %w", err)
 	}
 
-	if err := setLogger(logFile, opts.Debug); err != nil {
-		return fmt.Errorf("setting logger: %w", err)
+	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// startup sets up logging in the migration directory, then loads the config and connects to both APIs
+func startup(ctx context.Context, opts CliOptions) (string, *Client, error) {
+	dir, err := startLogging(opts)
+	if err != nil {
+		return "", nil, err
 	}
 
 	client, err := runStartup(ctx, dir, opts)
 	if err != nil {
 		slog.Error("running startup", "error", err)
-		return err
+		return "", nil, err
 	}
 
-	model, err := newModel(ctx, client)
+	return dir, client, nil
+}
+
+// exportStartup is startup for the export command, which only needs the Zendesk config and API
+func exportStartup(ctx context.Context, opts CliOptions) (string, *Client, error) {
+	dir, err := startLogging(opts)
 	if err != nil {
-		return fmt.Errorf("initializing terminal interface: %w", err)
+		return "", nil, err
 	}
 
-	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
-	if _, err := p.Run(); err != nil {
-		return err
+	cfg, err := InitConfig(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to initialize config: %w", err)
 	}
+	addConfigSecrets(cfg)
 
-	return nil
-}
+	cfg.CliOptions = opts
+	cfg.Tuning = cfg.Tuning.withOverrides(opts.TuningOverrides).withDefaults()
+	slog.Info("export startup options", "opts", opts, "tuning", cfg.Tuning)
 
-func makeMigrationDir() (string, error) {
-	home, err := os.UserHomeDir()
+	if err := cfg.validateExportConfig(); err != nil {
+		return "", nil, fmt.Errorf("validating config: %w", err)
+	}
+
+	client, err := newClient(cfg.Zendesk.Creds, cfg.Connectwise.Creds, cfg)
 	if err != nil {
-		return "", fmt.Errorf("getting user home directory: %w", err)
+		return "", nil, err
+	}
+
+	if err := client.ZendeskClient.ConnectionTest(ctx); err != nil {
+		slog.Error("zendesk api connection test", "error", err)
+		return "", nil, fmt.Errorf("testing zendesk connection: %w", err)
 	}
 
-	migrationDir := filepath.Join(home, "ticket-migration")
-	if err := os.MkdirAll(migrationDir, os.ModePerm); err != nil {
+	return dir, client, nil
+}
+
+// startLogging creates the migration directory and sets up logging to its log file
+func startLogging(opts CliOptions) (string, error) {
+	dir, err := makeMigrationDir(opts.Profile)
+	if err != nil {
 		return "", fmt.Errorf("creating migration directory: %w", err)
 	}
 
-	return migrationDir, nil
+	logFile, err := openLogFile(filepath.Join(dir, "migration.log"))
+	if err != nil {
+		return "", fmt.Errorf("opening log file: %w", err)
+	}
+
+	if err := setLogger(logFile, opts.Debug, opts.HashLogEmails); err != nil {
+		return "", fmt.Errorf("setting logger: %w", err)
+	}
+
+	return dir, nil
 }
 
 func runStartup(ctx context.Context, dir string, opts CliOptions) (*Client, error) {
@@ -83,13 +135,16 @@ func runStartup(ctx context.Context, dir string, opts CliOptions) (*Client, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize config: %w", err)
 	}
+	addConfigSecrets(cfg)
 
 	cfg.CliOptions = opts
+	cfg.Tuning = cfg.Tuning.withOverrides(opts.TuningOverrides).withDefaults()
+	cfg.OrgSelection = cfg.OrgSelection.withOverrides(opts.OrgOverrides)
 	viper.Set("ticket_limit", opts.TicketLimit)
 	viper.Set("migrate_open_tickets", opts.MigrateOpenTickets)
 	viper.Set("output_levels", opts.OutputLevels)
 
-	slog.Info("startup options", "opts", opts)
+	slog.Info("startup options", "opts", opts, "tuning", cfg.Tuning, "orgSelection", cfg.OrgSelection)
 
 	if err := cfg.validatePreClient(); err != nil {
 		return nil, fmt.Errorf("validating config: %w", err)
@@ -97,38 +152,90 @@ func runStartup(ctx context.Context, dir string, opts CliOptions) (*Client, erro
 
 	slog.Info("config validated")
 
-	client := newClient(cfg.Zendesk.Creds, cfg.Connectwise.Creds, cfg)
+	client, err := newClient(cfg.Zendesk.Creds, cfg.Connectwise.Creds, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := localZendeskSource(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if source != nil {
+		client.ZendeskClient = source
+	}
 
 	if err := client.validatePostClient(ctx); err != nil {
 		return nil, fmt.Errorf("validating config: %w", err)
 	}
 
 	slog.Debug("config details", "config", cfg)
-	if err := viper.WriteConfig(); err != nil {
+	if err := cfg.writeConfig(); err != nil {
 		return nil, fmt.Errorf("writing config file: %w", err)
 	}
 	return client, nil
 }
 
-func newClient(zendeskCreds zendesk.Creds, cwCreds psa.Creds, cfg *Config) *Client {
+func newClient(zendeskCreds zendesk.Creds, cwCreds psa.Creds, cfg *Config) (*Client, error) {
+	transport, err := newCassetteTransport(cfg.CliOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := newApiMetrics()
 	httpClient := &http.Client{
-		Transport: newTransport(),
+		Transport: &metricsTransport{next: transport, metrics: metrics},
 	}
-	
+
 	return &Client{
 		ZendeskClient: zendesk.NewClient(zendeskCreds, httpClient),
 		CwClient:      psa.NewClient(cwCreds, httpClient),
 		Cfg:           cfg,
+		transport:     transport,
+		metrics:       metrics,
+	}, nil
+}
+
+// newCassetteTransport returns the transport for both API clients, which records to or replays from a cassette
+// if either option is set
+func newCassetteTransport(opts CliOptions) (http.RoundTripper, error) {
+	switch {
+	case opts.Record != "" && opts.Replay != "":
+		return nil, errors.New("record and replay can't be used together")
+	case opts.Record != "":
+		t, err := newRecordingTransport(newTransport(), opts.Record)
+		if err != nil {
+			return nil, fmt.Errorf("starting recording: %w", err)
+		}
+		return t, nil
+	case opts.Replay != "":
+		t, err := newReplayTransport(opts.Replay)
+		if err != nil {
+			return nil, fmt.Errorf("starting replay: %w", err)
+		}
+		return t, nil
+	default:
+		return newTransport(), nil
+	}
+}
+
+// close finishes writing the cassette, if the run is being recorded
+func (c *Client) close() {
+	if t, ok := c.transport.(*recordingTransport); ok {
+		if err := t.close(); err != nil {
+			slog.Error("closing cassette", "error", err)
+		}
 	}
 }
 
 func newTransport() *http.Transport {
 	return &http.Transport{
 		MaxIdleConns:        100,
-		MaxConnsPerHost:     100,
+		MaxConnsPerHost:     maxConnsPerHost,
 		IdleConnTimeout:     90 * time.Second,
 		DisableCompression:  true,
-		MaxIdleConnsPerHost: 100,
+		MaxIdleConnsPerHost: maxConnsPerHost,
 	}
 }
 
@@ -10,8 +10,8 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"log/slog"
-	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,20 +19,44 @@ type Model struct {
 	// API
 	mu     sync.Mutex
 	ctx    context.Context
+	cancel context.CancelFunc
 	client *Client
 
 	// Migration State
-	timeZone               *time.Location
-	form                   *huh.Form
-	formComplete           bool
-	allOrgsSelected        bool
-	status                 migrationStatus
-	currentTicketMigration *activeTicketMigration
-	data                   *Data
-	errCapture             errCapture
+	timeZone     *time.Location
+	orgPicker    *orgPicker
+	formComplete bool
+
+	// countingOrgDetails is set while the org picker's details are counted, before it's shown
+	countingOrgDetails bool
+	status             migrationStatus
+	ticketProgress     atomic.Pointer[[]*orgTicketMigration]
+	data               *Data
+	errCapture         errCapture
+	contactLocks       keyedMutex
+	throughput         throughput
 	statistics
 
+	// Duplicate contact prompts
+	contactPrompts chan *contactPrompt
+	activePrompt   *contactPrompt
+	promptForm     *huh.Form
+	promptChoice   int
+
+	// Pause, resume and quit
+	dispatch        dispatchGate
+	quitPrompt      *huh.Form
+	quitChoice      quitChoice
+	quitAfterFinish bool
+
+	report      *runReport
+	runFinished bool
+
+	// migrationDir is where the results are saved
+	migrationDir string
+
 	// UI
+	search   resultsSearch
 	viewport viewport.Model
 	spinner  spinner.Model
 	dimensions
@@ -54,20 +78,53 @@ type errCapture struct {
 	err  error
 }
 
+// statistics are updated by many goroutines at once, so every counter is atomic
 type statistics struct {
-	orgsChecked         int
-	orgsNotInPsa        int
-	orgsMigrated        int
-	orgsCheckedForUsers int
-	usersProcessed      int
-	newUsersCreated     int
-	ticketsToProcess    int
-	ticketsProcessed    int
-	newTicketsCreated   int
-	ticketOrgsProcessed int
-
-	userMigrationErrors   int
-	ticketMigrationErrors int
+	orgsChecked         counter
+	orgsNotInPsa        counter
+	orgsMigrated        counter
+	orgsCheckedForUsers counter
+	usersProcessed      counter
+	newUsersCreated     counter
+	ticketsToProcess    counter
+	ticketsProcessed    counter
+	newTicketsCreated   counter
+	ticketOrgsProcessed counter
+	ticketsInFlight     counter
+	notesCreated        counter
+
+	userMigrationErrors   counter
+	ticketMigrationErrors counter
+}
+
+func (s *statistics) reset() {
+	for _, c := range []*counter{
+		&s.orgsChecked, &s.orgsNotInPsa, &s.orgsMigrated, &s.orgsCheckedForUsers, &s.usersProcessed,
+		&s.newUsersCreated, &s.ticketsToProcess, &s.ticketsProcessed, &s.newTicketsCreated,
+		&s.ticketOrgsProcessed, &s.ticketsInFlight, &s.notesCreated, &s.userMigrationErrors, &s.ticketMigrationErrors,
+	} {
+		c.set(0)
+	}
+}
+
+type counter struct {
+	v atomic.Int64
+}
+
+func (c *counter) inc() {
+	c.v.Add(1)
+}
+
+func (c *counter) dec() {
+	c.v.Add(-1)
+}
+
+func (c *counter) get() int {
+	return int(c.v.Load())
+}
+
+func (c *counter) set(n int) {
+	c.v.Store(int64(n))
 }
 
 type viewState struct {
@@ -94,19 +151,25 @@ func newModel(ctx context.Context, client *Client) (*Model, error) {
 
 	slog.Info("time zone set", "timeZone", loc.String())
 
-	return &Model{
-		ctx:                    ctx,
-		client:                 client,
-		data:                   data,
-		status:                 awaitingStart,
-		timeZone:               loc,
-		spinner:                spnr,
-		currentTicketMigration: newActiveTicketMigration("none", ticketStatusGetting),
-	}, nil
+	// the model's context is cancelled when the migration is aborted, stopping any API calls in flight
+	ctx, cancel := context.WithCancel(ctx)
+
+	m := &Model{
+		ctx:            ctx,
+		cancel:         cancel,
+		client:         client,
+		data:           data,
+		status:         awaitingStart,
+		timeZone:       loc,
+		spinner:        spnr,
+		contactPrompts: make(chan *contactPrompt),
+	}
+
+	return m, nil
 }
 
 func (m *Model) Init() tea.Cmd {
-	return m.spinner.Tick
+	return tea.Batch(m.spinner.Tick, m.waitForContactPrompt())
 }
 
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -121,13 +184,56 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.calculateDimensions(msg.Width, msg.Height)
 
 	case tea.KeyMsg:
+		if m.quitPrompt != nil {
+			break
+		}
+
+		// the org picker takes every key but quit, since typing filters it
+		if m.status == pickingOrgs && m.orgPicker != nil && msg.String() != "ctrl+q" {
+			m.orgPicker.update(msg)
+			break
+		}
+
+		// the duplicate contact prompt takes every key but quit too, since it has its own keys and typing filters
+		// its contacts
+		if m.activePrompt != nil && msg.String() != "ctrl+q" {
+			break
+		}
+
+		if msg.String() != "ctrl+q" {
+			if m.search.active {
+				m.search.update(msg)
+				break
+			}
+
+			if cmd, ok := m.handleResultsKey(msg); ok {
+				cmds = append(cmds, cmd)
+				break
+			}
+		}
 
 		switch msg.String() {
 		case "ctrl+q":
-			m.quitting = true
-			cmds = append(cmds, tea.Quit)
+			if m.migrationRunning() && !m.quitAfterFinish {
+				m.quitPrompt = m.quitForm()
+				return m, m.quitPrompt.Init()
+			}
+
+			cmds = append(cmds, m.quit())
+		case "p":
+			if m.migrationRunning() && !m.dispatch.isPaused() && !m.dispatch.isStopped() {
+				slog.Info("pausing ticket dispatch")
+				m.dispatch.pause()
+				m.writeToOutput(warnYellowOutput("PAUSED", "no new tickets will be started until the migration is resumed"), warnOutput)
+			}
+		case "r":
+			if m.dispatch.isPaused() {
+				slog.Info("resuming ticket dispatch")
+				m.dispatch.unpause()
+				m.writeToOutput(goodBlueOutput("RESUMED", "resuming ticket migration"), createdOutput)
+			}
 		case "c":
-			cmds = append(cmds, m.copyToClipboard(m.data.Output.String()))
+			cmds = append(cmds, m.copyToClipboard(m.results()))
 		case " ":
 			if m.status == awaitingStart {
 				return m, switchStatus(gettingTags)
@@ -141,39 +247,73 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.scrollOverride = true
 		}
 
+	case orgDetailsCountedMsg:
+		slog.Debug("initializing org picker")
+		m.countingOrgDetails = false
+		m.orgPicker = newOrgPicker(m.data.AllOrgs.snapshot())
+		return m, switchStatus(pickingOrgs)
+
 	case switchStatusMsg:
+		if m.dispatch.isStopped() && migrationStatus(msg) != m.status {
+			switch migrationStatus(msg) {
+			case gettingUsers, migratingUsers, gettingPsaTickets, migratingTickets:
+				slog.Info("migration stopped - skipping remaining steps", "skippedStatus", migrationStatus(msg))
+				msg = switchStatusMsg(done)
+			}
+		}
+
 		m.status = migrationStatus(msg)
 		switch migrationStatus(msg) {
 		case gettingTags:
 			slog.Debug("getting tags from config")
-			return m, m.getTagDetails()
+			return m, tea.Batch(m.getTagDetails(), dashboardTick())
 		case gettingZendeskOrgs:
 			slog.Debug("getting zendesk orgs")
-			m.statistics = statistics{}
+			m.statistics.reset()
+			m.throughput.reset()
 			return m, m.getOrgs()
 		case comparingOrgs:
 			slog.Debug("comparing orgs")
 			var checkOrgCmds []tea.Cmd
-			for _, org := range m.data.AllOrgs {
+			for _, org := range m.data.AllOrgs.snapshot() {
 				checkOrgCmds = append(checkOrgCmds, m.checkOrg(org))
 			}
 
 			return m, tea.Batch(checkOrgCmds...)
 		case initOrgForm:
-			slog.Debug("initializing org form")
-			m.form = m.orgSelectionForm()
-			cmds = append(cmds, m.form.Init(), switchStatus(pickingOrgs))
-			return m, tea.Sequence(cmds...)
+			if m.client.Cfg.OrgSelection.hasAllowList() {
+				// the status can be switched here more than once before it changes, so orgs are only selected once
+				if m.formComplete {
+					return m, nil
+				}
+
+				slog.Debug("selecting orgs from org selection - skipping org form")
+				m.formComplete = true
+				m.selectConfiguredOrgs()
+				return m, switchStatus(gettingUsers)
+			}
+
+			// the status can be switched here more than once before it changes, so orgs are only counted once
+			switch {
+			case m.orgPicker != nil:
+				return m, switchStatus(pickingOrgs)
+			case m.countingOrgDetails:
+				return m, nil
+			}
+
+			slog.Debug("counting org details for the org picker")
+			m.countingOrgDetails = true
+			return m, m.countPickerOrgDetails()
 		case gettingUsers:
-			if m.errCapture.flag {
+			if m.hasErr() {
 				slog.Debug("stopping migration due to error")
 				return m, nil
 			}
 			slog.Debug("getting users for all selected orgs")
-			m.data.UsersToMigrate = make(map[string]*userMigrationDetails)
+			m.data.UsersToMigrate = newSafeMap[*userMigrationDetails]()
 			var batches []tea.Cmd
 			var currentBatch []tea.Cmd
-			const batchSize = 20
+			batchSize := m.client.Cfg.UserBatchSize
 
 			for _, org := range m.data.SelectedOrgs {
 				currentBatch = append(currentBatch, m.getUsersToMigrate(org))
@@ -189,7 +329,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, tea.Batch(batches...))
 			return m, tea.Sequence(cmds...)
 		case migratingUsers:
-			if m.errCapture.flag {
+			if m.hasErr() {
 				slog.Debug("stopping migration due to error")
 				return m, nil
 			}
@@ -198,35 +338,79 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Sequence(cmds...)
 
 		case gettingPsaTickets:
-			if m.errCapture.flag {
+			if m.hasErr() {
 				slog.Debug("stopping migration due to error")
 				return m, nil
 			}
 			return m, m.getAlreadyMigrated()
 
 		case migratingTickets:
-			if m.errCapture.flag {
+			if m.hasErr() {
 				slog.Debug("stopping migration due to error")
 				return m, nil
 			}
-			for _, org := range m.data.SelectedOrgs {
-				cmds = append(cmds, m.runTicketMigration(org))
+			return m, m.runTicketMigration(m.data.SelectedOrgs)
+
+		case done, errored:
+			m.finishRun()
+			if m.quitAfterFinish {
+				slog.Info("in-flight work complete - exiting")
+				return m, m.quit()
 			}
+		}
 
-			return m, tea.Sequence(cmds...)
+	case dashboardTickMsg:
+		m.throughput.add(throughputSample{at: time.Time(msg), tickets: m.ticketsProcessed.get(), notes: m.notesCreated.get()})
+		if m.status != done && m.status != errored {
+			cmds = append(cmds, dashboardTick())
 		}
 
+	case contactPromptMsg:
+		slog.Debug("prompting for duplicate contact choice", "zendeskUserId", msg.user.ZendeskUser.Id)
+		m.activePrompt = msg
+		m.promptForm = m.contactPromptForm(msg)
+		return m, m.promptForm.Init()
+
 	case fatalErrMsg:
 		slog.Error("fatal error", "error", msg.Err)
 		cmds = append(cmds, switchStatus(done))
 	}
 
+	if m.quitPrompt != nil {
+		form, cmd := m.quitPrompt.Update(msg)
+		cmds = append(cmds, cmd)
+
+		if f, ok := form.(*huh.Form); ok {
+			m.quitPrompt = f
+		}
+
+		if m.quitPrompt.State == huh.StateCompleted {
+			m.quitPrompt = nil
+			cmds = append(cmds, m.handleQuitChoice())
+		}
+	} else if m.activePrompt != nil {
+		form, cmd := m.promptForm.Update(msg)
+		cmds = append(cmds, cmd)
+
+		if f, ok := form.(*huh.Form); ok {
+			m.promptForm = f
+		}
+
+		if m.promptForm.State == huh.StateCompleted {
+			slog.Debug("duplicate contact chosen", "zendeskUserId", m.activePrompt.user.ZendeskUser.Id, "psaContactId", m.promptChoice)
+			m.activePrompt.response <- m.promptChoice
+			m.activePrompt = nil
+			m.promptForm = nil
+			cmds = append(cmds, m.waitForContactPrompt())
+		}
+	}
+
 	m.spinner, cmd = m.spinner.Update(msg)
 	cmds = append(cmds, cmd)
 
 	switch m.status {
 	case comparingOrgs:
-		if len(m.data.AllOrgs) == m.orgsChecked {
+		if m.data.AllOrgs.len() == m.orgsChecked.get() {
 			if m.client.Cfg.StopAfterOrgs {
 				slog.Info("stopping after org check as per configuration")
 				cmds = append(cmds, switchStatus(done))
@@ -236,47 +420,37 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case pickingOrgs:
-		form, cmd := m.form.Update(msg)
-		cmds = append(cmds, cmd)
-
-		if f, ok := form.(*huh.Form); ok {
-			m.form = f
-		}
-
-		if m.form.State == huh.StateCompleted && !m.formComplete {
-			switch m.status {
-			case pickingOrgs:
-				if m.allOrgsSelected {
-					for _, org := range m.data.AllOrgs {
-						if !org.Migrated {
-							continue
-						}
-						m.data.SelectedOrgs = append(m.data.SelectedOrgs, org)
-					}
+		if m.orgPicker != nil && m.orgPicker.done && !m.formComplete {
+			m.data.SelectedOrgs = m.orgPicker.selectedOrgs()
+			slog.Debug("org picker completed, selected orgs", "selectedOrgsCount", len(m.data.SelectedOrgs))
+			if m.orgPicker.save {
+				if err := m.client.saveOrgSelection(m.data.SelectedOrgs); err != nil {
+					slog.Error("saving org selection", "error", err)
+					m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("couldn't save org selection: %s", err)), errOutput)
+				} else {
+					m.writeToOutput(goodBlueOutput("SAVED", fmt.Sprintf("%d selected orgs saved to the config", len(m.data.SelectedOrgs))), createdOutput)
 				}
-
-				slog.Debug("form completed, selected orgs", "selectedOrgsCount", len(m.data.SelectedOrgs))
-				m.formComplete = true
-				cmds = append(cmds, switchStatus(gettingUsers))
 			}
+
+			m.formComplete = true
+			cmds = append(cmds, switchStatus(gettingUsers))
 		}
 
 	case gettingUsers:
-		if len(m.data.SelectedOrgs) == m.orgsCheckedForUsers {
+		if len(m.data.SelectedOrgs) == m.orgsCheckedForUsers.get() {
 			cmds = append(cmds, switchStatus(migratingUsers))
 		}
-
-	case migratingTickets:
-		if len(m.data.SelectedOrgs) == m.ticketOrgsProcessed {
-			cmds = append(cmds, switchStatus(done))
-		}
 	}
 
 	if m.ready {
-		m.viewport.SetContent(m.data.Output.String())
+		m.viewport.SetContent(m.results())
 		m.setAutoScrollBehavior()
-		m.viewport, cmd = m.viewport.Update(msg)
-		cmds = append(cmds, cmd)
+
+		// keys typed into the org picker, a search or the duplicate contact prompt shouldn't scroll the output
+		if _, ok := msg.(tea.KeyMsg); !ok || (m.status != pickingOrgs && !m.search.active && m.activePrompt == nil) {
+			m.viewport, cmd = m.viewport.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 	}
 
 	return m, tea.Batch(cmds...)
@@ -292,53 +466,41 @@ func (m *Model) View() string {
 	}
 
 	var s string
-	switch m.status {
-	case awaitingStart:
-		s += welcomeText()
-	case comparingOrgs:
-		s += m.runSpinner(fmt.Sprintf("Checking organizations (%d/%d)", m.orgsChecked, len(m.data.AllOrgs)))
-	case gettingUsers:
-		s += m.runSpinner(fmt.Sprintf("Getting users for all selected orgs - got %d users", len(m.data.UsersToMigrate)))
-	case migratingUsers:
-		s += m.runSpinner(fmt.Sprintf("Migrating users (%d/%d)", m.usersProcessed, len(m.data.UsersToMigrate)))
-	case pickingOrgs:
-		s += m.form.View()
-	case gettingPsaTickets:
-		s += m.runSpinner("Getting existing tickets from the PSA")
-	case migratingTickets:
-		switch m.currentTicketMigration.status {
-		case ticketStatusGetting:
-			s += m.runSpinner(fmt.Sprintf("Getting Zendesk tickets for org %s", m.currentTicketMigration.orgName))
-		case ticketStatusMigrating:
-			s += m.runSpinner(fmt.Sprintf("Migrating tickets for org %s - %d/%d done", m.currentTicketMigration.orgName, m.currentTicketMigration.ticketsProcessed, m.currentTicketMigration.ticketsToProcess))
+	if m.quitPrompt != nil {
+		s += m.quitPrompt.View()
+	} else if m.activePrompt != nil {
+		s += m.promptForm.View()
+	} else {
+		switch m.status {
+		case awaitingStart:
+			s += welcomeText(m.client.Cfg.Tuning)
+		case comparingOrgs:
+			s += m.runSpinner(fmt.Sprintf("Checking organizations (%d/%d)", m.orgsChecked.get(), m.data.AllOrgs.len()))
+		case gettingUsers:
+			s += m.runSpinner(fmt.Sprintf("Getting users for all selected orgs - got %d users", m.data.UsersToMigrate.len()))
+		case migratingUsers:
+			s += m.runSpinner(fmt.Sprintf("Migrating users (%d/%d)", m.usersProcessed.get(), m.data.UsersToMigrate.len()))
+		case pickingOrgs:
+			s += m.orgPicker.view(m.windowWidth-1, m.verticalLeftForMainView)
+		case gettingPsaTickets:
+			s += m.runSpinner("Getting existing tickets from the PSA")
+		case migratingTickets:
+			s += m.ticketProgressView()
+		case done:
+			s += "Migration complete - press CTRL+Q to exit.\n\nTo run the migration again, exit and run the utility again."
+		case errored:
+			s += fmt.Sprintf("An error occured: %s\n\n", m.capturedErr())
 		default:
-			s += m.runSpinner("Starting ticket migration")
+			s += m.runSpinner(string(m.status))
+		}
+
+		if p := m.pauseStatus(); p != "" && m.migrationRunning() {
+			s += "\n\n" + p
 		}
-	case done:
-		s += "Migration complete - press CTRL+Q to exit.\n\nTo run the migration again, exit and run the utility again."
-	case errored:
-		s += fmt.Sprintf("An error occured: %s\n\n", m.errCapture.err.Error())
-	default:
-		s += m.runSpinner(string(m.status))
 	}
 
 	if m.status != awaitingStart && m.status != pickingOrgs {
-		s += fmt.Sprintf("\n\nUsers Processed: %d\n"+
-			"New Users Created: %d\n"+
-			"Tickets Processed: %d\n"+
-			"New Tickets Created: %d\n"+
-			"Orgs Complete: %d/%d\n"+
-			"Orgs Not in PSA: %d\n"+
-			"User Migration Errors: %d\n"+
-			"Ticket Migration Errors: %d\n",
-			m.usersProcessed,
-			m.newUsersCreated,
-			m.ticketsProcessed,
-			m.newTicketsCreated,
-			m.ticketOrgsProcessed, len(m.data.SelectedOrgs),
-			m.orgsNotInPsa,
-			m.userMigrationErrors,
-			m.ticketMigrationErrors)
+		s += "\n\n" + m.dashboardView(m.verticalLeftForMainView-lipgloss.Height(s)-2) + "\n"
 	}
 
 	mainView := lipgloss.NewStyle().
@@ -352,20 +514,24 @@ func (m *Model) View() string {
 	return lipgloss.JoinVertical(lipgloss.Top, views...)
 }
 
-func welcomeText() string {
+func welcomeText(t Tuning) string {
 	return fmt.Sprintf(`
 This utility will copy all users and tickets from Zendesk to ConnectWise PSA.
 
 Custom fields will be updated in both systems to reflect the migrationStatus of each item; if you run it again, it will only copy new items.
 
-It is recommended to make your terminal as big as possible to see all output, as it will overflow horizontally in the below "Results" section. For full output, press %s to copy to clipboard.
+It is recommended to make your terminal as big as possible to see all output, as it will overflow horizontally in the below "Results" section. For full output, press %s to copy to clipboard, or %s to save every result to a file in the migration folder. Press %s to search the results, and %s to show or hide no action, created, warning and error results.
 
-If you exit in the middle of a migration, there may be incomplete tickets - %s
+Press %s to pause the ticket migration and %s to resume it - tickets already in progress will finish while paused. If you exit in the middle of a migration, you can let in-flight tickets finish first; if you abort, there may be incomplete tickets - %s
 
 Press %s to select organizations and begin the migration. For more options, see the README.
-`, textBlue("C"),
+
+%s
+`, textBlue("C"), textBlue("S"), textBlue("/"), textBlue("1-4"),
+		textBlue("P"), textBlue("R"),
 		textYellow("you will need to delete these before running the utility again."),
-		textBlue("SPACE"))
+		textBlue("SPACE"),
+		t.String())
 }
 
 func (c *Client) getTimeZone() (*time.Location, error) {
@@ -385,11 +551,10 @@ func (c *Client) getTimeZone() (*time.Location, error) {
 
 func (m *Model) copyToClipboard(s string) tea.Cmd {
 	return func() tea.Msg {
-		re := regexp.MustCompile(`\x1B(?:[@-Z\\-_]|\[[0-?]*[ -/]*[@-~])`)
-		plaintext := re.ReplaceAllString(s, "")
+		plaintext := ansiPattern.ReplaceAllString(s, "")
 		if err := clipboard.WriteAll(plaintext); err != nil {
 			slog.Error("copying results to clipboard", "error", err)
-			m.writeToOutput(badRedOutput("ERROR", "couldn't copy results to clipboard"), errOutput)
+			m.writeToOutput(badRedOutput("ERROR", "couldn't copy results to clipboard - press S to save them to a file instead"), errOutput)
 			return nil
 		}
 		slog.Debug("copied result to clipboard")
@@ -398,6 +563,49 @@ func (m *Model) copyToClipboard(s string) tea.Cmd {
 	}
 }
 
+// finishRun writes the end of run reports - it only runs once, since done can be sent more than once, and also
+// runs when the migration is aborted
+func (m *Model) finishRun() {
+	if m.runFinished {
+		return
+	}
+
+	m.runFinished = true
+	m.writeDuplicateContactsReport()
+
+	for _, h := range m.client.metrics.snapshot() {
+		slog.Info("finishRun: api calls", "host", h.Host, "calls", h.Calls, "rateLimited", h.RateLimited)
+	}
+
+	if m.report == nil {
+		return
+	}
+
+	if err := m.report.finish(); err != nil {
+		slog.Error("finishing run report", "error", err)
+		m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("couldn't write run report: %s", err)), errOutput)
+		return
+	}
+
+	m.writeToOutput(goodBlueOutput("REPORT", fmt.Sprintf("run report saved to %s", m.report.dir)), createdOutput)
+}
+
+// hasErr reports whether an error has been captured during the migration
+func (m *Model) hasErr() bool {
+	return m.capturedErr() != nil
+}
+
+func (m *Model) capturedErr() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.errCapture.flag {
+		return nil
+	}
+
+	return m.errCapture.err
+}
+
 func (m *Model) updateErrCapture(err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
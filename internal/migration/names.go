@@ -0,0 +1,171 @@
+package migration
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	psaFirstNameMaxLength = 30
+	psaLastNameMaxLength  = 30
+	psaTitleMaxLength     = 100
+)
+
+// nameParticles are words that start a surname, ie "van der Berg" or "De La Cruz", matched ignoring case
+var nameParticles = map[string]bool{
+	"al": true, "bin": true, "da": true, "das": true, "de": true, "del": true, "della": true, "den": true,
+	"der": true, "di": true, "do": true, "dos": true, "du": true, "el": true, "ibn": true, "la": true,
+	"le": true, "st": true, "st.": true, "ten": true, "ter": true, "van": true, "von": true,
+}
+
+// nameSuffixes are kept at the end of the last name, ie "Smith Jr."
+var nameSuffixes = map[string]bool{
+	"jr": true, "jr.": true, "sr": true, "sr.": true, "ii": true, "iii": true, "iv": true,
+	"phd": true, "ph.d.": true, "md": true, "m.d.": true, "esq": true, "esq.": true, "cpa": true, "dds": true,
+}
+
+type contactName struct {
+	First    string
+	Last     string
+	Original string
+
+	// Changed is true when the first and last name don't read the same as the original name, either from
+	// reordering, deriving the name from an email address or truncating it to fit ConnectWise PSA limits
+	Changed bool
+}
+
+// parseContactName splits a Zendesk user's name into a first and last name that fit ConnectWise PSA's limits.
+// It handles "Last, First" ordering, surname particles, suffixes and names that are actually email addresses.
+// If the name is blank, the local part of the email is used instead.
+func parseContactName(name, email string) contactName {
+	original := strings.Join(strings.Fields(name), " ")
+	cn := contactName{Original: original}
+
+	source := original
+	if source == "" || looksLikeEmail(source) {
+		if source == "" {
+			source = email
+		}
+		source = nameFromEmail(source)
+	}
+
+	cn.First, cn.Last = splitName(source)
+	if cn.First == "" {
+		// ConnectWise PSA requires a first name
+		cn.First, cn.Last = cn.Last, ""
+	}
+
+	var truncated bool
+	cn.First, truncated = truncateRunes(cn.First, psaFirstNameMaxLength)
+	cn.Changed = truncated
+
+	cn.Last, truncated = truncateRunes(cn.Last, psaLastNameMaxLength)
+	cn.Changed = cn.Changed || truncated
+
+	if strings.TrimSpace(cn.First+" "+cn.Last) != original {
+		cn.Changed = true
+	}
+
+	return cn
+}
+
+func splitName(name string) (string, string) {
+	var suffixes []string
+
+	// "Last, First" - anything after the first comma is the first name, unless it's a suffix ("Smith, Jr.")
+	if parts := strings.Split(name, ","); len(parts) > 1 {
+		for len(parts) > 1 && isSuffix(parts[len(parts)-1]) {
+			suffixes = append([]string{strings.TrimSpace(parts[len(parts)-1])}, suffixes...)
+			parts = parts[:len(parts)-1]
+		}
+
+		if len(parts) > 1 {
+			last := strings.TrimSpace(parts[0])
+			first := strings.TrimSpace(strings.Join(parts[1:], " "))
+			return first, joinNonEmpty(last, strings.Join(suffixes, " "))
+		}
+
+		name = parts[0]
+	}
+
+	words := strings.Fields(name)
+	for len(words) > 1 && isSuffix(words[len(words)-1]) {
+		suffixes = append([]string{words[len(words)-1]}, suffixes...)
+		words = words[:len(words)-1]
+	}
+
+	if len(words) == 0 {
+		return "", strings.Join(suffixes, " ")
+	}
+
+	if len(words) == 1 {
+		return words[0], strings.Join(suffixes, " ")
+	}
+
+	// a leading particle means there's no first name at all, ie "van der Berg". Capitalized, it's only read as one in
+	// longer names, since "Al", "Van" and "Del" are also first names, as in "Al Smith" or "Van Morrison".
+	if isParticle(words[0]) && (words[0] == strings.ToLower(words[0]) || len(words) > 2) {
+		return "", strings.Join(append(words, suffixes...), " ")
+	}
+
+	// the surname starts at the first particle after the first word, or is the last word if there isn't one
+	lastStart := len(words) - 1
+	for i := 1; i < len(words)-1; i++ {
+		if isParticle(words[i]) {
+			lastStart = i
+			break
+		}
+	}
+
+	first := strings.Join(words[:lastStart], " ")
+	last := strings.Join(append(words[lastStart:], suffixes...), " ")
+	return first, last
+}
+
+func isParticle(s string) bool {
+	return nameParticles[strings.ToLower(s)]
+}
+
+func isSuffix(s string) bool {
+	return nameSuffixes[strings.ToLower(strings.TrimSpace(s))]
+}
+
+func looksLikeEmail(s string) bool {
+	return strings.Contains(s, "@") && !strings.ContainsAny(s, " \t")
+}
+
+// nameFromEmail turns the local part of an email into a name, ie "john.smith@example.com" becomes "John Smith"
+func nameFromEmail(email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	words := strings.FieldsFunc(local, func(r rune) bool {
+		return r == '.' || r == '_' || r == '-' || r == '+'
+	})
+
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// truncateRunes shortens s to at most n characters, without splitting multibyte characters
+func truncateRunes(s string, n int) (string, bool) {
+	if utf8.RuneCountInString(s) <= n {
+		return s, false
+	}
+
+	return strings.TrimSpace(string([]rune(s)[:n])), true
+}
+
+func joinNonEmpty(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+
+	return strings.Join(nonEmpty, " ")
+}
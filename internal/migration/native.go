@@ -0,0 +1,54 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+)
+
+// The files in a Zendesk account export (Admin Center > Account > Tools > Reports > Export). Despite the .json
+// extension, each has one JSON record per line.
+const (
+	nativeOrgsName    = "organizations.json"
+	nativeUsersName   = "users.json"
+	nativeTicketsName = "tickets.json"
+)
+
+// nativeUser is a user in the account export, which has the user's org rather than a list of identities
+type nativeUser struct {
+	zendesk.User
+	OrganizationId int64 `json:"organization_id"`
+}
+
+// nativeTicket is a ticket in the account export, which has all of its comments
+type nativeTicket struct {
+	zendesk.Ticket
+	Comments []zendesk.Comment `json:"comments"`
+}
+
+// openNativeExport loads a Zendesk account export into a read only source. The export has no identities, so a
+// user's profile email and phone are the only ones used, and no Zendesk custom fields, so links to PSA contacts
+// and companies are only kept for the run.
+func openNativeExport(dir string) (*archiveSource, error) {
+	a := newArchiveSource(dir)
+
+	err := errors.Join(
+		readArchiveFile(dir, nativeOrgsName, func(o archivedOrg) { a.orgs[o.Id] = &o }),
+		readArchiveFile(dir, nativeUsersName, func(u nativeUser) {
+			user := &archivedUser{User: u.User}
+			if u.OrganizationId != 0 {
+				user.OrganizationIds = []int64{u.OrganizationId}
+			}
+			a.users[int64(u.Id)] = user
+		}),
+		readArchiveFile(dir, nativeTicketsName, func(t nativeTicket) {
+			a.tickets[int64(t.Id)] = t.Ticket
+			a.comments[int64(t.Id)] = t.Comments
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading zendesk export: %w", err)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,409 @@
+package migration
+
+import (
+	"cmp"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// orgPickerHeaderLines is the lines the org picker uses above and below its table: the title, the filter, the
+// table's header and borders, and the help line
+const orgPickerHeaderLines = 7
+
+var (
+	pickerHeaderStyle   = lipgloss.NewStyle().Bold(true).Padding(0, 1)
+	pickerCellStyle     = lipgloss.NewStyle().Padding(0, 1)
+	pickerSelectedStyle = pickerCellStyle.Foreground(lipgloss.Color("4"))
+	pickerNotReadyStyle = pickerCellStyle.Foreground(lipgloss.Color("240"))
+)
+
+// orgPickerColumn is a column of the org picker, which can be sorted on
+type orgPickerColumn struct {
+	title string
+	value func(org *orgMigrationDetails) string
+	cmp   func(a, b *orgMigrationDetails) int
+}
+
+var orgPickerColumns = []orgPickerColumn{
+	{
+		title: "Zendesk Org",
+		value: func(o *orgMigrationDetails) string { return o.ZendeskOrg.Name },
+		cmp: func(a, b *orgMigrationDetails) int {
+			return cmp.Compare(strings.ToLower(a.ZendeskOrg.Name), strings.ToLower(b.ZendeskOrg.Name))
+		},
+	},
+	{
+		title: "PSA Company",
+		value: func(o *orgMigrationDetails) string { return pickerCompany(o) },
+		cmp: func(a, b *orgMigrationDetails) int {
+			return cmp.Compare(strings.ToLower(pickerCompany(a)), strings.ToLower(pickerCompany(b)))
+		},
+	},
+	{
+		title: "Tag",
+		value: func(o *orgMigrationDetails) string { return pickerTag(o) },
+		cmp:   func(a, b *orgMigrationDetails) int { return cmp.Compare(pickerTag(a), pickerTag(b)) },
+	},
+	{
+		title: "Date Range",
+		value: func(o *orgMigrationDetails) string { return pickerDateRange(o) },
+		cmp:   func(a, b *orgMigrationDetails) int { return cmp.Compare(pickerDateRange(a), pickerDateRange(b)) },
+	},
+	{
+		title: "Tickets",
+		value: func(o *orgMigrationDetails) string { return pickerCount(o.ZendeskTickets) },
+		cmp:   func(a, b *orgMigrationDetails) int { return cmp.Compare(a.ZendeskTickets, b.ZendeskTickets) },
+	},
+	{
+		title: "In PSA",
+		value: func(o *orgMigrationDetails) string { return pickerReadyCount(o, o.PsaTickets) },
+		cmp:   func(a, b *orgMigrationDetails) int { return cmp.Compare(a.PsaTickets, b.PsaTickets) },
+	},
+	{
+		title: "Users",
+		value: func(o *orgMigrationDetails) string { return pickerReadyCount(o, o.ZendeskUsers) },
+		cmp:   func(a, b *orgMigrationDetails) int { return cmp.Compare(a.ZendeskUsers, b.ZendeskUsers) },
+	},
+	{
+		title: "Status",
+		value: func(o *orgMigrationDetails) string { return pickerStatus(o) },
+		cmp:   func(a, b *orgMigrationDetails) int { return cmp.Compare(pickerStatus(a), pickerStatus(b)) },
+	},
+}
+
+// orgPicker is a table of every checked org, which can be filtered by typing and sorted by any column. Only orgs
+// ready for migration can be selected - the rest are greyed out with the reason they aren't ready.
+type orgPicker struct {
+	orgs     []*orgMigrationDetails
+	visible  []*orgMigrationDetails
+	selected map[int64]bool
+
+	filter   string
+	sortCol  int
+	sortDesc bool
+	cursor   int
+	offset   int
+
+	// save is whether the selection is saved to the config when it's confirmed
+	save bool
+	done bool
+	msg  string
+}
+
+// newOrgPicker returns a picker with every org ready for migration selected
+func newOrgPicker(orgs map[string]*orgMigrationDetails) *orgPicker {
+	p := &orgPicker{selected: make(map[int64]bool)}
+	for _, org := range orgs {
+		p.orgs = append(p.orgs, org)
+		if orgReady(org) {
+			p.selected[org.ZendeskOrg.Id] = true
+		}
+	}
+
+	p.refresh()
+	return p
+}
+
+func orgReady(org *orgMigrationDetails) bool {
+	return org.Migrated && (org.PsaOrg == nil || !org.PsaOrg.DeletedFlag)
+}
+
+// refresh filters and sorts the orgs again, keeping the cursor on the same org if it's still shown
+func (p *orgPicker) refresh() {
+	var current *orgMigrationDetails
+	if p.cursor < len(p.visible) {
+		current = p.visible[p.cursor]
+	}
+
+	terms := strings.Fields(strings.ToLower(p.filter))
+	p.visible = p.visible[:0]
+	for _, org := range p.orgs {
+		if orgMatchesFilter(org, terms) {
+			p.visible = append(p.visible, org)
+		}
+	}
+
+	col := orgPickerColumns[p.sortCol]
+	slices.SortStableFunc(p.visible, func(a, b *orgMigrationDetails) int {
+		c := col.cmp(a, b)
+		if c == 0 {
+			c = orgPickerColumns[0].cmp(a, b)
+		}
+
+		if p.sortDesc {
+			return -c
+		}
+		return c
+	})
+
+	p.cursor = 0
+	if i := slices.Index(p.visible, current); i >= 0 {
+		p.cursor = i
+	}
+}
+
+// orgMatchesFilter reports whether every filter term is in the org's name, company, tag or status
+func orgMatchesFilter(org *orgMigrationDetails, terms []string) bool {
+	text := strings.ToLower(strings.Join([]string{org.ZendeskOrg.Name, pickerCompany(org), pickerTag(org), pickerStatus(org)}, " "))
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *orgPicker) update(msg tea.KeyMsg) {
+	p.msg = ""
+	switch msg.String() {
+	case "up", "ctrl+p":
+		p.moveCursor(-1)
+	case "down", "ctrl+n":
+		p.moveCursor(1)
+	case "pgup":
+		p.moveCursor(-10)
+	case "pgdown":
+		p.moveCursor(10)
+	case "home":
+		p.moveCursor(-len(p.visible))
+	case "end":
+		p.moveCursor(len(p.visible))
+	case "left":
+		p.sortCol = (p.sortCol + len(orgPickerColumns) - 1) % len(orgPickerColumns)
+		p.refresh()
+	case "right":
+		p.sortCol = (p.sortCol + 1) % len(orgPickerColumns)
+		p.refresh()
+	case "ctrl+r":
+		p.sortDesc = !p.sortDesc
+		p.refresh()
+	case "tab":
+		if p.cursor < len(p.visible) {
+			p.toggle(p.visible[p.cursor])
+			p.moveCursor(1)
+		}
+	case "ctrl+a":
+		p.toggleAllVisible()
+	case "ctrl+s":
+		p.save = !p.save
+	case "esc":
+		p.filter = ""
+		p.refresh()
+	case "backspace":
+		if r := []rune(p.filter); len(r) > 0 {
+			p.filter = string(r[:len(r)-1])
+			p.refresh()
+		}
+	case "enter":
+		if len(p.selectedOrgs()) == 0 {
+			p.msg = "Select at least one org to migrate"
+			return
+		}
+		p.done = true
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			p.filter += string(msg.Runes)
+			p.refresh()
+		}
+	}
+}
+
+func (p *orgPicker) moveCursor(n int) {
+	p.cursor = max(0, min(p.cursor+n, len(p.visible)-1))
+}
+
+func (p *orgPicker) toggle(org *orgMigrationDetails) {
+	if !orgReady(org) {
+		p.msg = fmt.Sprintf("%s can't be migrated: %s", org.ZendeskOrg.Name, pickerStatus(org))
+		return
+	}
+
+	p.selected[org.ZendeskOrg.Id] = !p.selected[org.ZendeskOrg.Id]
+}
+
+// toggleAllVisible selects every ready org shown, or unselects them if they're all selected already
+func (p *orgPicker) toggleAllVisible() {
+	all := true
+	for _, org := range p.visible {
+		if orgReady(org) && !p.selected[org.ZendeskOrg.Id] {
+			all = false
+			break
+		}
+	}
+
+	for _, org := range p.visible {
+		if orgReady(org) {
+			p.selected[org.ZendeskOrg.Id] = !all
+		}
+	}
+}
+
+// selectedOrgs returns the selected orgs, sorted by name
+func (p *orgPicker) selectedOrgs() []*orgMigrationDetails {
+	var orgs []*orgMigrationDetails
+	for _, org := range p.orgs {
+		if p.selected[org.ZendeskOrg.Id] && orgReady(org) {
+			orgs = append(orgs, org)
+		}
+	}
+
+	slices.SortFunc(orgs, orgPickerColumns[0].cmp)
+	return orgs
+}
+
+func (p *orgPicker) view(width, height int) string {
+	rows := max(1, height-orgPickerHeaderLines)
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	} else if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+	p.offset = max(0, min(p.offset, len(p.visible)-rows))
+
+	end := min(len(p.visible), p.offset+rows)
+	shown := p.visible[p.offset:end]
+
+	headers := []string{""}
+	for i, col := range orgPickerColumns {
+		title := col.title
+		if i == p.sortCol {
+			title += map[bool]string{false: " ▲", true: " ▼"}[p.sortDesc]
+		}
+		headers = append(headers, title)
+	}
+
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderColumn(false).
+		BorderLeft(false).
+		BorderRight(false).
+		Headers(headers...).
+		Wrap(false).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return pickerHeaderStyle
+			}
+
+			org := shown[row]
+			style := pickerCellStyle
+			switch {
+			case !orgReady(org):
+				style = pickerNotReadyStyle
+			case p.selected[org.ZendeskOrg.Id]:
+				style = pickerSelectedStyle
+			}
+
+			if p.offset+row == p.cursor {
+				style = style.Reverse(true)
+			}
+
+			return style
+		})
+
+	if width > 0 {
+		t = t.Width(width)
+	}
+
+	for _, org := range shown {
+		mark := " "
+		if p.selected[org.ZendeskOrg.Id] && orgReady(org) {
+			mark = "✓"
+		}
+
+		row := []string{mark}
+		for _, col := range orgPickerColumns {
+			row = append(row, col.value(org))
+		}
+		t = t.Row(row...)
+	}
+
+	save := "no"
+	if p.save {
+		save = textBlue("yes")
+	}
+
+	s := fmt.Sprintf("Select the orgs to migrate - %d of %d ready orgs selected, showing %d of %d orgs | Save selection to config: %s\n",
+		len(p.selectedOrgs()), p.readyCount(), len(p.visible), len(p.orgs), save)
+	s += fmt.Sprintf("Filter: %s\n", p.filter+textBlue("█"))
+	s += t.Render() + "\n"
+
+	if p.msg != "" {
+		s += textYellow(p.msg)
+	} else {
+		s += "Type to filter | ↑/↓: Move | TAB: Select | CTRL+A: Select all shown | ←/→: Sort column | CTRL+R: Reverse sort | CTRL+S: Save selection | ENTER: Start"
+	}
+
+	return s
+}
+
+func (p *orgPicker) readyCount() int {
+	var n int
+	for _, org := range p.orgs {
+		if orgReady(org) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func pickerCompany(org *orgMigrationDetails) string {
+	if org.PsaOrg == nil {
+		return ""
+	}
+
+	return org.PsaOrg.Name
+}
+
+func pickerTag(org *orgMigrationDetails) string {
+	if org.Tag == nil {
+		return ""
+	}
+
+	return org.Tag.Name
+}
+
+func pickerDateRange(org *orgMigrationDetails) string {
+	if org.Tag == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s to %s", org.Tag.StartDate.Format(archiveDateLayout), org.Tag.EndDate.Format(archiveDateLayout))
+}
+
+// pickerCount shows a count, or ? if it couldn't be found
+func pickerCount(n int) string {
+	if n < 0 {
+		return "?"
+	}
+
+	return strconv.Itoa(n)
+}
+
+// pickerReadyCount shows a count that's only found for orgs ready for migration
+func pickerReadyCount(org *orgMigrationDetails, n int) string {
+	if !orgReady(org) {
+		return ""
+	}
+
+	return pickerCount(n)
+}
+
+func pickerStatus(org *orgMigrationDetails) string {
+	switch {
+	case orgReady(org):
+		return "ready"
+	case org.PsaOrg != nil && org.PsaOrg.DeletedFlag:
+		return "company is marked as deleted in PSA"
+	case org.NotReadyReason != "":
+		return org.NotReadyReason
+	default:
+		return "not checked"
+	}
+}
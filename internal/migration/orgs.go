@@ -4,11 +4,10 @@ import (
 	"errors"
 	"fmt"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/huh"
 	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
 	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
 	"log/slog"
-	"sort"
+	"sync"
 	"time"
 )
 
@@ -67,32 +66,38 @@ func (m *Model) getOrgs() tea.Cmd {
 			}
 
 			for _, org := range orgs {
-				idString := fmt.Sprintf("%d", org.Id)
-				if _, ok := m.data.AllOrgs[idString]; !ok {
-					slog.Debug("adding org to migration data", "zendeskOrgId", idString, "orgName", org.Name)
+				if !m.client.Cfg.OrgSelection.includes(&org) {
+					slog.Debug("org not in org selection", "zendeskOrgId", org.Id, "orgName", org.Name)
+					continue
+				}
 
-					md := &orgMigrationDetails{
-						ZendeskOrg: &org,
-						Tag:        &tag,
-					}
+				idString := fmt.Sprintf("%d", org.Id)
+				md := &orgMigrationDetails{
+					ZendeskOrg: &org,
+					Tag:        &tag,
+				}
 
-					m.data.AllOrgs[idString] = md
+				if _, loaded := m.data.AllOrgs.loadOrStore(idString, md); !loaded {
+					slog.Debug("adding org to migration data", "zendeskOrgId", idString, "orgName", org.Name)
 				} else {
 					slog.Debug("org already in migration data", "zendeskOrgId", org.Id, "orgName", org.Name)
 				}
 			}
 		}
 
+		m.warnUnmatchedOrgs()
 		return switchStatusMsg(comparingOrgs)
 	}
 }
 
 func (m *Model) checkOrg(org *orgMigrationDetails) tea.Cmd {
 	return func() tea.Msg {
+		started := time.Now()
 		if org.Migrated {
 			slog.Debug("org already migrated", "orgName", org.ZendeskOrg.Name)
-			m.orgsChecked++
-			m.orgsMigrated++
+			m.orgsChecked.inc()
+			m.orgsMigrated.inc()
+			m.recordOrg(org, actionMatched, "already matched to a PSA company", started)
 			return nil
 		}
 
@@ -106,19 +111,25 @@ func (m *Model) checkOrg(org *orgMigrationDetails) tea.Cmd {
 			q.GetOpenTickets = true
 		}
 
-		tickets, err := m.client.ZendeskClient.GetTicketsWithQuery(m.ctx, q, 20, 1)
+		tickets, err := m.client.ZendeskClient.CountTicketsWithQuery(m.ctx, q)
 		if err != nil {
 			slog.Error("getting tickets for org", "orgName", org.ZendeskOrg.Name, "error", err)
 			m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("couldn't get tickets for org %s: %s", org.ZendeskOrg.Name, err)), errOutput)
 			m.updateErrCapture(err)
-			m.orgsChecked++
+			m.orgsChecked.inc()
+			org.NotReadyReason = "couldn't get tickets"
+			m.recordFailed(orgRecord(org, actionFailed, fmt.Sprintf("getting tickets: %s", err), started), err)
 			return nil
 		}
 
-		if len(tickets) == 0 {
+		org.ZendeskTickets = tickets
+		org.HasTickets = tickets > 0
+		if tickets == 0 {
 			// We only care about orgs with tickets - no need to check further
 			slog.Debug("org has no tickets", "orgName", org.ZendeskOrg.Name)
-			m.orgsChecked++
+			m.orgsChecked.inc()
+			org.NotReadyReason = "no tickets in date range"
+			m.recordOrg(org, actionSkipped, org.NotReadyReason, started)
 			return nil
 		}
 
@@ -126,15 +137,19 @@ func (m *Model) checkOrg(org *orgMigrationDetails) tea.Cmd {
 		if err != nil {
 			slog.Warn("org is not in PSA", "orgName", org.ZendeskOrg.Name)
 			m.writeToOutput(warnYellowOutput("WARNING", fmt.Sprintf("org not in PSA: %s", org.ZendeskOrg.Name)), warnOutput)
-			m.orgsChecked++
-			m.orgsNotInPsa++
+			m.orgsChecked.inc()
+			m.orgsNotInPsa.inc()
+			org.NotReadyReason = "no company with a matching name in PSA"
+			m.recordOrg(org, actionSkipped, org.NotReadyReason, started)
 			return nil
 		}
 
 		if err := m.updateCompanyFieldValue(org); err != nil {
 			slog.Error("updating company field value in zendesk", "orgName", org.ZendeskOrg.Name, "zendeskOrgId", org.ZendeskOrg.Id, "error", err)
 			m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("couldn't update PSA company field value for org %s: %s", org.ZendeskOrg.Name, err)), errOutput)
-			m.orgsChecked++
+			m.orgsChecked.inc()
+			org.NotReadyReason = "couldn't link to the PSA company in Zendesk"
+			m.recordFailed(orgRecord(org, actionFailed, fmt.Sprintf("updating PSA company field: %s", err), started), err)
 			return nil
 		}
 
@@ -143,25 +158,76 @@ func (m *Model) checkOrg(org *orgMigrationDetails) tea.Cmd {
 			if org.PsaOrg != nil && org.PsaOrg.DeletedFlag {
 				slog.Warn("org is marked as deleted in PSA", "orgName", org.ZendeskOrg.Name)
 				m.writeToOutput(warnYellowOutput("WARNING", fmt.Sprintf("org is marked as deleted in PSA: %s", org.ZendeskOrg.Name)), warnOutput)
-				m.orgsChecked++
-				m.orgsNotInPsa++
+				m.orgsChecked.inc()
+				m.orgsNotInPsa.inc()
+				org.NotReadyReason = "company is marked as deleted in PSA"
+				m.recordOrg(org, actionSkipped, org.NotReadyReason, started)
 				return nil
 			}
 
 			if org.ZendeskOrg.OrganizationFields.PSACompanyId == int64(org.PsaOrg.Id) {
 				slog.Info("org ready for migration", "orgName", org.ZendeskOrg.Name, "zendeskOrgId", org.ZendeskOrg.Id)
-				m.orgsChecked++
-				m.orgsMigrated++
+				m.orgsChecked.inc()
+				m.orgsMigrated.inc()
 				org.Migrated = true
+				m.recordOrg(org, actionMatched, "matched to PSA company by name", started)
 				return nil
 			}
 		}
 
-		m.orgsChecked++
+		m.orgsChecked.inc()
+		org.NotReadyReason = "not ready for migration"
+		m.recordOrg(org, actionSkipped, org.NotReadyReason, started)
 		return nil
 	}
 }
 
+type orgDetailsCountedMsg struct{}
+
+// countPickerOrgDetails counts the details of every org ready for migration, for the org picker. They're only
+// counted once the picker is about to be shown, since a run that selects orgs from the config never needs them.
+// As many orgs are counted at once as the user batch size.
+func (m *Model) countPickerOrgDetails() tea.Cmd {
+	return func() tea.Msg {
+		sem := make(chan struct{}, max(m.client.Cfg.UserBatchSize, 1))
+		var wg sync.WaitGroup
+		for _, org := range m.data.AllOrgs.snapshot() {
+			if !org.Migrated {
+				continue
+			}
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				m.countOrgDetails(org)
+			}()
+		}
+
+		wg.Wait()
+		return orgDetailsCountedMsg{}
+	}
+}
+
+// countOrgDetails counts the org's Zendesk users and the tickets already migrated to its PSA company, for the org
+// picker. A count that fails is logged and left as -1, since the org can still be migrated.
+func (m *Model) countOrgDetails(org *orgMigrationDetails) {
+	users, err := m.client.ZendeskClient.GetOrganizationUserCount(m.ctx, org.ZendeskOrg.Id)
+	if err != nil {
+		slog.Warn("countOrgDetails: couldn't count zendesk users", "orgName", org.ZendeskOrg.Name, "error", err)
+		users = -1
+	}
+	org.ZendeskUsers = users
+
+	psaTickets, err := m.client.CwClient.CountMigratedTickets(m.ctx, m.data.PsaInfo.ZendeskTicketIdField.Id, org.PsaOrg.Id)
+	if err != nil {
+		slog.Warn("countOrgDetails: couldn't count psa tickets", "orgName", org.ZendeskOrg.Name, "error", err)
+		psaTickets = -1
+	}
+	org.PsaTickets = psaTickets
+}
+
 func (m *Model) updateCompanyFieldValue(org *orgMigrationDetails) error {
 	if org.ZendeskOrg.OrganizationFields.PSACompanyId == int64(org.PsaOrg.Id) {
 		slog.Debug("zendesk org already has PSA company id field", "orgName", org.ZendeskOrg.Name, "zendeskOrgId", org.ZendeskOrg.Id, "psaCompanyId", org.ZendeskOrg.OrganizationFields.PSACompanyId)
@@ -194,52 +260,6 @@ func (m *Model) matchZdOrgToCwCompany(org *zendesk.Organization) (*psa.Company,
 	return comp, nil
 }
 
-func (m *Model) orgSelectionForm() *huh.Form {
-	return huh.NewForm(
-
-		huh.NewGroup(
-			huh.NewSelect[bool]().
-				Title("Migrate all confirmed orgs?").
-				Description("If not, select the organizations you want to migrate on the next screen.").
-				Options(
-					huh.NewOption("All Orgs", true),
-					huh.NewOption("Select Orgs", false)).
-				Value(&m.allOrgsSelected),
-		),
-		huh.NewGroup(
-			huh.NewMultiSelect[*orgMigrationDetails]().
-				Title("Pick the orgs you'd like to migrate users for").
-				Description("Use Space to select, and Enter/Return to submit").
-				Options(m.orgOptions()...).
-				Value(&m.data.SelectedOrgs),
-		).WithHideFunc(func() bool { return m.allOrgsSelected == true }),
-	).WithHeight(m.verticalLeftForMainView).WithShowHelp(false).WithTheme(customFormTheme())
-}
-
-func (m *Model) orgOptions() []huh.Option[*orgMigrationDetails] {
-	var orgOptions []huh.Option[*orgMigrationDetails]
-	for _, org := range m.data.AllOrgs {
-		if org.PsaOrg != nil && org.PsaOrg.DeletedFlag {
-			continue
-		}
-
-		if org.Migrated {
-			opt := huh.Option[*orgMigrationDetails]{
-				Key:   org.ZendeskOrg.Name,
-				Value: org,
-			}
-
-			orgOptions = append(orgOptions, opt)
-		}
-	}
-
-	sort.Slice(orgOptions, func(i, j int) bool {
-		return orgOptions[i].Key < orgOptions[j].Key
-	})
-
-	return orgOptions
-}
-
 func convertStringToTime(details *timeConversionDetails) (time.Time, time.Time, error) {
 	var startDate, endDate time.Time
 	var err error
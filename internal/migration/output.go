@@ -0,0 +1,245 @@
+package migration
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resultsFilePrefix starts the name of each results file saved to the migration directory, followed by the time
+const resultsFilePrefix = "results_"
+
+// ansiPattern matches the terminal escape codes used to color the results
+var ansiPattern = regexp.MustCompile(`\x1B(?:[@-Z\\-_]|\[[0-?]*[ -/]*[@-~])`)
+
+// outputLevelKeys are the keys that show or hide each output level in the results, in order
+var outputLevelKeys = []struct {
+	key   string
+	level outputLevel
+	name  string
+}{
+	{"1", noActionOutput, "no action"},
+	{"2", createdOutput, "created"},
+	{"3", warnOutput, "warnings"},
+	{"4", errOutput, "errors"},
+}
+
+// outputEntry is one message in the results, with its level, its text without colors for saving and that text in
+// lowercase for searching
+type outputEntry struct {
+	level outputLevel
+	text  string
+	plain string
+	lower string
+}
+
+// outputBuffer holds every results message, whatever output levels are shown, so levels can be turned on and off
+// while the utility runs. It's written by many goroutines at once.
+type outputBuffer struct {
+	mu      sync.Mutex
+	entries []outputEntry
+
+	// view is the last filtered results, which new messages are added to until the levels or search change, so the
+	// results aren't filtered from the start on every render
+	view *filteredView
+}
+
+type filteredView struct {
+	levels  OutputLevels
+	query   string
+	entries int
+	text    strings.Builder
+}
+
+func (o *outputBuffer) write(s string, level outputLevel) {
+	plain := ansiPattern.ReplaceAllString(s, "")
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries = append(o.entries, outputEntry{level: level, text: s, plain: plain, lower: strings.ToLower(plain)})
+}
+
+// String returns every message, at every level
+func (o *outputBuffer) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var b strings.Builder
+	for _, e := range o.entries {
+		b.WriteString(e.text)
+	}
+	return b.String()
+}
+
+// plainText returns every message, at every level, without colors
+func (o *outputBuffer) plainText() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var b strings.Builder
+	for _, e := range o.entries {
+		b.WriteString(e.plain)
+	}
+	return b.String()
+}
+
+// filtered returns the messages at the shown levels that contain the query, ignoring case
+func (o *outputBuffer) filtered(levels OutputLevels, query string) string {
+	query = strings.ToLower(query)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.view == nil || o.view.levels != levels || o.view.query != query {
+		o.view = &filteredView{levels: levels, query: query}
+	}
+
+	for _, e := range o.entries[o.view.entries:] {
+		if levels.shows(e.level) && (query == "" || strings.Contains(e.lower, query)) {
+			o.view.text.WriteString(e.text)
+		}
+	}
+	o.view.entries = len(o.entries)
+
+	return o.view.text.String()
+}
+
+func (l OutputLevels) shows(level outputLevel) bool {
+	switch level {
+	case noActionOutput:
+		return l.NoAction
+	case createdOutput:
+		return l.Created
+	case warnOutput:
+		return l.Warn
+	case errOutput:
+		return l.Error
+	default:
+		return false
+	}
+}
+
+func (l *OutputLevels) toggle(level outputLevel) {
+	switch level {
+	case noActionOutput:
+		l.NoAction = !l.NoAction
+	case createdOutput:
+		l.Created = !l.Created
+	case warnOutput:
+		l.Warn = !l.Warn
+	case errOutput:
+		l.Error = !l.Error
+	}
+}
+
+// resultsSearch is the search typed after pressing / - while it's active, keys go to the search instead of running
+// commands
+type resultsSearch struct {
+	query  string
+	active bool
+}
+
+func (s *resultsSearch) update(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		s.query = ""
+		s.active = false
+	case "enter":
+		s.active = false
+	case "backspace":
+		if r := []rune(s.query); len(r) > 0 {
+			s.query = string(r[:len(r)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			s.query += string(msg.Runes)
+		}
+	}
+}
+
+// results returns the results shown: the messages at the output levels turned on that match the search
+func (m *Model) results() string {
+	return m.data.Output.filtered(m.client.Cfg.OutputLevels, m.search.query)
+}
+
+// handleResultsKey toggles an output level, starts a search or saves the results, and reports whether the key was
+// one of those
+func (m *Model) handleResultsKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch msg.String() {
+	case "/":
+		m.search.active = true
+		return nil, true
+	case "s":
+		return m.saveResults(), true
+	}
+
+	for _, k := range outputLevelKeys {
+		if msg.String() == k.key {
+			m.client.Cfg.OutputLevels.toggle(k.level)
+			slog.Debug("toggled output level", "level", k.level, "shown", m.client.Cfg.OutputLevels.shows(k.level))
+			return nil, true
+		}
+	}
+
+	return nil, false
+}
+
+// resultsTitle is the title of the results divider, showing the search and any hidden output levels
+func (m *Model) resultsTitle() string {
+	t := "Results"
+
+	var hidden []string
+	for _, k := range outputLevelKeys {
+		if !m.client.Cfg.OutputLevels.shows(k.level) {
+			hidden = append(hidden, k.name)
+		}
+	}
+
+	if len(hidden) > 0 {
+		t += " | Hiding: " + strings.Join(hidden, ", ")
+	}
+
+	switch {
+	case m.search.active:
+		t += fmt.Sprintf(" | Search: %s█ (ENTER: Done, ESC: Clear)", m.search.query)
+	case m.search.query != "":
+		t += fmt.Sprintf(" | Search: %s (/: Edit)", m.search.query)
+	}
+
+	return t
+}
+
+// saveResults writes every results message, whatever is shown, to a timestamped file in the migration directory
+func (m *Model) saveResults() tea.Cmd {
+	return func() tea.Msg {
+		path, err := writeResultsFile(m.migrationDir, m.data.Output.plainText(), time.Now())
+		if err != nil {
+			slog.Error("saving results to file", "error", err)
+			m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("couldn't save results: %s", err)), errOutput)
+			return nil
+		}
+
+		slog.Info("saved results to file", "path", path)
+		m.writeToOutput(goodGreenOutput("SAVED", fmt.Sprintf("results saved to %s", path)), createdOutput)
+		return nil
+	}
+}
+
+func writeResultsFile(dir, results string, at time.Time) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("no migration directory to save to")
+	}
+
+	path := filepath.Join(dir, resultsFilePrefix+at.Format(runDirTimeLayout)+".txt")
+	if err := os.WriteFile(path, []byte(results), 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
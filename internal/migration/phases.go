@@ -0,0 +1,345 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ErrMigrationFailures is returned by the headless phases when any user or ticket failed to migrate. The failures
+// are in the run report, and can be retried.
+var ErrMigrationFailures = errors.New("some users or tickets failed to migrate")
+
+// MatchOrgs checks every org with a tag in the config for a PSA company with the same name, and links each match in
+// Zendesk so later phases can find it. It runs without the terminal interface.
+func MatchOrgs(opts CliOptions) error {
+	return runPhase(opts, "orgs match", (*Model).matchOrgs, func(m *Model) string {
+		return fmt.Sprintf("Orgs checked: %d | Matched: %d | Not in PSA: %d", m.orgsChecked.get(), m.orgsMigrated.get(), m.orgsNotInPsa.get())
+	})
+}
+
+// MigrateUsers matches or creates a PSA contact for every user in the orgs already linked to a PSA company. It runs
+// without the terminal interface.
+func MigrateUsers(opts CliOptions) error {
+	return runPhase(opts, "users migrate", (*Model).migrateLinkedUsers, func(m *Model) string {
+		return fmt.Sprintf("Orgs: %d | Users processed: %d (%d errors) | Contacts created: %d",
+			len(m.data.SelectedOrgs), m.usersProcessed.get(), m.userMigrationErrors.get(), m.newUsersCreated.get())
+	})
+}
+
+// MigrateTickets migrates the tickets of every org already linked to a PSA company, using the contacts linked by
+// the users phase. It runs without the terminal interface.
+func MigrateTickets(opts CliOptions) error {
+	return runPhase(opts, "tickets migrate", (*Model).migrateLinkedTickets, func(m *Model) string {
+		return fmt.Sprintf("Orgs: %d | Tickets created: %d (%d errors)",
+			len(m.data.SelectedOrgs), m.newTicketsCreated.get(), m.ticketMigrationErrors.get())
+	})
+}
+
+// MapAgents matches each Zendesk agent to a PSA member by email and saves the mappings in the config
+func MapAgents(opts CliOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := configStartup(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	if err := client.processAgentMappings(ctx); err != nil {
+		return fmt.Errorf("processing agent mappings: %w", err)
+	}
+
+	fmt.Printf("%d agent mappings saved to the config\n", len(client.Cfg.AgentMappings))
+	return nil
+}
+
+// SetupFields finds or creates the Zendesk custom fields that link users and orgs to PSA contacts and companies,
+// and saves their IDs in the config
+func SetupFields(opts CliOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := configStartup(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	if client.Cfg.ZendeskExport != "" {
+		return errors.New("custom fields can't be set up when reading from a zendesk export")
+	}
+
+	if err := client.processZendeskPsaFields(ctx); err != nil {
+		return fmt.Errorf("processing zendesk custom fields: %w", err)
+	}
+
+	fmt.Printf("Zendesk custom fields saved to the config - PSA company: %d, PSA contact: %d\n",
+		client.Cfg.Zendesk.FieldIds.PsaCompanyId, client.Cfg.Zendesk.FieldIds.PsaContactId)
+	return nil
+}
+
+// ValidateConfig checks the config and the API connections, and reports anything the other phases would ask for,
+// without asking for it or changing anything
+func ValidateConfig(opts CliOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := configStartup(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	missing := client.Cfg.missingPostClientValues()
+	if len(missing) > 0 {
+		fmt.Println("The config is missing:")
+		for _, v := range missing {
+			fmt.Printf("  - %s\n", v)
+		}
+		return errors.New("config is incomplete")
+	}
+
+	fmt.Println("Config is valid and both API connections are working")
+	return nil
+}
+
+// missingPostClientValues lists the config values set by validatePostClient, with the command that sets each one
+func (cfg *Config) missingPostClientValues() []string {
+	var missing []string
+	if cfg.ZendeskExport == "" && cfg.validateZendeskCustomFields() != nil {
+		missing = append(missing, "Zendesk custom field IDs (run fields setup)")
+	}
+
+	if cfg.validateConnectwiseBoardId() != nil {
+		missing = append(missing, "ConnectWise destination board (run the migrator to choose one)")
+	}
+
+	if cfg.validateConnectwiseBoardType() != nil {
+		missing = append(missing, "ConnectWise ticket type (run the migrator to choose one)")
+	}
+
+	if cfg.validateConnectwiseStatuses() != nil {
+		missing = append(missing, "ConnectWise open and closed statuses (run the migrator to choose them)")
+	}
+
+	if len(cfg.AgentMappings) == 0 {
+		missing = append(missing, "agent mappings (run agents map)")
+	}
+
+	return missing
+}
+
+// configStartup is startup for the commands that only set up or check the config. It validates what it can without
+// the APIs and tests both connections, but doesn't ask for anything missing.
+func configStartup(ctx context.Context, opts CliOptions) (*Client, error) {
+	dir, err := startLogging(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := InitConfig(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize config: %w", err)
+	}
+	addConfigSecrets(cfg)
+
+	cfg.CliOptions = opts
+	cfg.Tuning = cfg.Tuning.withOverrides(opts.TuningOverrides).withDefaults()
+	slog.Info("config startup options", "opts", opts, "tuning", cfg.Tuning)
+
+	if err := cfg.validatePreClient(); err != nil {
+		return nil, fmt.Errorf("validating config: %w", err)
+	}
+
+	client, err := newClient(cfg.Zendesk.Creds, cfg.Connectwise.Creds, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := localZendeskSource(opts)
+	if err != nil {
+		client.close()
+		return nil, err
+	}
+
+	if source != nil {
+		client.ZendeskClient = source
+	}
+
+	if err := client.testConnection(ctx); err != nil {
+		client.close()
+		return nil, fmt.Errorf("testing API connections: %w", err)
+	}
+
+	return client, nil
+}
+
+// runPhase runs one phase of the migration without the terminal interface, writing a run report like a full run,
+// then prints the results and the phase's summary
+func runPhase(opts CliOptions, name string, phase func(m *Model) error, summary func(m *Model) string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, client, err := startup(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	m, err := newModel(ctx, client)
+	if err != nil {
+		return fmt.Errorf("initializing migration: %w", err)
+	}
+
+	// there's nobody to answer duplicate contact prompts, so those users fail and can be retried later
+	m.contactPrompts = nil
+
+	m.report, err = newRunReport(dir, time.Now())
+	if err != nil {
+		return fmt.Errorf("creating run report: %w", err)
+	}
+
+	slog.Info("running migration phase", "phase", name)
+	phaseErr := phase(m)
+	m.finishRun()
+
+	fmt.Print(m.results())
+	fmt.Println(summary(m))
+
+	if phaseErr != nil {
+		return fmt.Errorf("running %s: %w", name, phaseErr)
+	}
+
+	if err := m.migrationFailures(); err != nil {
+		return fmt.Errorf("running %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// migrationFailures returns ErrMigrationFailures if any user or ticket failed to migrate, so a phase run from a
+// script exits with an error even though it ran to the end
+func (m *Model) migrationFailures() error {
+	users, tickets := m.userMigrationErrors.get(), m.ticketMigrationErrors.get()
+	if users == 0 && tickets == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d users and %d tickets", ErrMigrationFailures, users, tickets)
+}
+
+// matchOrgs gets the orgs for every tag in the config and checks each one, selecting those ready for migration
+func (m *Model) matchOrgs() error {
+	if err := m.loadTaggedOrgs(); err != nil {
+		return err
+	}
+
+	m.data.SelectedOrgs = nil
+	for _, org := range sortedOrgs(m.data.AllOrgs.snapshot()) {
+		m.checkOrg(org)()
+		if org.Migrated {
+			m.data.SelectedOrgs = append(m.data.SelectedOrgs, org)
+		}
+	}
+
+	return nil
+}
+
+// migrateLinkedUsers migrates the users of every org already linked to a PSA company
+func (m *Model) migrateLinkedUsers() error {
+	if err := m.selectLinkedOrgs(); err != nil {
+		return err
+	}
+
+	for _, org := range m.data.SelectedOrgs {
+		m.getUsersToMigrate(org)()
+	}
+
+	if m.data.UsersToMigrate.len() == 0 {
+		return nil
+	}
+
+	m.migrateUsers(m.data.UsersToMigrate)()
+	return nil
+}
+
+// migrateLinkedTickets migrates the tickets of every org already linked to a PSA company. Requesters must already
+// be linked to a PSA contact by the users phase, except inactive users, who are migrated as their tickets need them.
+func (m *Model) migrateLinkedTickets() error {
+	if err := m.selectLinkedOrgs(); err != nil {
+		return err
+	}
+
+	for _, org := range m.data.SelectedOrgs {
+		if err := m.loadLinkedUsers(org); err != nil {
+			return fmt.Errorf("loading linked users for org %s: %w", org.ZendeskOrg.Name, err)
+		}
+	}
+
+	if msg, ok := m.getAlreadyMigrated()().(fatalErrMsg); ok {
+		return fmt.Errorf("%s: %w", msg.Msg, msg.Err)
+	}
+
+	m.runTicketMigration(m.data.SelectedOrgs)()
+	return nil
+}
+
+// selectLinkedOrgs gets the orgs for every tag in the config and selects those already linked to a PSA company
+func (m *Model) selectLinkedOrgs() error {
+	if err := m.loadTaggedOrgs(); err != nil {
+		return err
+	}
+
+	m.data.SelectedOrgs = nil
+	var unlinked int
+	for _, org := range sortedOrgs(m.data.AllOrgs.snapshot()) {
+		companyId := org.ZendeskOrg.OrganizationFields.PSACompanyId
+		if companyId == 0 {
+			unlinked++
+			continue
+		}
+
+		org.PsaOrg = &psa.Company{Id: int(companyId), Name: org.ZendeskOrg.Name}
+		org.Migrated = true
+		m.data.SelectedOrgs = append(m.data.SelectedOrgs, org)
+	}
+
+	slog.Info("selectLinkedOrgs: orgs selected", "linked", len(m.data.SelectedOrgs), "unlinked", unlinked)
+	if unlinked > 0 {
+		m.writeToOutput(warnYellowOutput("WARNING", fmt.Sprintf("%d orgs aren't linked to a PSA company and were skipped - run orgs match first", unlinked)), warnOutput)
+	}
+
+	return nil
+}
+
+// loadTaggedOrgs gets the tag date ranges from the config, then the orgs for every tag
+func (m *Model) loadTaggedOrgs() error {
+	if msg, ok := m.getTagDetails()().(timeConvertErrMsg); ok {
+		return fmt.Errorf("getting tag details: %w", msg.Err)
+	}
+
+	if msg, ok := m.getOrgs()().(apiErrMsg); ok {
+		return fmt.Errorf("getting zendesk orgs: %w", msg.Err)
+	}
+
+	return nil
+}
+
+// sortedOrgs returns the orgs sorted by name, so a phase works through them and reports them in the same order
+func sortedOrgs(byId map[string]*orgMigrationDetails) []*orgMigrationDetails {
+	orgs := make([]*orgMigrationDetails, 0, len(byId))
+	for _, org := range byId {
+		orgs = append(orgs, org)
+	}
+
+	sort.Slice(orgs, func(i, j int) bool {
+		return orgs[i].ZendeskOrg.Name < orgs[j].ZendeskOrg.Name
+	})
+	return orgs
+}
@@ -0,0 +1,126 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	migrationDirName   = "ticket-migration"
+	profilesDirName    = "profiles"
+	defaultProfileName = "default"
+)
+
+// profileNamePattern keeps profile names usable as a single folder name on any OS
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ProfileInfo describes a profile's working directory, for listing profiles
+type ProfileInfo struct {
+	Name      string
+	Dir       string
+	HasConfig bool
+	Runs      int
+
+	// LastRun is when the latest run started, or zero if there are no runs
+	LastRun time.Time
+}
+
+// migrationRoot is the top level working directory, which is also the default profile's directory
+func migrationRoot() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting user home directory: %w", err)
+	}
+
+	return filepath.Join(home, migrationDirName), nil
+}
+
+// profileDir returns the working directory for a profile. The default profile uses the top level directory, so
+// setups from before profiles existed keep working; every other profile has a folder under profiles.
+func profileDir(profile string) (string, error) {
+	root, err := migrationRoot()
+	if err != nil {
+		return "", err
+	}
+
+	if profile == "" || profile == defaultProfileName {
+		return root, nil
+	}
+
+	if !profileNamePattern.MatchString(profile) {
+		return "", fmt.Errorf("invalid profile name %q: use only letters, numbers, - and _", profile)
+	}
+
+	return filepath.Join(root, profilesDirName, profile), nil
+}
+
+// makeMigrationDir creates the profile's working directory, which holds its config, logs, reports and archives
+func makeMigrationDir(profile string) (string, error) {
+	migrationDir, err := profileDir(profile)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(migrationDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("creating migration directory: %w", err)
+	}
+
+	return migrationDir, nil
+}
+
+// ListProfiles returns the default profile and every named profile, sorted by name
+func ListProfiles() ([]ProfileInfo, error) {
+	root, err := migrationRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []ProfileInfo{readProfile(defaultProfileName, root)}
+
+	entries, err := os.ReadDir(filepath.Join(root, profilesDirName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading profiles directory: %w", err)
+	}
+
+	var named []ProfileInfo
+	for _, e := range entries {
+		if e.IsDir() && profileNamePattern.MatchString(e.Name()) {
+			named = append(named, readProfile(e.Name(), filepath.Join(root, profilesDirName, e.Name())))
+		}
+	}
+
+	sort.Slice(named, func(i, j int) bool { return named[i].Name < named[j].Name })
+	return append(profiles, named...), nil
+}
+
+func readProfile(name, dir string) ProfileInfo {
+	p := ProfileInfo{Name: name, Dir: dir}
+
+	if _, err := os.Stat(filepath.Join(dir, configFileSubPath)); err == nil {
+		p.HasConfig = true
+	}
+
+	runs, err := os.ReadDir(filepath.Join(dir, runsDirName))
+	if err != nil {
+		return p
+	}
+
+	for _, r := range runs {
+		started, err := time.ParseInLocation(runDirTimeLayout, r.Name(), time.Local)
+		if !r.IsDir() || err != nil {
+			continue
+		}
+
+		p.Runs++
+		if started.After(p.LastRun) {
+			p.LastRun = started
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,104 @@
+package migration
+
+import (
+	"context"
+	"sync"
+)
+
+// ticketQueue holds the tickets waiting to be migrated for every selected org. Workers take tickets from the
+// orgs in turn, so one big org can't hold up the rest. Orgs are added as their tickets are fetched.
+type ticketQueue struct {
+	mu        sync.Mutex
+	orgs      []*queuedOrg
+	next      int
+	producers int
+
+	// changed is closed and replaced whenever tickets are added or a producer finishes, waking waiting workers
+	changed chan struct{}
+}
+
+type queuedOrg struct {
+	progress *orgTicketMigration
+	tickets  []*ticketMigrationDetails
+}
+
+type queuedTicket struct {
+	ticket   *ticketMigrationDetails
+	progress *orgTicketMigration
+}
+
+// newTicketQueue returns a queue expecting tickets from the given number of producers - workers keep waiting for
+// tickets until every producer has called done
+func newTicketQueue(producers int) *ticketQueue {
+	return &ticketQueue{
+		producers: producers,
+		changed:   make(chan struct{}),
+	}
+}
+
+func (q *ticketQueue) add(progress *orgTicketMigration, tickets []*ticketMigrationDetails) {
+	if len(tickets) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.orgs = append(q.orgs, &queuedOrg{progress: progress, tickets: tickets})
+	q.notify()
+}
+
+// done marks one producer as finished
+func (q *ticketQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.producers--
+	q.notify()
+}
+
+func (q *ticketQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// take returns the next ticket, moving round-robin between orgs. It blocks while the queue is empty but
+// producers are still running, and returns false once there is nothing left or the context is cancelled.
+func (q *ticketQueue) take(ctx context.Context) (queuedTicket, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.orgs) > 0 {
+			if q.next >= len(q.orgs) {
+				q.next = 0
+			}
+
+			org := q.orgs[q.next]
+			t := queuedTicket{ticket: org.tickets[0], progress: org.progress}
+			org.tickets = org.tickets[1:]
+
+			if len(org.tickets) == 0 {
+				// the next org slides into this index
+				q.orgs = append(q.orgs[:q.next], q.orgs[q.next+1:]...)
+			} else {
+				q.next++
+			}
+
+			q.mu.Unlock()
+			return t, true
+		}
+
+		if q.producers <= 0 {
+			q.mu.Unlock()
+			return queuedTicket{}, false
+		}
+
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return queuedTicket{}, false
+		}
+	}
+}
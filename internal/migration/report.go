@@ -0,0 +1,346 @@
+package migration
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	runsDirName       = "runs"
+	runDirTimeLayout  = "2006-01-02_15-04-05"
+	reportCsvName     = "report.csv"
+	reportJsonName    = "report.json"
+	reportRecordsName = "records.jsonl"
+)
+
+type reportEntity string
+
+const (
+	orgEntity    reportEntity = "org"
+	userEntity   reportEntity = "user"
+	ticketEntity reportEntity = "ticket"
+)
+
+type reportAction string
+
+const (
+	actionCreated reportAction = "created"
+	actionMatched reportAction = "matched"
+	actionSkipped reportAction = "skipped"
+	actionFailed  reportAction = "failed"
+)
+
+// reportRecord is one row of the run report - the outcome for a single org, user or ticket
+type reportRecord struct {
+	Entity     reportEntity `json:"entity"`
+	ZendeskId  int64        `json:"zendesk_id"`
+	PsaId      int          `json:"psa_id,omitempty"`
+	Name       string       `json:"name"`
+	Org        string       `json:"org,omitempty"`
+	OrgId      int64        `json:"zendesk_org_id,omitempty"`
+	Tag        string       `json:"tag,omitempty"`
+	Action     reportAction `json:"action"`
+	Reason     string       `json:"reason,omitempty"`
+	ErrorClass errorClass   `json:"error_class,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+}
+
+var reportCsvHeader = []string{"entity", "zendesk_id", "psa_id", "name", "org", "zendesk_org_id", "tag", "action", "reason", "error_class", "started_at", "finished_at"}
+
+func (r reportRecord) csvRow() []string {
+	psaId := ""
+	if r.PsaId != 0 {
+		psaId = strconv.Itoa(r.PsaId)
+	}
+
+	orgId := ""
+	if r.OrgId != 0 {
+		orgId = strconv.FormatInt(r.OrgId, 10)
+	}
+
+	return []string{
+		string(r.Entity),
+		strconv.FormatInt(r.ZendeskId, 10),
+		psaId,
+		r.Name,
+		r.Org,
+		orgId,
+		r.Tag,
+		string(r.Action),
+		r.Reason,
+		string(r.ErrorClass),
+		r.StartedAt.Format(time.RFC3339),
+		r.FinishedAt.Format(time.RFC3339),
+	}
+}
+
+// runReport writes a machine readable record of everything the run did to its own folder. Rows are appended to
+// the CSV and JSON lines files as they happen, so a report survives a crash, and the full JSON report with
+// totals is written when the run finishes. A nil runReport discards everything.
+type runReport struct {
+	mu        sync.Mutex
+	dir       string
+	startedAt time.Time
+	csvFile   *os.File
+	csv       *csv.Writer
+	jsonl     *os.File
+	records   []reportRecord
+	closed    bool
+
+	// seen indexes records by recordKey, so checking for an existing record doesn't scan them all
+	seen map[string]bool
+}
+
+type reportSummary struct {
+	StartedAt  time.Time                             `json:"started_at"`
+	FinishedAt time.Time                             `json:"finished_at"`
+	Totals     map[reportEntity]map[reportAction]int `json:"totals"`
+	Records    []reportRecord                        `json:"records"`
+}
+
+// newRunReport creates a folder for this run under the migration directory and opens the report files in it
+func newRunReport(migrationDir string, startedAt time.Time) (*runReport, error) {
+	dir := filepath.Join(migrationDir, runsDirName, startedAt.Format(runDirTimeLayout))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("creating run directory: %w", err)
+	}
+
+	csvFile, err := os.Create(filepath.Join(dir, reportCsvName))
+	if err != nil {
+		return nil, fmt.Errorf("creating csv report: %w", err)
+	}
+
+	jsonl, err := os.Create(filepath.Join(dir, reportRecordsName))
+	if err != nil {
+		_ = csvFile.Close()
+		return nil, fmt.Errorf("creating json records file: %w", err)
+	}
+
+	r := &runReport{
+		dir:       dir,
+		startedAt: startedAt,
+		csvFile:   csvFile,
+		csv:       csv.NewWriter(csvFile),
+		jsonl:     jsonl,
+		seen:      make(map[string]bool),
+	}
+
+	if err := r.csv.Write(reportCsvHeader); err != nil {
+		_ = r.close()
+		return nil, fmt.Errorf("writing csv report header: %w", err)
+	}
+	r.csv.Flush()
+
+	slog.Info("run report created", "dir", dir)
+	return r, nil
+}
+
+// add records an outcome and writes it straight to disk. Write errors are logged rather than stopping the migration.
+func (r *runReport) add(rec reportRecord) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	r.records = append(r.records, rec)
+	r.seen[recordKey(rec.Entity, rec.ZendeskId)] = true
+
+	if err := r.csv.Write(rec.csvRow()); err != nil {
+		slog.Error("runReport: writing csv row", "error", err)
+	}
+	r.csv.Flush()
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("runReport: marshaling record", "error", err)
+		return
+	}
+
+	if _, err := r.jsonl.Write(append(b, '\n')); err != nil {
+		slog.Error("runReport: writing json record", "error", err)
+	}
+}
+
+// recorded reports whether there is already a record for the item
+func (r *runReport) recorded(entity reportEntity, zendeskId int64) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.seen[recordKey(entity, zendeskId)]
+}
+
+// finish writes the full JSON report and closes the files. Nothing more is recorded after it is called.
+func (r *runReport) finish() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	summary := reportSummary{
+		StartedAt:  r.startedAt,
+		FinishedAt: time.Now(),
+		Totals:     make(map[reportEntity]map[reportAction]int),
+		Records:    r.records,
+	}
+
+	for _, rec := range r.records {
+		if summary.Totals[rec.Entity] == nil {
+			summary.Totals[rec.Entity] = make(map[reportAction]int)
+		}
+		summary.Totals[rec.Entity][rec.Action]++
+	}
+
+	b, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		_ = r.close()
+		return fmt.Errorf("marshaling json report: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(r.dir, reportJsonName), b, 0644); err != nil {
+		_ = r.close()
+		return fmt.Errorf("writing json report: %w", err)
+	}
+
+	return r.close()
+}
+
+func (r *runReport) close() error {
+	r.closed = true
+	r.csv.Flush()
+
+	var errs []error
+	if err := r.csv.Error(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := r.csvFile.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := r.jsonl.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("closing report files: %v", errs)
+	}
+
+	return nil
+}
+
+func (m *Model) recordOrg(org *orgMigrationDetails, action reportAction, reason string, startedAt time.Time) {
+	m.report.add(orgRecord(org, action, reason, startedAt))
+}
+
+func (m *Model) recordUser(user *userMigrationDetails, action reportAction, reason string, startedAt time.Time) {
+	m.report.add(userRecord(user, action, reason, startedAt))
+}
+
+func (m *Model) recordTicket(ticket *ticketMigrationDetails, org *orgMigrationDetails, action reportAction, reason string, startedAt time.Time) {
+	m.report.add(ticketRecord(ticket, org, action, reason, startedAt))
+}
+
+// recordFailed records a failure along with the class of error that caused it
+func (m *Model) recordFailed(rec reportRecord, err error) {
+	rec.Action = actionFailed
+	rec.ErrorClass = classifyErr(err)
+	m.report.add(rec)
+}
+
+func orgRecord(org *orgMigrationDetails, action reportAction, reason string, startedAt time.Time) reportRecord {
+	rec := reportRecord{
+		Entity:     orgEntity,
+		ZendeskId:  org.ZendeskOrg.Id,
+		Name:       org.ZendeskOrg.Name,
+		Org:        org.ZendeskOrg.Name,
+		OrgId:      org.ZendeskOrg.Id,
+		Action:     action,
+		Reason:     reason,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+
+	if org.Tag != nil {
+		rec.Tag = org.Tag.Name
+	}
+
+	if org.PsaOrg != nil {
+		rec.PsaId = org.PsaOrg.Id
+	}
+
+	return rec
+}
+
+func userRecord(user *userMigrationDetails, action reportAction, reason string, startedAt time.Time) reportRecord {
+	rec := reportRecord{
+		Entity:     userEntity,
+		ZendeskId:  int64(user.ZendeskUser.Id),
+		Name:       user.ZendeskUser.Name,
+		Action:     action,
+		Reason:     reason,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+
+	if user.PsaContact != nil {
+		rec.PsaId = user.PsaContact.Id
+	}
+
+	if user.Org != nil {
+		rec.Org = user.Org.ZendeskOrg.Name
+		rec.OrgId = user.Org.ZendeskOrg.Id
+		if user.Org.Tag != nil {
+			rec.Tag = user.Org.Tag.Name
+		}
+	}
+
+	return rec
+}
+
+func ticketRecord(ticket *ticketMigrationDetails, org *orgMigrationDetails, action reportAction, reason string, startedAt time.Time) reportRecord {
+	rec := reportRecord{
+		Entity:     ticketEntity,
+		ZendeskId:  int64(ticket.ZendeskTicket.Id),
+		Name:       ticket.ZendeskTicket.Subject,
+		Org:        org.ZendeskOrg.Name,
+		OrgId:      org.ZendeskOrg.Id,
+		Action:     action,
+		Reason:     reason,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+
+	if org.Tag != nil {
+		rec.Tag = org.Tag.Name
+	}
+
+	if ticket.PsaTicket != nil {
+		rec.PsaId = ticket.PsaTicket.Id
+	}
+
+	return rec
+}
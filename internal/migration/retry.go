@@ -0,0 +1,518 @@
+package migration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryOptions picks which failures from a previous run are retried
+type RetryOptions struct {
+	// Run is the name of the run folder to retry - if empty, the latest run with matching failures is used
+	Run string
+
+	// ErrorClasses limits the retry to failures with these error classes - if empty, every failure is retried
+	ErrorClasses []string
+}
+
+// Retry reprocesses only the orgs, users and tickets that failed in a previous run, using the same config. It runs
+// without the terminal interface, and writes its own run report so anything that fails again can be retried later.
+func Retry(opts CliOptions, retryOpts RetryOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	classes, err := parseErrorClasses(retryOpts.ErrorClasses)
+	if err != nil {
+		return err
+	}
+
+	dir, client, err := startup(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	runDir, failed, err := findRetryRun(dir, retryOpts.Run, classes)
+	if err != nil {
+		return fmt.Errorf("finding run to retry: %w", err)
+	}
+
+	if len(failed) == 0 {
+		fmt.Println("No failures to retry")
+		return nil
+	}
+
+	slog.Info("retrying failures from previous run", "runDir", runDir, "failures", len(failed), "errorClasses", retryOpts.ErrorClasses)
+	fmt.Printf("Retrying %d failures from %s\n", len(failed), runDir)
+
+	m, err := newModel(ctx, client)
+	if err != nil {
+		return fmt.Errorf("initializing migration: %w", err)
+	}
+
+	// there's nobody to answer duplicate contact prompts, so those users fail and can be retried again later
+	m.contactPrompts = nil
+
+	m.report, err = newRunReport(dir, time.Now())
+	if err != nil {
+		return fmt.Errorf("creating run report: %w", err)
+	}
+
+	retryErr := m.retryFailures(failed)
+	m.recordNotRetried(failed)
+	m.finishRun()
+
+	fmt.Print(m.results())
+	fmt.Println(m.retrySummary())
+
+	if retryErr != nil {
+		return fmt.Errorf("retrying failures: %w", retryErr)
+	}
+
+	return nil
+}
+
+func parseErrorClasses(names []string) (map[errorClass]bool, error) {
+	classes := make(map[errorClass]bool)
+	for _, name := range names {
+		class := errorClass(strings.TrimSpace(name))
+		valid := false
+		for _, c := range errorClasses {
+			if c == class {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			var options []string
+			for _, c := range errorClasses {
+				options = append(options, string(c))
+			}
+			return nil, fmt.Errorf("unknown error class %q (expected one of: %s)", name, strings.Join(options, ", "))
+		}
+
+		classes[class] = true
+	}
+
+	return classes, nil
+}
+
+// findRetryRun returns the run folder to retry and its failures matching the error classes. With no run name, every
+// run is read oldest to newest, and only items whose latest record is a matching failure are retried - so items a
+// later run fixed aren't retried again. The folder returned is then the newest run with one of those failures.
+func findRetryRun(migrationDir, run string, classes map[errorClass]bool) (string, []reportRecord, error) {
+	runsDir := filepath.Join(migrationDir, runsDirName)
+	if run != "" {
+		runDir := filepath.Join(runsDir, run)
+		failed, err := loadFailedRecords(runDir, classes)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return runDir, failed, nil
+	}
+
+	entries, err := os.ReadDir(runsDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading runs directory: %w", err)
+	}
+
+	// run folders are named by start time, so they sort oldest to newest
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	type outcome struct {
+		rec    reportRecord
+		runDir string
+	}
+
+	var keys []string
+	latest := make(map[string]outcome)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		runDir := filepath.Join(runsDir, entry.Name())
+		records, err := loadRecords(runDir)
+		if err != nil {
+			slog.Warn("findRetryRun: couldn't read run records", "runDir", runDir, "error", err)
+			continue
+		}
+
+		for _, rec := range records {
+			key := recordKey(rec.Entity, rec.ZendeskId)
+			if _, ok := latest[key]; !ok {
+				keys = append(keys, key)
+			}
+			latest[key] = outcome{rec: rec, runDir: runDir}
+		}
+	}
+
+	var runDir string
+	var failed []reportRecord
+	for _, key := range keys {
+		o := latest[key]
+		if o.rec.Action != actionFailed {
+			continue
+		}
+
+		if len(classes) > 0 && !classes[o.rec.ErrorClass] {
+			continue
+		}
+
+		failed = append(failed, o.rec)
+		if o.runDir > runDir {
+			runDir = o.runDir
+		}
+	}
+
+	return runDir, failed, nil
+}
+
+// loadFailedRecords reads the failures from a run's records file, keeping one per item
+func loadFailedRecords(runDir string, classes map[errorClass]bool) ([]reportRecord, error) {
+	records, err := loadRecords(runDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []reportRecord
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if rec.Action != actionFailed {
+			continue
+		}
+
+		if len(classes) > 0 && !classes[rec.ErrorClass] {
+			continue
+		}
+
+		key := recordKey(rec.Entity, rec.ZendeskId)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		failed = append(failed, rec)
+	}
+
+	return failed, nil
+}
+
+// loadRecords reads every record from a run's records file, in the order they were written
+func loadRecords(runDir string) ([]reportRecord, error) {
+	f, err := os.Open(filepath.Join(runDir, reportRecordsName))
+	if err != nil {
+		return nil, fmt.Errorf("opening run records: %w", err)
+	}
+	defer f.Close()
+
+	var records []reportRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec reportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("reading run record: %w", err)
+		}
+		records = append(records, rec)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading run records: %w", err)
+	}
+
+	return records, nil
+}
+
+func recordKey(entity reportEntity, zendeskId int64) string {
+	return fmt.Sprintf("%s:%d", entity, zendeskId)
+}
+
+// retryFailures reprocesses each failure. Failed orgs are checked again and, if they're ready, have all of their
+// users and tickets migrated, since neither was attempted the first time. Failed users and tickets are migrated
+// on their own.
+func (m *Model) retryFailures(failed []reportRecord) error {
+	if msg := m.getTagDetails()(); msg != nil {
+		if errMsg, ok := msg.(timeConvertErrMsg); ok {
+			return fmt.Errorf("getting tag details: %w", errMsg.Err)
+		}
+	}
+
+	var orgRecs, userRecs, ticketRecs []reportRecord
+	for _, rec := range failed {
+		switch rec.Entity {
+		case orgEntity:
+			orgRecs = append(orgRecs, rec)
+		case userEntity:
+			userRecs = append(userRecs, rec)
+		case ticketEntity:
+			ticketRecs = append(ticketRecs, rec)
+		}
+	}
+
+	slog.Info("retryFailures: failures to retry", "orgs", len(orgRecs), "users", len(userRecs), "tickets", len(ticketRecs))
+
+	fullOrgs := make(map[int64]*orgMigrationDetails)
+	for _, rec := range orgRecs {
+		org, err := m.loadRetryOrg(rec.ZendeskId, rec.Tag)
+		if err != nil {
+			m.failRetry(rec, err)
+			continue
+		}
+
+		m.checkOrg(org)()
+		if !org.Migrated {
+			continue
+		}
+
+		m.getUsersToMigrate(org)()
+		fullOrgs[org.ZendeskOrg.Id] = org
+	}
+
+	for _, rec := range userRecs {
+		if _, ok := m.data.UsersToMigrate.load(strconv.FormatInt(rec.ZendeskId, 10)); ok {
+			continue
+		}
+
+		if err := m.queueRetryUser(rec); err != nil {
+			m.failRetry(rec, err)
+		}
+	}
+
+	if m.data.UsersToMigrate.len() > 0 {
+		msg := m.migrateUsers(m.data.UsersToMigrate)()
+		if msg == switchStatusMsg(errored) || msg == switchStatusMsg(done) {
+			slog.Info("retryFailures: stopping after user migration")
+			return nil
+		}
+	}
+
+	ticketOrgs := make(map[int64]*orgMigrationDetails)
+	ticketsByOrg := make(map[int64][]reportRecord)
+	for _, rec := range ticketRecs {
+		if _, ok := fullOrgs[rec.OrgId]; ok {
+			// every ticket in the org is being migrated already
+			continue
+		}
+
+		org, err := m.loadLinkedOrg(rec)
+		if err != nil {
+			m.failRetry(rec, err)
+			continue
+		}
+
+		ticketOrgs[org.ZendeskOrg.Id] = org
+		ticketsByOrg[org.ZendeskOrg.Id] = append(ticketsByOrg[org.ZendeskOrg.Id], rec)
+	}
+
+	if len(fullOrgs) == 0 && len(ticketOrgs) == 0 {
+		return nil
+	}
+
+	m.data.SelectedOrgs = nil
+	for _, org := range fullOrgs {
+		m.data.SelectedOrgs = append(m.data.SelectedOrgs, org)
+	}
+	for _, org := range ticketOrgs {
+		m.data.SelectedOrgs = append(m.data.SelectedOrgs, org)
+	}
+
+	if msg := m.getAlreadyMigrated()(); msg != switchStatusMsg(migratingTickets) {
+		if errMsg, ok := msg.(fatalErrMsg); ok {
+			return fmt.Errorf("%s: %w", errMsg.Msg, errMsg.Err)
+		}
+	}
+
+	if len(fullOrgs) > 0 {
+		var orgs []*orgMigrationDetails
+		for _, org := range fullOrgs {
+			orgs = append(orgs, org)
+		}
+
+		if msg := m.runTicketMigration(orgs)(); msg == switchStatusMsg(errored) {
+			slog.Info("retryFailures: stopping after error as per configuration")
+			return nil
+		}
+	}
+
+	queue := newTicketQueue(1)
+	for orgId, recs := range ticketsByOrg {
+		org := ticketOrgs[orgId]
+		if err := m.loadLinkedUsers(org); err != nil {
+			for _, rec := range recs {
+				m.failRetry(rec, err)
+			}
+			continue
+		}
+
+		var tickets []*ticketMigrationDetails
+		for _, rec := range recs {
+			ticket, err := m.client.ZendeskClient.GetTicket(m.ctx, rec.ZendeskId)
+			if err != nil {
+				m.failRetry(rec, err)
+				continue
+			}
+
+			if psaId, ok := m.data.TicketsInPsa.load(strconv.Itoa(ticket.Id)); ok {
+				// the failure happened after the ticket was created, so it may be missing notes or still be open
+				slog.Warn("retryFailures: failed ticket is already in the PSA", "zendeskTicketId", ticket.Id, "psaTicketId", psaId)
+				m.writeToOutput(warnYellowOutput("WARN", fmt.Sprintf("%s: ticket %d is already in PSA as ticket %d - if it's incomplete, delete it and retry again", org.ZendeskOrg.Name, ticket.Id, psaId)), warnOutput)
+				m.recordTicket(&ticketMigrationDetails{ZendeskTicket: &ticket, PsaTicket: &psa.Ticket{Id: psaId}}, org, actionSkipped, fmt.Sprintf("already in PSA as ticket %d - if it's incomplete, delete it and retry again", psaId), time.Now())
+				continue
+			}
+
+			tickets = append(tickets, &ticketMigrationDetails{ZendeskTicket: &ticket, PsaTicket: &psa.Ticket{}})
+		}
+
+		p := newOrgTicketMigration(org, ticketStatusMigrating)
+		p.ticketsToProcess.set(len(tickets))
+		p.remaining.set(len(tickets))
+		queue.add(p, tickets)
+	}
+	queue.done()
+
+	m.drainTicketQueue(queue)
+	return nil
+}
+
+// loadRetryOrg gets an org from Zendesk along with the tag it was migrated under
+func (m *Model) loadRetryOrg(orgId int64, tagName string) (*orgMigrationDetails, error) {
+	idString := strconv.FormatInt(orgId, 10)
+	if org, ok := m.data.AllOrgs.load(idString); ok {
+		return org, nil
+	}
+
+	var tag *tagDetails
+	for _, t := range m.data.Tags {
+		if t.Name == tagName {
+			tag = &t
+			break
+		}
+	}
+
+	if tag == nil {
+		return nil, fmt.Errorf("tag %q is no longer in the config", tagName)
+	}
+
+	zOrg, err := m.client.ZendeskClient.GetOrganization(m.ctx, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("getting zendesk org: %w", err)
+	}
+
+	org := &orgMigrationDetails{ZendeskOrg: &zOrg, Tag: tag}
+	m.data.AllOrgs.store(idString, org)
+	return org, nil
+}
+
+// loadLinkedOrg gets the org of a failed user or ticket, which must already be linked to a PSA company
+func (m *Model) loadLinkedOrg(rec reportRecord) (*orgMigrationDetails, error) {
+	if rec.OrgId == 0 {
+		return nil, errors.New("no zendesk org recorded - the run is from an older version")
+	}
+
+	org, err := m.loadRetryOrg(rec.OrgId, rec.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if org.PsaOrg == nil {
+		companyId := org.ZendeskOrg.OrganizationFields.PSACompanyId
+		if companyId == 0 {
+			return nil, fmt.Errorf("org %s is not linked to a PSA company", org.ZendeskOrg.Name)
+		}
+
+		org.PsaOrg = &psa.Company{Id: int(companyId), Name: org.ZendeskOrg.Name}
+		org.Migrated = true
+	}
+
+	return org, nil
+}
+
+// queueRetryUser gets a failed user from Zendesk and adds them to the users to migrate
+func (m *Model) queueRetryUser(rec reportRecord) error {
+	org, err := m.loadLinkedOrg(rec)
+	if err != nil {
+		return err
+	}
+
+	user := &userMigrationDetails{PsaCompany: org.PsaOrg, Org: org}
+	user.ZendeskUser, err = m.client.ZendeskClient.GetUser(m.ctx, rec.ZendeskId)
+	if err != nil {
+		slog.Debug("queueRetryUser: user not found, checking deleted users", "zendeskUserId", rec.ZendeskId, "error", err)
+		user.ZendeskUser, err = m.client.ZendeskClient.GetDeletedUser(m.ctx, rec.ZendeskId)
+		if err != nil {
+			return fmt.Errorf("getting zendesk user: %w", err)
+		}
+		user.Deleted = true
+	}
+
+	m.data.UsersToMigrate.store(strconv.FormatInt(rec.ZendeskId, 10), user)
+	return nil
+}
+
+// loadLinkedUsers adds the org's users that are already linked to a PSA contact, so retried tickets get the same
+// contacts as they would have in a full run
+func (m *Model) loadLinkedUsers(org *orgMigrationDetails) error {
+	users, err := m.client.ZendeskClient.GetOrganizationUsers(m.ctx, org.ZendeskOrg.Id)
+	if err != nil {
+		return fmt.Errorf("getting zendesk users for org: %w", err)
+	}
+
+	for _, user := range users {
+		if user.UserFields.PSAContactId == 0 {
+			continue
+		}
+
+		m.data.UsersInPsa.loadOrStore(strconv.Itoa(user.Id), &userMigrationDetails{
+			ZendeskUser: &user,
+			PsaContact:  &psa.Contact{Id: user.UserFields.PSAContactId},
+			PsaCompany:  org.PsaOrg,
+			Org:         org,
+		})
+	}
+
+	return nil
+}
+
+// failRetry records a failure that couldn't be set up to retry, keeping the details of the original failure
+func (m *Model) failRetry(rec reportRecord, err error) {
+	slog.Error("retryFailures: couldn't retry item", "entity", rec.Entity, "zendeskId", rec.ZendeskId, "error", err)
+	m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s %d (%s): couldn't retry: %s", rec.Entity, rec.ZendeskId, rec.Name, err)), errOutput)
+
+	rec.Reason = fmt.Sprintf("preparing retry: %s", err)
+	rec.StartedAt = time.Now()
+	rec.FinishedAt = time.Now()
+	m.recordFailed(rec, err)
+}
+
+// recordNotRetried records every failure the retry stopped before reaching, so it can be retried again
+func (m *Model) recordNotRetried(failed []reportRecord) {
+	for _, rec := range failed {
+		if m.report.recorded(rec.Entity, rec.ZendeskId) {
+			continue
+		}
+
+		rec.Reason = fmt.Sprintf("not retried - the retry stopped first (original error: %s)", rec.Reason)
+		rec.StartedAt = time.Now()
+		rec.FinishedAt = time.Now()
+		m.report.add(rec)
+	}
+}
+
+func (m *Model) retrySummary() string {
+	return fmt.Sprintf("Orgs checked: %d | Users processed: %d (%d errors) | Tickets created: %d (%d errors)",
+		m.orgsChecked.get(), m.usersProcessed.get(), m.userMigrationErrors.get(), m.newTicketsCreated.get(), m.ticketMigrationErrors.get())
+}
@@ -0,0 +1,115 @@
+package migration
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// credEnvPrefix starts the name of every credential environment variable, e.g. MIGRATOR_ZENDESK_TOKEN
+const credEnvPrefix = "MIGRATOR_"
+
+// credSource is a credential that can be set in the config file, an environment variable, or a file named by either
+type credSource struct {
+	// key is the credential's key in the config file
+	key string
+	dst *string
+}
+
+func (cfg *Config) credSources() []credSource {
+	return []credSource{
+		{"zendesk.api_creds.token", &cfg.Zendesk.Creds.Token},
+		{"zendesk.api_creds.username", &cfg.Zendesk.Creds.Username},
+		{"zendesk.api_creds.subdomain", &cfg.Zendesk.Creds.Subdomain},
+		{"zendesk.api_creds.base_url", &cfg.Zendesk.Creds.BaseUrl},
+		{"connectwise.api_creds.company_id", &cfg.Connectwise.Creds.CompanyId},
+		{"connectwise.api_creds.public_key", &cfg.Connectwise.Creds.PublicKey},
+		{"connectwise.api_creds.private_key", &cfg.Connectwise.Creds.PrivateKey},
+		{"connectwise.api_creds.client_id", &cfg.Connectwise.Creds.ClientId},
+		{"connectwise.api_creds.base_url", &cfg.Connectwise.Creds.BaseUrl},
+	}
+}
+
+// envName is the environment variable for a credential, e.g. MIGRATOR_CONNECTWISE_PRIVATE_KEY for
+// connectwise.api_creds.private_key
+func (s credSource) envName() string {
+	return credEnvPrefix + strings.ToUpper(strings.Replace(s.key, ".api_creds.", "_", 1))
+}
+
+// resolve returns the credential from the first place it is set, and where that was: the environment variable,
+// a file named by the environment variable with _FILE on the end, a file named by the config key with _file on
+// the end, then the config value itself. An empty source means the config value is used.
+func (s credSource) resolve() (string, string, error) {
+	if v, ok := os.LookupEnv(s.envName()); ok && v != "" {
+		return v, "env " + s.envName(), nil
+	}
+
+	if path, ok := os.LookupEnv(s.envName() + "_FILE"); ok && path != "" {
+		v, err := readSecretFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("reading %s from %s: %w", s.key, s.envName()+"_FILE", err)
+		}
+		return v, "file from " + s.envName() + "_FILE", nil
+	}
+
+	if path := viper.GetString(s.key + "_file"); path != "" {
+		v, err := readSecretFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("reading %s from %s_file: %w", s.key, s.key, err)
+		}
+		return v, "file from " + s.key + "_file", nil
+	}
+
+	return viper.GetString(s.key), "", nil
+}
+
+// readSecretFile reads a secret from a file, such as a mounted Docker or Kubernetes secret, without the trailing
+// newline most editors and tools add
+func readSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	v := strings.TrimSpace(string(b))
+	if v == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+
+	return v, nil
+}
+
+// loadCredSources replaces any credential set outside the config file, and remembers what the file had for it so
+// writeConfig never saves it
+func (cfg *Config) loadCredSources() error {
+	cfg.fileCreds = make(map[string]string)
+	for _, s := range cfg.credSources() {
+		v, source, err := s.resolve()
+		if err != nil {
+			return err
+		}
+
+		if source == "" {
+			continue
+		}
+
+		slog.Info("credential loaded from outside the config file", "key", s.key, "source", source)
+		cfg.fileCreds[s.key] = viper.GetString(s.key)
+		*s.dst = v
+	}
+
+	return nil
+}
+
+// writeConfig writes the config file, first putting back the file's own value for every credential set from the
+// environment or a secret file, so those are never saved in plain text
+func (cfg *Config) writeConfig() error {
+	for key, v := range cfg.fileCreds {
+		viper.Set(key, v)
+	}
+
+	return viper.WriteConfig()
+}
@@ -0,0 +1,127 @@
+package migration
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+	"github.com/spf13/viper"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OrgSelection picks the orgs to migrate without the org selection form, so the same orgs can be migrated again
+// exactly. Orgs are matched by name, ignoring case, or by Zendesk ID. If any orgs or org IDs are listed, only those
+// are migrated, and excluded orgs are never migrated.
+type OrgSelection struct {
+	Orgs   []string `mapstructure:"orgs" json:"orgs"`
+	OrgIds []int64  `mapstructure:"org_ids" json:"org_ids"`
+
+	// ExcludeOrgs are org names or Zendesk IDs
+	ExcludeOrgs []string `mapstructure:"exclude_orgs" json:"exclude_orgs"`
+}
+
+// withOverrides returns s with the orgs set with CLI flags. Orgs or org IDs from the flags replace the ones in the
+// config, and excluded orgs are added to the config's.
+func (s OrgSelection) withOverrides(o OrgSelection) OrgSelection {
+	if o.hasAllowList() {
+		s.Orgs = o.Orgs
+		s.OrgIds = o.OrgIds
+	}
+
+	s.ExcludeOrgs = append(slices.Clone(s.ExcludeOrgs), o.ExcludeOrgs...)
+	return s
+}
+
+// hasAllowList reports whether only listed orgs are migrated, in which case the org selection form is skipped
+func (s OrgSelection) hasAllowList() bool {
+	return len(s.Orgs) > 0 || len(s.OrgIds) > 0
+}
+
+// includes reports whether an org is selected for migration
+func (s OrgSelection) includes(org *zendesk.Organization) bool {
+	id := strconv.FormatInt(org.Id, 10)
+	for _, v := range s.ExcludeOrgs {
+		if strings.EqualFold(v, org.Name) || v == id {
+			return false
+		}
+	}
+
+	if !s.hasAllowList() {
+		return true
+	}
+
+	return slices.Contains(s.OrgIds, org.Id) || slices.ContainsFunc(s.Orgs, func(v string) bool {
+		return strings.EqualFold(v, org.Name)
+	})
+}
+
+// unmatched returns each listed org name or ID that isn't one of the orgs, e.g. a typo or an org without a tag in
+// the config
+func (s OrgSelection) unmatched(orgs map[string]*orgMigrationDetails) []string {
+	var missing []string
+	for _, name := range s.Orgs {
+		found := false
+		for _, org := range orgs {
+			if strings.EqualFold(name, org.ZendeskOrg.Name) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+
+	for _, id := range s.OrgIds {
+		if _, ok := orgs[strconv.FormatInt(id, 10)]; !ok {
+			missing = append(missing, strconv.FormatInt(id, 10))
+		}
+	}
+
+	return missing
+}
+
+// selectConfiguredOrgs selects every org ready for migration, in place of the org selection form, and warns about
+// any listed org that wasn't found or isn't ready
+func (m *Model) selectConfiguredOrgs() {
+	m.data.SelectedOrgs = nil
+	for _, org := range sortedOrgs(m.data.AllOrgs.snapshot()) {
+		if !org.Migrated {
+			m.writeToOutput(warnYellowOutput("WARNING", fmt.Sprintf("org %s is in the org selection but isn't ready for migration", org.ZendeskOrg.Name)), warnOutput)
+			continue
+		}
+
+		m.data.SelectedOrgs = append(m.data.SelectedOrgs, org)
+	}
+
+	slog.Info("selectConfiguredOrgs: orgs selected from org selection", "selectedOrgsCount", len(m.data.SelectedOrgs))
+	m.writeToOutput(goodBlueOutput("SELECTED", fmt.Sprintf("%d orgs from the org selection", len(m.data.SelectedOrgs))), createdOutput)
+}
+
+// warnUnmatchedOrgs writes a warning for each listed org that isn't one of the tagged orgs
+func (m *Model) warnUnmatchedOrgs() {
+	for _, v := range m.client.Cfg.OrgSelection.unmatched(m.data.AllOrgs.snapshot()) {
+		slog.Warn("org in org selection not found in tagged orgs", "org", v)
+		m.writeToOutput(warnYellowOutput("WARNING", fmt.Sprintf("org %s is in the org selection but wasn't found under the tags in the config", v)), warnOutput)
+	}
+}
+
+// saveOrgSelection saves the selected orgs to the config by ID, replacing any org selection already there
+func (c *Client) saveOrgSelection(orgs []*orgMigrationDetails) error {
+	sel := OrgSelection{Orgs: []string{}, OrgIds: []int64{}, ExcludeOrgs: []string{}}
+	for _, org := range orgs {
+		sel.OrgIds = append(sel.OrgIds, org.ZendeskOrg.Id)
+	}
+	slices.Sort(sel.OrgIds)
+
+	c.Cfg.OrgSelection = sel
+	viper.Set("org_selection", sel)
+	if err := c.Cfg.writeConfig(); err != nil {
+		return fmt.Errorf("writing org selection to config file: %w", err)
+	}
+
+	slog.Info("saved org selection to config", "orgIds", sel.OrgIds)
+	return nil
+}
@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+	"log/slog"
+)
+
+// Source is everything the migration reads from, and writes back to, the helpdesk being migrated from. The
+// Zendesk API client is the main implementation, and an archive written by the export command or a Zendesk
+// account export can be read instead. Its data is in the shape of the zendesk package types.
+type Source interface {
+	ConnectionTest(ctx context.Context) error
+
+	// Fields are the custom fields that link Zendesk users and orgs to PSA contacts and companies
+	GetUserFieldByKey(ctx context.Context, key string) (*zendesk.UserField, error)
+	PostUserField(ctx context.Context, fieldType, key, title, description string) (*zendesk.UserField, error)
+	GetOrgFieldByKey(ctx context.Context, key string) (*zendesk.OrganizationField, error)
+	PostOrgField(ctx context.Context, fieldType, key, title, description string) (*zendesk.OrganizationField, error)
+
+	// Orgs
+	GetOrganizationsWithQuery(ctx context.Context, q zendesk.SearchQuery) ([]zendesk.Organization, error)
+	GetOrganization(ctx context.Context, orgId int64) (zendesk.Organization, error)
+	GetOrganizationUserCount(ctx context.Context, orgId int64) (int, error)
+	UpdateOrganization(ctx context.Context, org *zendesk.Organization) (*zendesk.Organization, error)
+
+	// Users
+	GetAgents(ctx context.Context) ([]zendesk.User, error)
+	GetOrganizationUsers(ctx context.Context, orgId int64) ([]zendesk.User, error)
+	GetUser(ctx context.Context, userId int64) (*zendesk.User, error)
+	GetDeletedUser(ctx context.Context, userId int64) (*zendesk.User, error)
+	UpdateUser(ctx context.Context, user *zendesk.User) (*zendesk.User, error)
+	GetUserIdentities(ctx context.Context, userId int64) ([]zendesk.Identity, error)
+
+	// Tickets and comments
+	GetTicketsWithQuery(ctx context.Context, q zendesk.SearchQuery, pageSize int, limit int) ([]zendesk.Ticket, error)
+	CountTicketsWithQuery(ctx context.Context, q zendesk.SearchQuery) (int, error)
+	GetTicket(ctx context.Context, ticketId int64) (zendesk.Ticket, error)
+	GetAllTicketComments(ctx context.Context, ticketId int64) ([]zendesk.Comment, error)
+}
+
+var _ Source = (*zendesk.Client)(nil)
+var _ Source = (*archiveSource)(nil)
+
+// localZendeskSource opens the archive or Zendesk account export set in the options, to read from instead of
+// the Zendesk API. It returns nil if neither is set.
+func localZendeskSource(opts CliOptions) (Source, error) {
+	switch {
+	case opts.Archive != "" && opts.ZendeskExport != "":
+		return nil, errors.New("an archive and a zendesk export can't be used together")
+
+	case opts.Archive != "":
+		archive, err := openArchive(opts.Archive)
+		if err != nil {
+			return nil, fmt.Errorf("opening zendesk archive: %w", err)
+		}
+
+		slog.Info("reading zendesk data from archive", "dir", opts.Archive, "exportedAt", archive.manifest.ExportedAt)
+		return archive, nil
+
+	case opts.ZendeskExport != "":
+		export, err := openNativeExport(opts.ZendeskExport)
+		if err != nil {
+			return nil, fmt.Errorf("opening zendesk export: %w", err)
+		}
+
+		slog.Info("reading zendesk data from account export", "dir", opts.ZendeskExport,
+			"orgs", len(export.orgs), "users", len(export.users), "tickets", len(export.tickets))
+		return export, nil
+	}
+
+	return nil, nil
+}
@@ -30,4 +30,5 @@ type ticketStatus string
 const (
 	ticketStatusGetting   ticketStatus = "ticketStatusGetting"
 	ticketStatusMigrating ticketStatus = "ticketStatusMigrating"
+	ticketStatusDone      ticketStatus = "ticketStatusDone"
 )
@@ -10,10 +10,16 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	totalConcurrentTickets = 25
+	totalConcurrentTickets  = 25
+	concurrentTicketFetches = 5
+
+	// maxPsaSummaryLength is the longest ticket summary ConnectWise PSA allows
+	maxPsaSummaryLength = 100
 )
 
 type ticketMigrationDetails struct {
@@ -23,97 +29,179 @@ type ticketMigrationDetails struct {
 	Migrated bool
 }
 
-type activeTicketMigration struct {
-	orgName          string
-	status           ticketStatus
-	ticketsToProcess int
-	ticketsProcessed int
+// orgTicketMigration tracks the ticket migration progress of a single org
+type orgTicketMigration struct {
+	org              *orgMigrationDetails
+	status           atomic.Value
+	ticketsToProcess counter
+	ticketsProcessed counter
+	remaining        counter
+	complete         atomic.Bool
 }
 
-func (m *Model) runTicketMigration(org *orgMigrationDetails) tea.Cmd {
+// runTicketMigration migrates the tickets of every selected org through one shared queue. Tickets for each org
+// are fetched concurrently and queued as they arrive, and a fixed pool of workers takes them from the orgs in turn.
+func (m *Model) runTicketMigration(orgs []*orgMigrationDetails) tea.Cmd {
 	return func() tea.Msg {
-		slog.Debug("runTicketMigration: called", "orgName", org.ZendeskOrg.Name)
-		m.currentTicketMigration = newActiveTicketMigration(org.ZendeskOrg.Name, ticketStatusGetting)
+		slog.Debug("runTicketMigration: called", "orgCount", len(orgs))
 
-		zTickets, err := m.getZendeskTickets(org)
-		if err != nil {
-			m.ticketMigrationErrors++
-			slog.Error("getting tickets for org", "orgName", org.ZendeskOrg.Name, "error", err)
-			m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s: couldn't get zendesk tickets: %s", org.ZendeskOrg.Name, err)), errOutput)
-			return nil
+		var progress []*orgTicketMigration
+		for _, org := range orgs {
+			progress = append(progress, newOrgTicketMigration(org, ticketStatusGetting))
 		}
+		m.ticketProgress.Store(&progress)
+
+		queue := newTicketQueue(len(progress))
+
+		// fetch tickets for a few orgs at a time, queueing each org's tickets as soon as they're ready
+		go func() {
+			sem := make(chan struct{}, concurrentTicketFetches)
+			for _, p := range progress {
+				sem <- struct{}{}
+				go func(p *orgTicketMigration) {
+					defer func() { <-sem }()
+					defer queue.done()
+					m.queueOrgTickets(queue, p)
+				}(p)
+			}
+		}()
 
-		m.currentTicketMigration.ticketsProcessed = org.TicketsAlreadyInPSA
-		m.currentTicketMigration.ticketsToProcess = len(zTickets)
-		m.currentTicketMigration.status = ticketStatusMigrating
+		m.drainTicketQueue(queue)
+		slog.Debug("runTicketMigration: done migrating tickets", "ticketsProcessed", m.ticketsProcessed.get(), "orgsComplete", m.ticketOrgsProcessed.get())
 
-		var ticketsToMigrate []*ticketMigrationDetails
-		for _, ticket := range zTickets {
-			if psaId, ok := m.data.TicketsInPsa[strconv.Itoa(ticket.Id)]; !ok {
-				td := &ticketMigrationDetails{
-					ZendeskTicket: &ticket,
-					PsaTicket:     &psa.Ticket{},
-				}
+		if m.hasErr() && m.client.Cfg.StopAtError {
+			slog.Info("runTicketMigration: stopping after error as per configuration")
+			return switchStatusMsg(errored)
+		}
+
+		return switchStatusMsg(done)
+	}
+}
+
+// drainTicketQueue runs the ticket workers until the queue is empty and every producer is done
+func (m *Model) drainTicketQueue(queue *ticketQueue) {
+	workers := m.client.Cfg.TicketWorkers
+	slog.Info("drainTicketQueue: starting ticket workers", "workers", workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.ticketWorker(queue)
+		}()
+	}
+
+	wg.Wait()
+}
 
-				slog.Debug("runTicketMigration: ticket needs to be migrated", "zendeskId", ticket.Id)
-				ticketsToMigrate = append(ticketsToMigrate, td)
+// queueOrgTickets gets an org's tickets from Zendesk and adds the ones that aren't already in the PSA to the queue
+func (m *Model) queueOrgTickets(queue *ticketQueue, p *orgTicketMigration) {
+	started := time.Now()
+	org := p.org
+	if m.dispatch.isStopped() || m.ctx.Err() != nil {
+		slog.Info("queueOrgTickets: migration stopped - skipping org", "orgName", org.ZendeskOrg.Name)
+		return
+	}
+
+	zTickets, err := m.getZendeskTickets(org)
+	if err != nil {
+		m.ticketMigrationErrors.inc()
+		slog.Error("getting tickets for org", "orgName", org.ZendeskOrg.Name, "error", err)
+		m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s: couldn't get zendesk tickets: %s", org.ZendeskOrg.Name, err)), errOutput)
+		m.recordFailed(orgRecord(org, actionFailed, fmt.Sprintf("getting tickets: %s", err), started), err)
+		m.completeOrg(p)
+		return
+	}
 
-			} else {
-				slog.Debug("runTicketMigration: ticket already migrated", "zendeskId", ticket.Id, "psaId", psaId)
+	var ticketsToMigrate []*ticketMigrationDetails
+	for _, ticket := range zTickets {
+		if psaId, ok := m.data.TicketsInPsa.load(strconv.Itoa(ticket.Id)); !ok {
+			td := &ticketMigrationDetails{
+				ZendeskTicket: &ticket,
+				PsaTicket:     &psa.Ticket{},
 			}
+
+			slog.Debug("queueOrgTickets: ticket needs to be migrated", "zendeskId", ticket.Id)
+			ticketsToMigrate = append(ticketsToMigrate, td)
+
+		} else {
+			slog.Debug("queueOrgTickets: ticket already migrated", "zendeskId", ticket.Id, "psaId", psaId)
+			m.recordTicket(&ticketMigrationDetails{ZendeskTicket: &ticket, PsaTicket: &psa.Ticket{Id: psaId}}, org, actionMatched, "already in PSA", time.Now())
 		}
+	}
 
-		sem := make(chan struct{}, totalConcurrentTickets)
-		var wg sync.WaitGroup
+	p.ticketsProcessed.set(org.TicketsAlreadyInPSA)
+	p.ticketsToProcess.set(len(zTickets))
+	p.remaining.set(len(ticketsToMigrate))
+	p.status.Store(ticketStatusMigrating)
 
-		for _, ticket := range ticketsToMigrate {
-			m.mu.Lock()
-			if m.errCapture.flag && m.errCapture.err != nil {
-				slog.Debug("runTicketMigration: stopping ticket migration due to error")
-				m.mu.Unlock()
-				break
-			}
-			m.mu.Unlock()
-
-			sem <- struct{}{}
-			wg.Add(1)
-
-			go func(ticket *ticketMigrationDetails) {
-				defer wg.Done()
-				defer func() { <-sem }()
-
-				if err := m.migrateTicket(ticket, org); err != nil {
-					slog.Error("runTicketMigration: error migrating ticket", "orgName", org.ZendeskOrg.Name, "zendeskTicketId", ticket.ZendeskTicket.Id, "error", err)
-					m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s: couldn't migrate ticket %d: %s", org.ZendeskOrg.Name, ticket.ZendeskTicket.Id, err)), errOutput)
-					m.errCapture.flag = true
-					m.errCapture.err = err
-					m.ticketMigrationErrors++
-					m.ticketsProcessed++
-					m.currentTicketMigration.ticketsProcessed++
-				} else {
-					m.ticketsProcessed++
-					m.currentTicketMigration.ticketsProcessed++
-				}
-			}(ticket)
+	slog.Debug("queueOrgTickets: queueing tickets", "orgName", org.ZendeskOrg.Name, "count", len(ticketsToMigrate))
+	queue.add(p, ticketsToMigrate)
+
+	if len(ticketsToMigrate) == 0 {
+		m.completeOrg(p)
+	}
+}
+
+// ticketWorker migrates tickets from the queue until it's empty, the migration is stopped or an error is captured
+func (m *Model) ticketWorker(queue *ticketQueue) {
+	for {
+		if m.hasErr() {
+			slog.Debug("ticketWorker: stopping ticket migration due to error")
+			return
+		}
+
+		// blocks while paused, in-flight tickets keep going
+		if !m.dispatch.wait(m.ctx) {
+			slog.Debug("ticketWorker: ticket dispatch stopped")
+			return
 		}
 
-		wg.Wait()
-		m.ticketOrgsProcessed++
-		slog.Debug("runTicketMigration: done migrating tickets", "orgName", org.ZendeskOrg.Name, "ticketsProcessed", m.currentTicketMigration.ticketsProcessed)
+		next, ok := queue.take(m.ctx)
+		if !ok {
+			return
+		}
 
-		if m.errCapture.flag && m.errCapture.err != nil && m.client.Cfg.StopAtError {
-			slog.Info("runTicketMigration: stopping after error as per configuration")
-			return switchStatusMsg(errored)
+		org := next.progress.org
+		ticket := next.ticket
+
+		m.ticketsInFlight.inc()
+		started := time.Now()
+		if err := m.migrateTicket(ticket, org); err != nil {
+			slog.Error("runTicketMigration: error migrating ticket", "orgName", org.ZendeskOrg.Name, "zendeskTicketId", ticket.ZendeskTicket.Id, "error", err)
+			m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s: couldn't migrate ticket %d: %s", org.ZendeskOrg.Name, ticket.ZendeskTicket.Id, err)), errOutput)
+			m.recordFailed(ticketRecord(ticket, org, actionFailed, err.Error(), started), err)
+			m.updateErrCapture(err)
+			m.ticketMigrationErrors.inc()
 		}
+		m.ticketsInFlight.dec()
 
-		return nil
+		m.ticketsProcessed.inc()
+		next.progress.ticketsProcessed.inc()
+		next.progress.remaining.dec()
+		if next.progress.remaining.get() == 0 {
+			m.completeOrg(next.progress)
+		}
 	}
 }
 
+// completeOrg marks an org's tickets as done - it only counts each org once
+func (m *Model) completeOrg(p *orgTicketMigration) {
+	if !p.complete.CompareAndSwap(false, true) {
+		return
+	}
+
+	p.status.Store(ticketStatusDone)
+	m.ticketOrgsProcessed.inc()
+	slog.Debug("completeOrg: done migrating tickets for org", "orgName", p.org.ZendeskOrg.Name, "ticketsProcessed", p.ticketsProcessed.get())
+}
+
 func (m *Model) migrateTicket(ticket *ticketMigrationDetails, org *orgMigrationDetails) error {
-	if m.client.Cfg.TicketLimit > 0 && m.ticketsProcessed >= m.client.Cfg.TicketLimit {
+	started := time.Now()
+	if m.client.Cfg.TicketLimit > 0 && m.ticketsProcessed.get() >= m.client.Cfg.TicketLimit {
 		slog.Info("testLimit reached")
-		m.ticketOrgsProcessed++
+		m.recordTicket(ticket, org, actionSkipped, "ticket limit reached", started)
 		return nil
 	}
 
@@ -125,6 +213,7 @@ func (m *Model) migrateTicket(ticket *ticketMigrationDetails, org *orgMigrationD
 		if errors.As(err, &noUserErr) {
 			slog.Warn("creating base ticket: no user found", "orgName", org.ZendeskOrg.Name, "zendeskTicketId", ticket.ZendeskTicket.Id, "userId", noUserErr.UserId)
 			m.writeToOutput(warnYellowOutput("WARN", fmt.Sprintf("%s: couldn't convert ticket %d to psa ticket: no user found in psa (zendesk user %d)", org.ZendeskOrg.Name, ticket.ZendeskTicket.Id, ticket.ZendeskTicket.RequesterId)), errOutput)
+			m.recordTicket(ticket, org, actionSkipped, fmt.Sprintf("no user found in psa for requester (zendesk user %d)", noUserErr.UserId), started)
 			return nil
 		}
 
@@ -153,17 +242,15 @@ func (m *Model) migrateTicket(ticket *ticketMigrationDetails, org *orgMigrationD
 	}
 
 	slog.Debug("runTicketMigration: migration complete for ticket", "orgName", org.ZendeskOrg.Name, "zendeskTicketId", ticket.ZendeskTicket.Id, "psaTicketId", ticket.PsaTicket.Id)
-	m.mu.Lock()
-	m.data.TicketsInPsa[strconv.Itoa(ticket.ZendeskTicket.Id)] = ticket.PsaTicket.Id
-	m.mu.Unlock()
-	m.newTicketsCreated++
+	m.data.TicketsInPsa.store(strconv.Itoa(ticket.ZendeskTicket.Id), ticket.PsaTicket.Id)
+	m.newTicketsCreated.inc()
+	m.recordTicket(ticket, org, actionCreated, "", started)
 	return nil
 }
 
 func (m *Model) getAlreadyMigrated() tea.Cmd {
 	return func() tea.Msg {
-		s := fmt.Sprintf("id=%d AND value != null", m.data.PsaInfo.ZendeskTicketIdField.Id)
-		tickets, err := m.client.CwClient.GetTickets(m.ctx, &s)
+		tickets, err := m.client.CwClient.GetMigratedTickets(m.ctx, m.data.PsaInfo.ZendeskTicketIdField.Id, m.client.Cfg.PsaPageSize)
 		if err != nil {
 			m.writeToOutput(badRedOutput("FATAL ERROR", fmt.Sprintf("getting already migrated tickets: %s", err)), errOutput)
 			return fatalErrMsg{
@@ -179,8 +266,8 @@ func (m *Model) getAlreadyMigrated() tea.Cmd {
 					// if value is an int, it's a zendesk ticket id
 					if _, ok := field.Value.(float64); ok {
 						val := strconv.Itoa(int(field.Value.(float64)))
-						m.data.TicketsInPsa[val] = ticket.Id
-						m.ticketsProcessed++
+						m.data.TicketsInPsa.store(val, ticket.Id)
+						m.ticketsProcessed.inc()
 						for _, org := range m.data.SelectedOrgs {
 							if ticket.Company.Id == org.PsaOrg.Id {
 								org.TicketsAlreadyInPSA++
@@ -209,7 +296,7 @@ func (m *Model) getZendeskTickets(org *orgMigrationDetails) ([]zendesk.Ticket, e
 		q.GetOpenTickets = true
 	}
 
-	tickets, err := m.client.ZendeskClient.GetTicketsWithQuery(m.ctx, q, 100, m.client.Cfg.TicketLimit)
+	tickets, err := m.client.ZendeskClient.GetTicketsWithQuery(m.ctx, q, m.client.Cfg.ZendeskPageSize, m.client.Cfg.TicketLimit)
 	if err != nil {
 		slog.Debug("getZendeskTickets: error getting tickets for org", "orgName", org.ZendeskOrg.Name, "error", err)
 		return nil, fmt.Errorf("getting tickets via zendesk api: %w", err)
@@ -252,9 +339,9 @@ func (m *Model) createBaseTicket(org *orgMigrationDetails, ticket *ticketMigrati
 	}
 
 	baseTicket.Summary = ticket.ZendeskTicket.Subject
-	if len(baseTicket.Summary) > 100 {
+	if len(baseTicket.Summary) > maxPsaSummaryLength {
 		slog.Debug("createBaseTicket: ticket subject is too long", "zendeskTicketId", ticket.ZendeskTicket.Id, "subjectLength", len(ticket.ZendeskTicket.Subject), "psaTicketId", ticket.PsaTicket.Id)
-		baseTicket.Summary = baseTicket.Summary[:100]
+		baseTicket.Summary = baseTicket.Summary[:maxPsaSummaryLength]
 		baseTicket.InitialInternalAnalysis = fmt.Sprintf("Ticket subject was shortened by migration utility (maximum ticket summary in ConnectWise PSA is 100 characters)\n\n"+
 			"Original Subject: %s", ticket.ZendeskTicket.Subject)
 	}
@@ -264,12 +351,23 @@ func (m *Model) createBaseTicket(org *orgMigrationDetails, ticket *ticketMigrati
 	}
 
 	userString := strconv.Itoa(int(ticket.ZendeskTicket.RequesterId))
-	if user, ok := m.data.UsersInPsa[userString]; ok {
+	user, ok := m.data.UsersInPsa.load(userString)
+	if ok {
 		slog.Debug("createBaseTicket: requester is in org data", "zendeskTicketId", ticket.ZendeskTicket.Id, "requesterId", ticket.ZendeskTicket.RequesterId, "psaTicketId", ticket.PsaTicket.Id, "contactId", user.PsaContact.Id)
 		baseTicket.Contact = &psa.Contact{Id: user.PsaContact.Id}
 	} else {
 		slog.Debug("createBaseTicket: requester is not in org data", "zendeskTicketId", ticket.ZendeskTicket.Id, "requesterId", ticket.ZendeskTicket.RequesterId, "psaTicketId", ticket.PsaTicket.Id)
-		return nil, NoUserErr{UserId: ticket.ZendeskTicket.RequesterId}
+		user, err := m.migrateInactiveRequester(org, ticket.ZendeskTicket.RequesterId)
+		if err != nil {
+			slog.Warn("createBaseTicket: error checking for inactive requester", "zendeskTicketId", ticket.ZendeskTicket.Id, "requesterId", ticket.ZendeskTicket.RequesterId, "error", err)
+		}
+
+		if user == nil {
+			return nil, NoUserErr{UserId: ticket.ZendeskTicket.RequesterId}
+		}
+
+		slog.Info("createBaseTicket: requester is inactive in zendesk, linked to inactive contact", "zendeskTicketId", ticket.ZendeskTicket.Id, "requesterId", ticket.ZendeskTicket.RequesterId, "contactId", user.PsaContact.Id)
+		baseTicket.Contact = &psa.Contact{Id: user.PsaContact.Id}
 	}
 
 	ownerString := strconv.Itoa(int(ticket.ZendeskTicket.AssigneeId))
@@ -298,7 +396,7 @@ func (m *Model) createTicketNotes(ticket *ticketMigrationDetails, comments []zen
 		authorString := strconv.Itoa(int(comment.AuthorId))
 		if agent, ok := m.client.Cfg.AgentMappings[authorString]; ok {
 			note.Member = &psa.Member{Id: agent.PsaId}
-		} else if contact, ok := m.data.UsersInPsa[authorString]; ok {
+		} else if contact, ok := m.data.UsersInPsa.load(authorString); ok {
 			note.Contact = &psa.Contact{Id: contact.PsaContact.Id}
 		} else {
 			// check if user is in Zendesk and use it as a label - we aren't making non-selected org users in ConnectWise
@@ -327,6 +425,7 @@ func (m *Model) createTicketNotes(ticket *ticketMigrationDetails, comments []zen
 			slog.Error("createTicketNote: error creating note in ticket", "zendeskTicketId", ticket.ZendeskTicket.Id, "zendeskCommentId", comment.Id, "psaTicketId", ticket.PsaTicket.Id, "error", err)
 			return fmt.Errorf("creating note in ticket: %w", err)
 		}
+		m.notesCreated.inc()
 	}
 
 	return nil
@@ -336,19 +435,28 @@ func (m *Model) getExternalUserDetails(ticket *ticketMigrationDetails, comment z
 	slog.Debug("createTicketNotes: author is not in org data", "zendeskTicketId", ticket.ZendeskTicket.Id, "zendeskCommentId", comment.Id, "authorId", comment.AuthorId, "psaTicketId", ticket.PsaTicket.Id)
 	senderName := "Unknown"
 	senderEmail := "no email"
-	if user, ok := m.data.ExternalUsers[authorString]; !ok {
-		user, err := m.client.ZendeskClient.GetUser(m.ctx, comment.AuthorId)
+	user, ok := m.data.ExternalUsers.load(authorString)
+	if !ok {
+		var err error
+		user, err = m.client.ZendeskClient.GetUser(m.ctx, comment.AuthorId)
+		if err != nil {
+			// deleted users are only available from the deleted users endpoint
+			user, err = m.client.ZendeskClient.GetDeletedUser(m.ctx, comment.AuthorId)
+		}
+
 		if err != nil {
+			slog.Debug("createTicketNotes: couldn't get external user", "zendeskTicketId", ticket.ZendeskTicket.Id, "authorId", comment.AuthorId, "error", err)
 		} else {
-			m.data.ExternalUsers[authorString] = user
-			senderName = user.Name
-			if user.Email != "" {
-				senderEmail = user.Email
-			}
+			m.data.ExternalUsers.store(authorString, user)
 		}
+	}
 
-	} else {
+	if user != nil {
 		senderName = user.Name
+		if user.Inactive() {
+			senderName += " (inactive)"
+		}
+
 		if user.Email != "" {
 			senderEmail = user.Email
 		}
@@ -386,7 +494,7 @@ func (m *Model) getCcString(comment *zendesk.Comment) string {
 		if agent, ok := m.client.Cfg.AgentMappings[ccString]; ok {
 			ccs = append(ccs, agent.Email)
 		} else {
-			if contact, ok := m.data.UsersInPsa[ccString]; ok {
+			if contact, ok := m.data.UsersInPsa.load(ccString); ok {
 				ccs = append(ccs, contact.ZendeskUser.Email)
 			}
 		}
@@ -394,6 +502,23 @@ func (m *Model) getCcString(comment *zendesk.Comment) string {
 	return strings.Join(ccs, ", ")
 }
 
-func newActiveTicketMigration(orgName string, status ticketStatus) *activeTicketMigration {
-	return &activeTicketMigration{orgName: orgName, status: status}
+func newOrgTicketMigration(org *orgMigrationDetails, status ticketStatus) *orgTicketMigration {
+	o := &orgTicketMigration{org: org}
+	o.status.Store(status)
+	return o
+}
+
+func (o *orgTicketMigration) getStatus() ticketStatus {
+	return o.status.Load().(ticketStatus)
+}
+
+// ticketProgressView shows overall ticket progress - each org's progress is in the dashboard
+func (m *Model) ticketProgressView() string {
+	p := m.ticketProgress.Load()
+	if p == nil {
+		return m.runSpinner("Starting ticket migration")
+	}
+
+	_, fetching := m.ticketsRemaining()
+	return m.runSpinner(fmt.Sprintf("Migrating tickets - %d in flight, getting tickets for %d orgs", m.ticketsInFlight.get(), fetching))
 }
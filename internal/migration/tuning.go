@@ -0,0 +1,96 @@
+package migration
+
+import (
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+	"log/slog"
+)
+
+const (
+	defaultUserBatchSize   = 20
+	defaultZendeskPageSize = 100
+	defaultPsaPageSize     = psa.MaxPageSize
+
+	// maxConnsPerHost caps the connections open to each API - running more workers than this only queues requests
+	maxConnsPerHost = 100
+)
+
+// Tuning sets how much work runs at once and how many items are requested per API page. Zero values use the
+// defaults, and any value set with a CLI flag overrides the config file.
+type Tuning struct {
+	TicketWorkers   int `mapstructure:"ticket_workers" json:"ticket_workers"`
+	UserWorkers     int `mapstructure:"user_workers" json:"user_workers"`
+	UserBatchSize   int `mapstructure:"user_batch_size" json:"user_batch_size"`
+	ZendeskPageSize int `mapstructure:"zendesk_page_size" json:"zendesk_page_size"`
+	PsaPageSize     int `mapstructure:"psa_page_size" json:"psa_page_size"`
+}
+
+func defaultTuning() Tuning {
+	return Tuning{
+		TicketWorkers:   totalConcurrentTickets,
+		UserWorkers:     totalConcurrentUsers,
+		UserBatchSize:   defaultUserBatchSize,
+		ZendeskPageSize: defaultZendeskPageSize,
+		PsaPageSize:     defaultPsaPageSize,
+	}
+}
+
+// withOverrides returns t with every non-zero value in o replacing its own
+func (t Tuning) withOverrides(o Tuning) Tuning {
+	for _, f := range []struct{ dst, src *int }{
+		{&t.TicketWorkers, &o.TicketWorkers},
+		{&t.UserWorkers, &o.UserWorkers},
+		{&t.UserBatchSize, &o.UserBatchSize},
+		{&t.ZendeskPageSize, &o.ZendeskPageSize},
+		{&t.PsaPageSize, &o.PsaPageSize},
+	} {
+		if *f.src != 0 {
+			*f.dst = *f.src
+		}
+	}
+
+	return t
+}
+
+// withDefaults fills in any value that isn't set
+func (t Tuning) withDefaults() Tuning {
+	return defaultTuning().withOverrides(t)
+}
+
+type tuningLimit struct {
+	name  string
+	value int
+	max   int
+	why   string
+}
+
+func (t Tuning) limits() []tuningLimit {
+	return []tuningLimit{
+		{"ticket_workers", t.TicketWorkers, maxConnsPerHost, "the maximum connections per API"},
+		{"user_workers", t.UserWorkers, maxConnsPerHost, "the maximum connections per API"},
+		{"user_batch_size", t.UserBatchSize, maxConnsPerHost, "the maximum connections per API"},
+		{"zendesk_page_size", t.ZendeskPageSize, zendesk.MaxExportPageSize, "the Zendesk search export page size limit"},
+		{"psa_page_size", t.PsaPageSize, psa.MaxPageSize, "the ConnectWise PSA page size limit"},
+	}
+}
+
+// validate checks every value is between 1 and its limit, printing each one that isn't
+func (t Tuning) validate() bool {
+	valid := true
+	for _, l := range t.limits() {
+		if l.value < 1 || l.value > l.max {
+			slog.Warn("invalid tuning value", "name", l.name, "value", l.value, "max", l.max)
+			valid = false
+
+			fmt.Printf("\n%s must be between 1 and %d (%s) - got %d\n", l.name, l.max, l.why, l.value)
+		}
+	}
+
+	return valid
+}
+
+func (t Tuning) String() string {
+	return fmt.Sprintf("Ticket workers: %d | User workers: %d | User batch size: %d | Zendesk page size: %d | PSA page size: %d",
+		t.TicketWorkers, t.UserWorkers, t.UserBatchSize, t.ZendeskPageSize, t.PsaPageSize)
+}
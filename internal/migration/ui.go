@@ -67,11 +67,11 @@ func goodGreenOutput(label, output string) string {
 }
 
 func (m *Model) viewportDivider() string {
-	return m.titleBar("Results")
+	return m.titleBar(m.resultsTitle())
 }
 
 func (m *Model) appFooter() string {
-	return m.titleBar("C: Copy Results | CTRL+Q: Exit")
+	return m.titleBar("C: Copy Results | S: Save Results | /: Search | 1-4: Show/Hide Levels | P: Pause | R: Resume | CTRL+Q: Exit")
 }
 
 func (m *Model) titleBar(t string) string {
@@ -131,7 +131,7 @@ func (m *Model) calculateDimensions(w, h int) tea.Cmd {
 			m.viewport.Height = viewportHeight
 		}
 
-		m.viewport.SetContent(m.data.Output.String())
+		m.viewport.SetContent(m.results())
 		m.setAutoScrollBehavior()
 		slog.Debug("setting ready to true")
 		m.ready = true
@@ -154,23 +154,7 @@ func (m *Model) setAutoScrollBehavior() {
 	}
 }
 
+// writeToOutput keeps every message, even at levels that aren't shown, so they can be shown later or saved
 func (m *Model) writeToOutput(s string, level outputLevel) {
-	switch level {
-	case noActionOutput:
-		if m.client.Cfg.OutputLevels.NoAction {
-			m.data.Output.WriteString(s)
-		}
-	case createdOutput:
-		if m.client.Cfg.OutputLevels.Created {
-			m.data.Output.WriteString(s)
-		}
-	case warnOutput:
-		if m.client.Cfg.OutputLevels.Warn {
-			m.data.Output.WriteString(s)
-		}
-	case errOutput:
-		if m.client.Cfg.OutputLevels.Error {
-			m.data.Output.WriteString(s)
-		}
-	}
+	m.data.Output.write(s, level)
 }
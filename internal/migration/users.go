@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -18,70 +19,71 @@ const (
 
 func (m *Model) getUsersToMigrate(org *orgMigrationDetails) tea.Cmd {
 	return func() tea.Msg {
+		started := time.Now()
 		slog.Debug("getUsersToMigrate: called", "orgName", org.ZendeskOrg.Name)
 		users, err := m.client.ZendeskClient.GetOrganizationUsers(m.ctx, org.ZendeskOrg.Id)
 		if err != nil {
 			slog.Error("getUsersToMigrate: error getting users for org", "orgName", org.ZendeskOrg.Name, "error", err)
 			m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s: couldn't get zendesk users: %s", org.ZendeskOrg.Name, err)), errOutput)
-			m.orgsCheckedForUsers++
-			m.userMigrationErrors++
+			m.recordFailed(orgRecord(org, actionFailed, fmt.Sprintf("getting users: %s", err), started), err)
+			m.orgsCheckedForUsers.inc()
+			m.userMigrationErrors.inc()
 			return nil
 		}
 		slog.Info("getUsersToMigrate: got users for org", "orgName", org.ZendeskOrg.Name, "totalUsers", len(users))
 
-		m.mu.Lock()
 		for _, user := range users {
 			idString := strconv.Itoa(user.Id)
-			m.data.UsersToMigrate[idString] = &userMigrationDetails{ZendeskUser: &user, PsaCompany: org.PsaOrg}
+			m.data.UsersToMigrate.store(idString, &userMigrationDetails{ZendeskUser: &user, PsaCompany: org.PsaOrg, Org: org})
 		}
-		m.mu.Unlock()
 
-		m.orgsCheckedForUsers++
+		m.orgsCheckedForUsers.inc()
 		return nil
 	}
 }
 
-func (m *Model) migrateUsers(users map[string]*userMigrationDetails) tea.Cmd {
+func (m *Model) migrateUsers(users *safeMap[*userMigrationDetails]) tea.Cmd {
 	return func() tea.Msg {
 		slog.Debug("migrateUsers: called")
 
-		sem := make(chan struct{}, totalConcurrentUsers)
+		sem := make(chan struct{}, m.client.Cfg.UserWorkers)
 		var wg sync.WaitGroup
 
-		for _, user := range users {
-			m.mu.Lock()
-			if m.errCapture.flag && m.errCapture.err != nil && m.client.Cfg.StopAtError {
-				slog.Debug("runTicketMigration: stopping user migration due to error")
-				m.mu.Unlock()
+		for _, group := range groupUsersByEmail(users.snapshot()) {
+			if m.hasErr() && m.client.Cfg.StopAtError {
+				slog.Debug("migrateUsers: stopping user migration due to error")
 				break
 			}
-			m.mu.Unlock()
 
 			sem <- struct{}{}
 			wg.Add(1)
 
-			go func(user *userMigrationDetails) {
+			// users sharing an email are migrated one after the other, so they all end up with the same contact
+			go func(group []*userMigrationDetails) {
 				defer wg.Done()
 				defer func() { <-sem }()
 
-				slog.Debug("migrateUsers: migrating user", "userName", user.ZendeskUser.Name)
-				if err := m.migrateUser(user); err != nil {
-					slog.Error("migrateUsers: error migrating user", "userName", user.ZendeskUser.Name, "error", err)
-					m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s (%d): couldn't migrate user: %s", user.ZendeskUser.Name, user.ZendeskUser.Id, err)), errOutput)
-					m.errCapture.flag = true
-					m.errCapture.err = err
-					m.userMigrationErrors++
-					m.usersProcessed++
-				} else {
-					m.usersProcessed++
+				for _, user := range group {
+					slog.Debug("migrateUsers: migrating user", "userName", user.ZendeskUser.Name)
+					started := time.Now()
+					if err := m.migrateUser(user); err != nil {
+						slog.Error("migrateUsers: error migrating user", "userName", user.ZendeskUser.Name, "error", err)
+						m.writeToOutput(badRedOutput("ERROR", fmt.Sprintf("%s (%d): couldn't migrate user: %s", user.ZendeskUser.Name, user.ZendeskUser.Id, err)), errOutput)
+						m.recordFailed(userRecord(user, actionFailed, err.Error(), started), err)
+						m.updateErrCapture(err)
+						m.userMigrationErrors.inc()
+						m.usersProcessed.inc()
+					} else {
+						m.usersProcessed.inc()
+					}
 				}
-			}(user)
+			}(group)
 		}
 
 		wg.Wait()
 		slog.Debug("migrateUsers: done")
 
-		if m.errCapture.flag && m.errCapture.err != nil && m.client.Cfg.StopAtError {
+		if m.hasErr() && m.client.Cfg.StopAtError {
 			slog.Info("migrateUsers: stopping after error as per configuration")
 			return switchStatusMsg(errored)
 		}
@@ -98,82 +100,203 @@ func (m *Model) migrateUsers(users map[string]*userMigrationDetails) tea.Cmd {
 }
 
 func (m *Model) migrateUser(user *userMigrationDetails) error {
-	if user.ZendeskUser.Email == "" {
+	started := time.Now()
+	if user.ZendeskUser.UserFields.PSAContactId != 0 {
+		m.useLinkedContact(user, started)
+		return nil
+	}
+
+	// identities are only needed to match the user by email or create their contact
+	var err error
+	if !user.Deleted {
+		// identities of deleted users are no longer available
+		user.Identities, err = m.client.ZendeskClient.GetUserIdentities(m.ctx, int64(user.ZendeskUser.Id))
+		if err != nil {
+			slog.Error("migrateUser: error getting zendesk user identities", "userName", user.ZendeskUser.Name, "zendeskUserId", user.ZendeskUser.Id, "error", err)
+			return fmt.Errorf("getting zendesk user identities: %w", err)
+		}
+	}
+
+	if len(user.emails()) == 0 {
 		slog.Warn("migrateUser: zendesk user has no email address - skipping", "userName", user.ZendeskUser.Name)
 		m.writeToOutput(warnYellowOutput("WARN", fmt.Sprintf("%s (%d): user has no email address, skipping migration", user.ZendeskUser.Name, user.ZendeskUser.Id)), warnOutput)
+		m.recordUser(user, actionSkipped, "no email address", started)
+		return nil
+	}
+
+	var created bool
+	user.PsaContact, created, err = m.matchOrCreateContact(user)
+	if errors.Is(err, errContactSkipped) {
+		slog.Info("migrateUser: user skipped at duplicate contact prompt", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id)
+		m.writeToOutput(goodBlueOutput("NO ACTION", fmt.Sprintf("%s (%d): user skipped at the duplicate contact prompt", user.ZendeskUser.Name, user.ZendeskUser.Id)), noActionOutput)
+		m.recordUser(user, actionSkipped, err.Error(), started)
 		return nil
 	}
 
-	var err error
-	user.PsaContact, err = m.matchZdUserToCwContact(user.ZendeskUser)
 	if err != nil {
+		return err
+	}
 
-		if errors.Is(err, psa.NoUserFoundErr{}) {
-			slog.Debug("migrateUser: user does not exist in psa - attempting to create new user", "userEmail", user.ZendeskUser.Email)
-			user.PsaContact, err = m.createPsaContact(user)
-			if err != nil {
-				slog.Error("migrateUser: error creating user", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "error", err)
-				return fmt.Errorf("creating psa contact: %w", err)
-			} else {
-				slog.Debug("migrateUser: created new psa user", "userName", user.ZendeskUser.Email, "psaContactId", user.PsaContact.Id)
-			}
+	if user.PsaContact == nil {
+		m.recordUser(user, actionSkipped, "suspended or deleted in zendesk", started)
+		return nil
+	}
+
+	slog.Debug("migrateUser: matched zendesk user to psa user", "userEmail", user.ZendeskUser.Email, "psaContactId", user.PsaContact.Id)
+	if user.Deleted {
+		slog.Debug("migrateUser: user is deleted in zendesk - not updating psa contact field", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id)
+	} else if err := m.updateContactFieldValue(user); err != nil {
+		slog.Error("migrateUser: error updating user contact field value in zendesk", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "psaContactId", user.PsaContact.Id, "error", err)
+		return fmt.Errorf("updating zendesk user contact field value: %w", err)
+	}
+
+	slog.Info("migrateUser: new user migrated", "userEmail", user.ZendeskUser.Email, "psaContactId", user.PsaContact.Id)
+	m.data.UsersInPsa.store(strconv.Itoa(user.ZendeskUser.Id), user)
+	if created {
+		m.recordUser(user, actionCreated, "", started)
+	} else {
+		m.recordUser(user, actionMatched, "matched existing contact by email", started)
+	}
+
+	m.newUsersCreated.inc()
+	return nil
+}
+
+// useLinkedContact keeps the contact a user is already linked to in Zendesk, without looking up their identities or
+// matching them by email again. The contact is cached against their primary email, so unlinked users sharing it
+// get the same contact.
+func (m *Model) useLinkedContact(user *userMigrationDetails, started time.Time) {
+	user.PsaContact = &psa.Contact{Id: user.ZendeskUser.UserFields.PSAContactId}
+	if email := strings.ToLower(user.ZendeskUser.Email); email != "" {
+		unlock := m.contactLocks.lock(email)
+		m.data.ContactsByEmail.loadOrStore(email, user.PsaContact)
+		unlock()
+	}
+
+	slog.Debug("useLinkedContact: user already has psa contact id field - skipping", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "psaContactId", user.PsaContact.Id)
+	m.data.UsersInPsa.store(strconv.Itoa(user.ZendeskUser.Id), user)
+	m.recordUser(user, actionMatched, "already linked to this contact", started)
+}
+
+// matchOrCreateContact finds the user's contact in the PSA, or creates it if it doesn't exist. The check and the
+// creation happen under a lock on each of the user's emails, and the contact is cached against those emails, so
+// Zendesk users sharing an email never end up with two contacts. A nil contact means the user was skipped, and
+// created reports whether a new contact was made for this user.
+func (m *Model) matchOrCreateContact(user *userMigrationDetails) (contact *psa.Contact, created bool, err error) {
+	var keys []string
+	for _, email := range user.emails() {
+		keys = append(keys, strings.ToLower(email))
+	}
 
-		} else {
-			slog.Error("migrateUser: error matching zendesk user to psa user", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "error", err)
-			return fmt.Errorf("matching zendesk user to psa contact: %w", err)
+	unlock := m.contactLocks.lock(keys...)
+	defer unlock()
+
+	for _, key := range keys {
+		if contact, ok := m.data.ContactsByEmail.load(key); ok {
+			slog.Debug("matchOrCreateContact: contact already found for email", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "psaContactId", contact.Id)
+			return contact, false, nil
 		}
 	}
 
-	slog.Debug("migrateUser: matched zendesk user to psa user", "userEmail", user.ZendeskUser.Email, "psaContactId", user.PsaContact.Id)
-	if user.ZendeskUser.UserFields.PSAContactId != user.PsaContact.Id {
-		if err := m.updateContactFieldValue(user); err != nil {
-			slog.Error("migrateUser: error updating user contact field value in zendesk", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "psaContactId", user.PsaContact.Id, "error", err)
-			return fmt.Errorf("updating zendesk user contact field value: %w", err)
+	contact, err = m.matchZdUserToCwContact(user)
+	if err != nil {
+		if errors.Is(err, errContactSkipped) {
+			return nil, false, err
 		}
-	} else {
-		slog.Debug("migrateUser: user already has psa contact id field - skipping", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "psaContactId", user.PsaContact.Id)
-		m.mu.Lock()
-		m.data.UsersInPsa[strconv.Itoa(user.ZendeskUser.Id)] = user
-		m.mu.Unlock()
 
-		return nil
+		if !errors.Is(err, psa.NoUserFoundErr{}) {
+			slog.Error("matchOrCreateContact: error matching zendesk user to psa user", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "error", err)
+			return nil, false, fmt.Errorf("matching zendesk user to psa contact: %w", err)
+		}
+
+		if user.ZendeskUser.Inactive() && m.client.Cfg.Zendesk.ExcludeInactiveUsers {
+			slog.Info("matchOrCreateContact: inactive user excluded from contact creation", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id)
+			m.writeToOutput(goodBlueOutput("NO ACTION", fmt.Sprintf("%s (%d): user is suspended or deleted in zendesk, not creating contact", user.ZendeskUser.Name, user.ZendeskUser.Id)), noActionOutput)
+			return nil, false, nil
+		}
+
+		slog.Debug("matchOrCreateContact: user does not exist in psa - attempting to create new user", "userEmail", user.ZendeskUser.Email)
+		contact, err = m.createPsaContact(user)
+		if err != nil {
+			slog.Error("matchOrCreateContact: error creating user", "userEmail", user.ZendeskUser.Email, "zendeskUserId", user.ZendeskUser.Id, "error", err)
+			return nil, false, fmt.Errorf("creating psa contact: %w", err)
+		}
+		created = true
+
+		slog.Debug("matchOrCreateContact: created new psa user", "userName", user.ZendeskUser.Email, "psaContactId", contact.Id)
 	}
 
-	slog.Info("migrateUser: new user migrated", "userEmail", user.ZendeskUser.Email, "psaContactId", user.PsaContact.Id)
-	m.mu.Lock()
-	m.data.UsersInPsa[strconv.Itoa(user.ZendeskUser.Id)] = user
-	m.mu.Unlock()
+	for _, key := range keys {
+		m.data.ContactsByEmail.store(key, contact)
+	}
 
-	m.newUsersCreated++
-	return nil
+	return contact, created, nil
 }
 
-func (m *Model) matchZdUserToCwContact(user *zendesk.User) (*psa.Contact, error) {
-	if user == nil {
+// groupUsersByEmail groups users by their primary email, so users sharing an email are migrated together.
+// Users without an email are each put in their own group.
+func groupUsersByEmail(users map[string]*userMigrationDetails) [][]*userMigrationDetails {
+	groups := make(map[string][]*userMigrationDetails)
+	var order []string
+	for id, user := range users {
+		key := strings.ToLower(strings.TrimSpace(user.ZendeskUser.Email))
+		if key == "" {
+			key = "id:" + id
+		}
+
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], user)
+	}
+
+	var grouped [][]*userMigrationDetails
+	for _, key := range order {
+		if len(groups[key]) > 1 {
+			slog.Debug("groupUsersByEmail: multiple zendesk users share an email", "email", key, "count", len(groups[key]))
+		}
+		grouped = append(grouped, groups[key])
+	}
+
+	return grouped
+}
+
+func (m *Model) matchZdUserToCwContact(user *userMigrationDetails) (*psa.Contact, error) {
+	if user == nil || user.ZendeskUser == nil {
 		return nil, errors.New("user is nil")
 	}
 
-	if user.Email == "" {
+	emails := user.emails()
+	if len(emails) == 0 {
 		return nil, errors.New("user email is empty")
 	}
 
-	contact, err := m.client.CwClient.GetContactByEmail(m.ctx, user.Email)
+	contact, err := m.client.CwClient.GetContactByEmail(m.ctx, emails...)
 	if err != nil {
+		var dupErr psa.MultipleContactsErr
+		if errors.As(err, &dupErr) {
+			return m.resolveDuplicateContacts(user, dupErr.Contacts)
+		}
+
 		return nil, err
 	}
 	return contact, nil
 }
 
 func (m *Model) createPsaContact(user *userMigrationDetails) (*psa.Contact, error) {
-	c := &psa.ContactPostBody{}
-	c.FirstName, c.LastName = separateName(user.ZendeskUser.Name)
-	if len(c.FirstName) > 30 {
-		return nil, errors.New("first name longer than 30 characters")
+	c := &psa.ContactPostBody{
+		Title:        user.ZendeskUser.UserFields.Title,
+		InactiveFlag: user.ZendeskUser.Inactive(),
+	}
+	name := parseContactName(user.ZendeskUser.Name, user.ZendeskUser.Email)
+	if name.First == "" {
+		return nil, errors.New("could not determine a first name for the contact")
 	}
 
-	if len(c.LastName) > 30 {
-		return nil, errors.New("last name longer than 30 characters")
+	if name.Changed {
+		slog.Debug("createPsaContact: contact name was reformatted", "zendeskName", name.Original, "firstName", name.First, "lastName", name.Last)
 	}
+	c.FirstName, c.LastName = name.First, name.Last
 
 	if user.PsaCompany == nil {
 		return nil, errors.New("user psa company is nil")
@@ -181,15 +304,183 @@ func (m *Model) createPsaContact(user *userMigrationDetails) (*psa.Contact, erro
 
 	c.Company.Id = user.PsaCompany.Id
 
-	c.CommunicationItems = []psa.CommunicationItem{
-		{
+	c.Title, _ = truncateRunes(c.Title, psaTitleMaxLength)
+
+	c.CommunicationItems = user.communicationItems()
+
+	contact, err := m.client.CwClient.PostContact(m.ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if note := user.contactNoteText(name); note != "" {
+		if err := m.client.CwClient.PostContactNote(m.ctx, contact.Id, &psa.ContactNote{Text: note}); err != nil {
+			slog.Warn("createPsaContact: error creating contact note", "userEmail", user.ZendeskUser.Email, "psaContactId", contact.Id, "error", err)
+			m.writeToOutput(warnYellowOutput("WARN", fmt.Sprintf("%s (%d): contact created but notes could not be added: %s", user.ZendeskUser.Name, user.ZendeskUser.Id, err)), warnOutput)
+		}
+	}
+
+	return contact, nil
+}
+
+// emails returns the user's primary email followed by any other verified email identities, without duplicates.
+func (u *userMigrationDetails) emails() []string {
+	var emails []string
+	seen := make(map[string]bool)
+	add := func(email string) {
+		key := strings.ToLower(email)
+		if email == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		emails = append(emails, email)
+	}
+
+	add(u.ZendeskUser.Email)
+	for _, identity := range u.Identities {
+		if identity.Type == zendesk.EmailIdentityType && identity.Verified {
+			add(identity.Value)
+		}
+	}
+
+	return emails
+}
+
+// phones returns the user's phone numbers. The phone on the user profile (or the primary phone identity if
+// there isn't one) is treated as the direct line, and any other phone identities are treated as mobile numbers,
+// since Zendesk doesn't distinguish between the two.
+func (u *userMigrationDetails) phones() (string, []string) {
+	direct := u.ZendeskUser.Phone
+	var mobile []string
+	for _, identity := range u.Identities {
+		if identity.Type != zendesk.PhoneIdentityType || identity.Value == "" {
+			continue
+		}
+
+		if direct == "" && identity.Primary {
+			direct = identity.Value
+			continue
+		}
+
+		if identity.Value != direct {
+			mobile = append(mobile, identity.Value)
+		}
+	}
+
+	return direct, mobile
+}
+
+func (u *userMigrationDetails) communicationItems() []psa.CommunicationItem {
+	var items []psa.CommunicationItem
+	for i, email := range u.emails() {
+		items = append(items, psa.CommunicationItem{
 			Type:              psa.CommunicationItemType{Name: "Email"},
-			Value:             user.ZendeskUser.Email,
+			Value:             email,
 			CommunicationType: "Email",
-		},
+			DefaultFlag:       i == 0,
+		})
+	}
+
+	direct, mobile := u.phones()
+	if direct != "" {
+		items = append(items, psa.CommunicationItem{
+			Type:              psa.CommunicationItemType{Name: "Direct"},
+			Value:             direct,
+			CommunicationType: "Phone",
+			DefaultFlag:       true,
+		})
+	}
+
+	for i, phone := range mobile {
+		items = append(items, psa.CommunicationItem{
+			Type:              psa.CommunicationItemType{Name: "Mobile"},
+			Value:             phone,
+			CommunicationType: "Phone",
+			DefaultFlag:       direct == "" && i == 0,
+		})
 	}
 
-	return m.client.CwClient.PostContact(m.ctx, c)
+	return items
+}
+
+// contactNoteText builds the text of the note added to new contacts, holding the Zendesk profile details
+// that don't have an equivalent field in ConnectWise PSA.
+func (u *userMigrationDetails) contactNoteText(name contactName) string {
+	var parts []string
+	if name.Changed && name.Original != "" {
+		parts = append(parts, fmt.Sprintf("Original Zendesk Name: %s", name.Original))
+	}
+
+	if u.ZendeskUser.Notes != "" {
+		parts = append(parts, fmt.Sprintf("Zendesk Notes:\n%s", u.ZendeskUser.Notes))
+	}
+
+	if u.ZendeskUser.Details != "" {
+		parts = append(parts, fmt.Sprintf("Zendesk Details:\n%s", u.ZendeskUser.Details))
+	}
+
+	if u.ZendeskUser.TimeZone != "" {
+		parts = append(parts, fmt.Sprintf("Time Zone: %s", u.ZendeskUser.TimeZone))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("Migrated from Zendesk user %d\n\n%s", u.ZendeskUser.Id, strings.Join(parts, "\n\n"))
+}
+
+// migrateInactiveRequester migrates a ticket requester who wasn't found in the selected orgs' user lists, so
+// historical tickets can still be linked to them. Zendesk doesn't list deleted users against their org, so this is
+// only done for suspended or deleted users - active users outside the selected orgs are left alone. Each requester
+// is only looked up once per run: linked requesters are added to UsersInPsa, and the rest to RequestersNotInPsa.
+func (m *Model) migrateInactiveRequester(org *orgMigrationDetails, userId int64) (*userMigrationDetails, error) {
+	key := strconv.FormatInt(userId, 10)
+
+	// tickets from the same requester are often migrated at once, so only the first looks them up
+	unlock := m.contactLocks.lock("requester:" + key)
+	defer unlock()
+
+	if user, ok := m.data.UsersInPsa.load(key); ok {
+		return user, nil
+	}
+
+	if reason, ok := m.data.RequestersNotInPsa.load(key); ok {
+		slog.Debug("migrateInactiveRequester: requester already looked up", "zendeskUserId", userId, "reason", reason)
+		return nil, nil
+	}
+
+	user := &userMigrationDetails{PsaCompany: org.PsaOrg, Org: org}
+
+	var err error
+	user.ZendeskUser, err = m.client.ZendeskClient.GetUser(m.ctx, userId)
+	if err != nil {
+		slog.Debug("migrateInactiveRequester: user not found, checking deleted users", "zendeskUserId", userId, "error", err)
+		user.ZendeskUser, err = m.client.ZendeskClient.GetDeletedUser(m.ctx, userId)
+		if err != nil {
+			m.data.RequestersNotInPsa.store(key, "not found in zendesk")
+			return nil, fmt.Errorf("getting zendesk user: %w", err)
+		}
+		user.Deleted = true
+	}
+
+	if !user.ZendeskUser.Inactive() {
+		m.data.RequestersNotInPsa.store(key, "active user outside the selected orgs")
+		return nil, nil
+	}
+
+	// a failure here isn't remembered, so a later ticket from the same requester tries again
+	if err := m.migrateUser(user); err != nil {
+		return nil, fmt.Errorf("migrating inactive user: %w", err)
+	}
+
+	if user.PsaContact == nil {
+		m.data.RequestersNotInPsa.store(key, "no psa contact for inactive user")
+		return nil, nil
+	}
+
+	m.data.UsersInPsa.store(key, user)
+	return user, nil
 }
 
 type ZendeskFieldAlreadySetErr struct{}
@@ -219,12 +510,3 @@ func (m *Model) updateContactFieldValue(user *userMigrationDetails) error {
 		return errors.New("user psa id is 0 - cannot update psa_contact field in zendesk")
 	}
 }
-
-func separateName(name string) (string, string) {
-	nameParts := strings.Split(name, " ")
-	if len(nameParts) == 1 {
-		return nameParts[0], ""
-	}
-
-	return nameParts[0], strings.Join(nameParts[1:], " ")
-}
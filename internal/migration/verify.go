@@ -0,0 +1,610 @@
+package migration
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/psa"
+	"github.com/dsrosen/zendesk-connectwise-migrator/internal/zendesk"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	verifyDirName  = "verify"
+	verifyCsvName  = "verify.csv"
+	verifyJsonName = "verify.json"
+)
+
+// ErrNotReconciled is returned by Verify when it finds any ticket that doesn't match between Zendesk and the PSA.
+// Tickets the migration skipped on purpose, such as those over the ticket limit, don't count against it.
+var ErrNotReconciled = errors.New("migration is not reconciled")
+
+type verifyResult string
+
+const (
+	verifyOk       verifyResult = "ok"
+	verifyMissing  verifyResult = "missing"
+	verifySkipped  verifyResult = "skipped"
+	verifyExtra    verifyResult = "extra"
+	verifyMismatch verifyResult = "mismatch"
+	verifyErrored  verifyResult = "error"
+)
+
+// ticketVerification is the result of checking one ticket
+type ticketVerification struct {
+	Org       string       `json:"org"`
+	ZendeskId int          `json:"zendesk_id,omitempty"`
+	PsaId     int          `json:"psa_id,omitempty"`
+	Result    verifyResult `json:"result"`
+	Issues    []string     `json:"issues,omitempty"`
+	Comments  int          `json:"zendesk_comments,omitempty"`
+	Notes     int          `json:"psa_notes,omitempty"`
+}
+
+// orgVerification totals the ticket results for one org
+type orgVerification struct {
+	Org            string `json:"org"`
+	ZendeskOrgId   int64  `json:"zendesk_org_id"`
+	PsaCompanyId   int    `json:"psa_company_id"`
+	ZendeskTickets int    `json:"zendesk_tickets"`
+	PsaTickets     int    `json:"psa_tickets"`
+	Ok             int    `json:"ok"`
+	Missing        int    `json:"missing"`
+	Skipped        int    `json:"skipped"`
+	Extra          int    `json:"extra"`
+	Mismatched     int    `json:"mismatched"`
+	Errors         int    `json:"errors"`
+	Err            string `json:"error,omitempty"`
+}
+
+func (o *orgVerification) add(t ticketVerification) {
+	switch t.Result {
+	case verifyOk:
+		o.Ok++
+	case verifyMissing:
+		o.Missing++
+	case verifySkipped:
+		o.Skipped++
+	case verifyExtra:
+		o.Extra++
+	case verifyMismatch:
+		o.Mismatched++
+	case verifyErrored:
+		o.Errors++
+	}
+}
+
+func (o *orgVerification) reconciled() bool {
+	return o.Err == "" && o.Missing == 0 && o.Extra == 0 && o.Mismatched == 0 && o.Errors == 0
+}
+
+func (o *orgVerification) String() string {
+	if o.Err != "" {
+		return fmt.Sprintf("%s: couldn't verify: %s", o.Org, o.Err)
+	}
+
+	return fmt.Sprintf("%s: %d Zendesk tickets, %d PSA tickets - %d ok, %d missing, %d skipped, %d extra, %d mismatched, %d errors",
+		o.Org, o.ZendeskTickets, o.PsaTickets, o.Ok, o.Missing, o.Skipped, o.Extra, o.Mismatched, o.Errors)
+}
+
+// verifyReport is the reconciliation report written by Verify
+type verifyReport struct {
+	StartedAt    time.Time            `json:"started_at"`
+	FinishedAt   time.Time            `json:"finished_at"`
+	Reconciled   bool                 `json:"reconciled"`
+	Orgs         []orgVerification    `json:"orgs"`
+	UnlinkedOrgs []string             `json:"unlinked_orgs,omitempty"`
+	Tickets      []ticketVerification `json:"tickets"`
+}
+
+var verifyCsvHeader = []string{"org", "zendesk_id", "psa_id", "result", "zendesk_comments", "psa_notes", "issues"}
+
+func (t ticketVerification) csvRow() []string {
+	itoa := func(i int) string {
+		if i == 0 {
+			return ""
+		}
+		return strconv.Itoa(i)
+	}
+
+	return []string{t.Org, itoa(t.ZendeskId), itoa(t.PsaId), string(t.Result), itoa(t.Comments), itoa(t.Notes), strings.Join(t.Issues, "; ")}
+}
+
+// Verify checks every migrated ticket in the orgs linked to a PSA company against Zendesk, and writes a
+// reconciliation report. It makes no changes. ErrNotReconciled is returned if anything doesn't match.
+func Verify(opts CliOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, client, err := startup(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	m, err := newModel(ctx, client)
+	if err != nil {
+		return fmt.Errorf("initializing verification: %w", err)
+	}
+
+	skipped, err := loadSkippedTickets(dir)
+	if err != nil {
+		return fmt.Errorf("loading skipped tickets from run reports: %w", err)
+	}
+
+	report, err := m.verifyMigration(skipped)
+	if err != nil {
+		return fmt.Errorf("verifying migration: %w", err)
+	}
+
+	reportDir, err := report.write(dir)
+	if err != nil {
+		return fmt.Errorf("writing verification report: %w", err)
+	}
+
+	for _, org := range report.Orgs {
+		fmt.Println(org.String())
+	}
+
+	if len(report.UnlinkedOrgs) > 0 {
+		fmt.Printf("%d orgs aren't linked to a PSA company and weren't checked\n", len(report.UnlinkedOrgs))
+	}
+
+	fmt.Printf("Verification report saved to %s\n", reportDir)
+
+	if !report.Reconciled {
+		return ErrNotReconciled
+	}
+
+	fmt.Println("All migrated tickets reconciled")
+	return nil
+}
+
+// verifyMigration gets the orgs for every tag in the config and checks the tickets of each one linked to a PSA
+// company. Tickets missing from the PSA that are in skipped, by Zendesk ID, are reported as skipped with the reason.
+func (m *Model) verifyMigration(skipped map[int64]string) (*verifyReport, error) {
+	report := &verifyReport{StartedAt: time.Now()}
+
+	if msg, ok := m.getTagDetails()().(timeConvertErrMsg); ok {
+		return nil, fmt.Errorf("getting tag details: %w", msg.Err)
+	}
+
+	if msg, ok := m.getOrgs()().(apiErrMsg); ok {
+		return nil, fmt.Errorf("getting zendesk orgs: %w", msg.Err)
+	}
+
+	psaTickets, err := m.getMigratedPsaTickets()
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := m.data.AllOrgs.snapshot()
+	var linked []*orgMigrationDetails
+	for _, org := range orgs {
+		companyId := org.ZendeskOrg.OrganizationFields.PSACompanyId
+		if companyId == 0 {
+			report.UnlinkedOrgs = append(report.UnlinkedOrgs, org.ZendeskOrg.Name)
+			continue
+		}
+
+		org.PsaOrg = &psa.Company{Id: int(companyId), Name: org.ZendeskOrg.Name}
+		linked = append(linked, org)
+	}
+
+	sort.Slice(linked, func(i, j int) bool {
+		return linked[i].ZendeskOrg.Name < linked[j].ZendeskOrg.Name
+	})
+	sort.Strings(report.UnlinkedOrgs)
+
+	claimed := make(map[int]bool)
+	firstOrg := make(map[int]int)
+	uncheckedCompanies := make(map[int]bool)
+	for _, org := range linked {
+		summary, tickets := m.verifyOrg(org, psaTickets[org.PsaOrg.Id], claimed, skipped)
+		slog.Info("verifyMigration: org verified", "orgName", org.ZendeskOrg.Name, "summary", summary.String())
+
+		if _, ok := firstOrg[org.PsaOrg.Id]; !ok {
+			firstOrg[org.PsaOrg.Id] = len(report.Orgs)
+		}
+
+		if summary.Err != "" {
+			uncheckedCompanies[org.PsaOrg.Id] = true
+		}
+
+		report.Orgs = append(report.Orgs, summary)
+		report.Tickets = append(report.Tickets, tickets...)
+	}
+
+	// more than one org can be linked to a company, so PSA tickets are only extra if no org's tickets matched them
+	for companyId, i := range firstOrg {
+		if uncheckedCompanies[companyId] {
+			continue
+		}
+
+		summary := &report.Orgs[i]
+		for _, ticket := range psaTickets[companyId] {
+			if claimed[ticket.Id] {
+				continue
+			}
+
+			zendeskId, _ := m.zendeskTicketId(ticket)
+			extra := ticketVerification{
+				Org:       summary.Org,
+				ZendeskId: zendeskId,
+				PsaId:     ticket.Id,
+				Result:    verifyExtra,
+				Issues:    []string{"zendesk ticket isn't in a linked org's date range"},
+			}
+
+			summary.PsaTickets++
+			summary.add(extra)
+			report.Tickets = append(report.Tickets, extra)
+		}
+	}
+
+	report.Reconciled = true
+	for _, summary := range report.Orgs {
+		if !summary.reconciled() {
+			report.Reconciled = false
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// getMigratedPsaTickets gets every PSA ticket with a Zendesk ticket ID, grouped by company, and fills in TicketsInPsa
+func (m *Model) getMigratedPsaTickets() (map[int][]psa.Ticket, error) {
+	tickets, err := m.client.CwClient.GetMigratedTickets(m.ctx, m.data.PsaInfo.ZendeskTicketIdField.Id, m.client.Cfg.PsaPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("getting migrated tickets from psa: %w", err)
+	}
+
+	byCompany := make(map[int][]psa.Ticket)
+	for _, ticket := range tickets {
+		zendeskId, ok := m.zendeskTicketId(ticket)
+		if !ok || ticket.Company == nil {
+			continue
+		}
+
+		m.data.TicketsInPsa.store(strconv.Itoa(zendeskId), ticket.Id)
+		byCompany[ticket.Company.Id] = append(byCompany[ticket.Company.Id], ticket)
+	}
+
+	slog.Debug("getMigratedPsaTickets: found migrated tickets", "count", len(tickets), "companies", len(byCompany))
+	return byCompany, nil
+}
+
+// zendeskTicketId returns the Zendesk ticket ID stored in a PSA ticket's custom field
+func (m *Model) zendeskTicketId(ticket psa.Ticket) (int, bool) {
+	for _, field := range ticket.CustomFields {
+		if field.Id == m.data.PsaInfo.ZendeskTicketIdField.Id {
+			if v, ok := field.Value.(float64); ok {
+				return int(v), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// verifyOrg compares an org's Zendesk tickets against the PSA tickets in its company. Tickets only in Zendesk are
+// missing, unless the migration skipped them, and a second PSA ticket for the same Zendesk ticket is extra. Every PSA
+// ticket matched to one of the org's tickets is added to claimed.
+func (m *Model) verifyOrg(org *orgMigrationDetails, psaTickets []psa.Ticket, claimed map[int]bool, skipped map[int64]string) (orgVerification, []ticketVerification) {
+	summary := orgVerification{
+		Org:          org.ZendeskOrg.Name,
+		ZendeskOrgId: org.ZendeskOrg.Id,
+		PsaCompanyId: org.PsaOrg.Id,
+	}
+
+	zTickets, err := m.getZendeskTickets(org)
+	if err != nil {
+		slog.Error("verifyOrg: getting zendesk tickets", "orgName", org.ZendeskOrg.Name, "error", err)
+		summary.Err = err.Error()
+		return summary, nil
+	}
+	summary.ZendeskTickets = len(zTickets)
+
+	contactIds, err := m.orgContactIds(org)
+	if err != nil {
+		slog.Error("verifyOrg: getting zendesk users", "orgName", org.ZendeskOrg.Name, "error", err)
+		summary.Err = err.Error()
+		return summary, nil
+	}
+
+	inOrg := make(map[int]bool)
+	for _, ticket := range zTickets {
+		inOrg[ticket.Id] = true
+	}
+
+	byZendeskId := make(map[int]psa.Ticket)
+	var results []ticketVerification
+	for _, ticket := range psaTickets {
+		zendeskId, _ := m.zendeskTicketId(ticket)
+		if !inOrg[zendeskId] || claimed[ticket.Id] {
+			continue
+		}
+		claimed[ticket.Id] = true
+		summary.PsaTickets++
+
+		if existing, ok := byZendeskId[zendeskId]; ok {
+			results = append(results, ticketVerification{
+				Org:       org.ZendeskOrg.Name,
+				ZendeskId: zendeskId,
+				PsaId:     ticket.Id,
+				Result:    verifyExtra,
+				Issues:    []string{fmt.Sprintf("duplicate of psa ticket %d", existing.Id)},
+			})
+			continue
+		}
+		byZendeskId[zendeskId] = ticket
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.client.Cfg.TicketWorkers)
+	for _, zTicket := range zTickets {
+		psaTicket, ok := byZendeskId[zTicket.Id]
+		if reason, wasSkipped := skipped[int64(zTicket.Id)]; !ok && wasSkipped {
+			results = append(results, ticketVerification{
+				Org:       org.ZendeskOrg.Name,
+				ZendeskId: zTicket.Id,
+				Result:    verifySkipped,
+				Issues:    []string{reason},
+			})
+			continue
+		}
+
+		if !ok {
+			results = append(results, ticketVerification{
+				Org:       org.ZendeskOrg.Name,
+				ZendeskId: zTicket.Id,
+				Result:    verifyMissing,
+				Issues:    []string{"not in psa"},
+			})
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(zTicket zendesk.Ticket, psaTicket psa.Ticket) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := m.verifyTicket(org, zTicket, psaTicket, contactIds)
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}(zTicket, psaTicket)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ZendeskId != results[j].ZendeskId {
+			return results[i].ZendeskId < results[j].ZendeskId
+		}
+		return results[i].PsaId < results[j].PsaId
+	})
+
+	for _, r := range results {
+		summary.add(r)
+	}
+
+	return summary, results
+}
+
+// verifyTicket checks a migrated ticket's notes, status, contact and closed date against the Zendesk ticket
+func (m *Model) verifyTicket(org *orgMigrationDetails, zTicket zendesk.Ticket, psaTicket psa.Ticket, contactIds map[int64]int) ticketVerification {
+	result := ticketVerification{
+		Org:       org.ZendeskOrg.Name,
+		ZendeskId: zTicket.Id,
+		PsaId:     psaTicket.Id,
+	}
+
+	comments, err := m.client.ZendeskClient.GetAllTicketComments(m.ctx, int64(zTicket.Id))
+	if err != nil {
+		result.Result = verifyErrored
+		result.Issues = append(result.Issues, fmt.Sprintf("getting zendesk comments: %s", err))
+		return result
+	}
+	result.Comments = len(comments)
+
+	result.Notes, err = m.client.CwClient.GetTicketNoteCount(m.ctx, psaTicket.Id)
+	if err != nil {
+		result.Result = verifyErrored
+		result.Issues = append(result.Issues, fmt.Sprintf("getting psa note count: %s", err))
+		return result
+	}
+
+	// a subject that was too long for the summary is kept in an extra internal note
+	expectedNotes := result.Comments
+	if len(zTicket.Subject) > maxPsaSummaryLength {
+		expectedNotes++
+	}
+
+	if result.Notes != expectedNotes {
+		result.Issues = append(result.Issues, fmt.Sprintf("%d zendesk comments but %d psa notes", result.Comments, result.Notes))
+	}
+
+	closed := zTicket.Status == "closed" || zTicket.Status == "solved"
+	if closed && (psaTicket.Status == nil || psaTicket.Status.Id != m.data.PsaInfo.StatusClosed.Id) {
+		result.Issues = append(result.Issues, fmt.Sprintf("%s in zendesk but not closed in psa", zTicket.Status))
+	}
+
+	if contactId, ok := m.requesterContactId(zTicket.RequesterId, contactIds); ok {
+		if psaTicket.Contact == nil || psaTicket.Contact.Id != contactId {
+			result.Issues = append(result.Issues, fmt.Sprintf("contact isn't the requester's contact %d", contactId))
+		}
+	}
+
+	if closed {
+		want := zTicket.UpdatedAt.In(m.timeZone).Format("2006-01-02")
+		if got := m.closedDate(psaTicket); got != want {
+			result.Issues = append(result.Issues, fmt.Sprintf("closed date is %q, want %s", got, want))
+		}
+	}
+
+	result.Result = verifyOk
+	if len(result.Issues) > 0 {
+		result.Result = verifyMismatch
+	}
+
+	return result
+}
+
+// orgContactIds returns the PSA contact linked to each of the org's Zendesk users
+func (m *Model) orgContactIds(org *orgMigrationDetails) (map[int64]int, error) {
+	users, err := m.client.ZendeskClient.GetOrganizationUsers(m.ctx, org.ZendeskOrg.Id)
+	if err != nil {
+		return nil, fmt.Errorf("getting zendesk users for org: %w", err)
+	}
+
+	ids := make(map[int64]int)
+	for _, user := range users {
+		if user.UserFields.PSAContactId != 0 {
+			ids[int64(user.Id)] = user.UserFields.PSAContactId
+		}
+	}
+
+	return ids, nil
+}
+
+// requesterContactId returns the PSA contact the requester is linked to, looking up requesters outside the org
+// in Zendesk. Requesters that aren't linked, such as deleted users, can't be checked.
+func (m *Model) requesterContactId(requesterId int64, contactIds map[int64]int) (int, bool) {
+	if id, ok := contactIds[requesterId]; ok {
+		return id, true
+	}
+
+	key := strconv.FormatInt(requesterId, 10)
+	user, ok := m.data.ExternalUsers.load(key)
+	if !ok {
+		var err error
+		user, err = m.client.ZendeskClient.GetUser(m.ctx, requesterId)
+		if err != nil {
+			slog.Debug("requesterContactId: couldn't get requester", "zendeskUserId", requesterId, "error", err)
+			return 0, false
+		}
+		m.data.ExternalUsers.store(key, user)
+	}
+
+	if user.UserFields.PSAContactId == 0 {
+		return 0, false
+	}
+
+	return user.UserFields.PSAContactId, true
+}
+
+// closedDate returns the date in the PSA ticket's Zendesk closed date field
+func (m *Model) closedDate(ticket psa.Ticket) string {
+	for _, field := range ticket.CustomFields {
+		if field.Id != m.data.PsaInfo.ZendeskClosedDateField.Id {
+			continue
+		}
+
+		if v, ok := field.Value.(string); ok && len(v) >= len("2006-01-02") {
+			return v[:len("2006-01-02")]
+		}
+	}
+
+	return ""
+}
+
+// loadSkippedTickets returns the reason for each ticket whose latest record across every run report is skipped, by
+// Zendesk ID. Runs are read oldest to newest, so a ticket skipped once and migrated by a later run isn't included.
+func loadSkippedTickets(migrationDir string) (map[int64]string, error) {
+	runsDir := filepath.Join(migrationDir, runsDirName)
+	entries, err := os.ReadDir(runsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[int64]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading runs directory: %w", err)
+	}
+
+	// run folders are named by start time, so they sort oldest to newest
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	latest := make(map[int64]reportRecord)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		runDir := filepath.Join(runsDir, entry.Name())
+		records, err := loadRecords(runDir)
+		if err != nil {
+			slog.Warn("loadSkippedTickets: couldn't read run records", "runDir", runDir, "error", err)
+			continue
+		}
+
+		for _, rec := range records {
+			if rec.Entity == ticketEntity {
+				latest[rec.ZendeskId] = rec
+			}
+		}
+	}
+
+	skipped := make(map[int64]string)
+	for id, rec := range latest {
+		if rec.Action == actionSkipped {
+			skipped[id] = rec.Reason
+		}
+	}
+
+	slog.Debug("loadSkippedTickets: found skipped tickets", "count", len(skipped))
+	return skipped, nil
+}
+
+// write saves the report as CSV and JSON in its own folder under the migration directory
+func (r *verifyReport) write(migrationDir string) (string, error) {
+	dir := filepath.Join(migrationDir, verifyDirName, r.StartedAt.Format(runDirTimeLayout))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("creating verify directory: %w", err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, verifyCsvName))
+	if err != nil {
+		return "", fmt.Errorf("creating csv report: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(verifyCsvHeader); err != nil {
+		return "", fmt.Errorf("writing csv report header: %w", err)
+	}
+
+	for _, t := range r.Tickets {
+		if err := w.Write(t.csvRow()); err != nil {
+			return "", fmt.Errorf("writing csv row: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("writing csv report: %w", err)
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshaling json report: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, verifyJsonName), b, 0644); err != nil {
+		return "", fmt.Errorf("writing json report: %w", err)
+	}
+
+	return dir, nil
+}
@@ -1,6 +1,7 @@
 package psa
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -15,10 +16,14 @@ import (
 )
 
 const (
-	baseUrl = "https://api-na.myconnectwise.net/v4_6_release/apis/3.0"
+	defaultBaseUrl = "https://api-na.myconnectwise.net/v4_6_release/apis/3.0"
+
+	// MaxPageSize is the most items ConnectWise PSA returns in one page
+	MaxPageSize = 1000
 )
 
 type Client struct {
+	baseUrl      string
 	encodedCreds string
 	clientId     string
 	httpClient   *http.Client
@@ -29,6 +34,20 @@ type Creds struct {
 	PublicKey  string `mapstructure:"public_key" json:"public_key"`
 	PrivateKey string `mapstructure:"private_key" json:"private_key"`
 	ClientId   string `mapstructure:"client_id" json:"client_id"`
+
+	// BaseUrl overrides the API URL, for a ConnectWise region other than North America or a test server
+	BaseUrl string `mapstructure:"base_url" json:"base_url,omitempty"`
+}
+
+// LogValue keeps the API keys out of logs
+func (c Creds) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("company_id", c.CompanyId),
+		slog.Bool("public_key_set", c.PublicKey != ""),
+		slog.Bool("private_key_set", c.PrivateKey != ""),
+		slog.String("client_id", c.ClientId),
+		slog.String("base_url", c.BaseUrl),
+	)
 }
 
 type PaginationDetails struct {
@@ -48,10 +67,32 @@ func (b BadGatewayErr) Error() string {
 	return "bad gateway"
 }
 
+// ApiErr is returned for any other non-200 response
+type ApiErr struct {
+	StatusCode int
+	Status     string
+}
+
+func (e ApiErr) Error() string {
+	return fmt.Sprintf("received non-200 response: %s (status code: %d)", e.Status, e.StatusCode)
+}
+
+// quoteCondition quotes a string value for a conditions query, escaping any quotes or backslashes in it so they
+// don't end the value early
+func quoteCondition(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
 func NewClient(creds Creds, httpClient *http.Client) *Client {
 	username := fmt.Sprintf("%s+%s", creds.CompanyId, creds.PublicKey)
 
+	baseUrl := defaultBaseUrl
+	if creds.BaseUrl != "" {
+		baseUrl = strings.TrimSuffix(creds.BaseUrl, "/")
+	}
+
 	return &Client{
+		baseUrl:      baseUrl,
 		encodedCreds: basicAuth(username, creds.PrivateKey),
 		clientId:     creds.ClientId,
 		httpClient:   httpClient,
@@ -59,7 +100,7 @@ func NewClient(creds Creds, httpClient *http.Client) *Client {
 }
 
 func (c *Client) ConnectionTest(ctx context.Context) error {
-	url := fmt.Sprintf("%s/company/companies?pageSize=1", baseUrl)
+	url := fmt.Sprintf("%s/company/companies?pageSize=1", c.baseUrl)
 	co := CompaniesResp{}
 
 	if _, err := c.ApiRequest(ctx, "GET", url, nil, &co); err != nil {
@@ -83,17 +124,32 @@ func (c *Client) apiRequest(ctx context.Context, method, url string, body io.Rea
 	slog.Debug("psa.apiRequest: called", "method", method, "url", url)
 	const maxRetries = 3
 	var retryAfter int
+	var lastErr error
 	p := &PaginationDetails{
 		HasMorePages: false,
 		NextLink:     "",
 	}
 
+	// the body is read once, so every attempt sends all of it
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return *p, fmt.Errorf("an error occured reading the request body: %w", err)
+		}
+	}
+
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
 			slog.Debug("psa.apiRequest: making additional attempt", "method", method, "url", url, "attempt", attempt)
 		}
 
-		req, err := http.NewRequestWithContext(ctx, method, url, body)
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 		if err != nil {
 			slog.Debug("psa.apiRequest: error creating request", "method", method, "url", url, "error", err)
 			return *p, fmt.Errorf("an error occured creating the request: %w", err)
@@ -137,33 +193,21 @@ func (c *Client) apiRequest(ctx context.Context, method, url string, body io.Rea
 			}
 
 			if res.StatusCode == http.StatusTooManyRequests {
-				retryAfterHeader := res.Header.Get("Retry-After")
-
-				if retryAfterHeader != "" {
-					retryAfter, err = strconv.Atoi(retryAfterHeader)
-					if err != nil {
-						slog.Debug("psa.apiRequest: error parsing Retry-After header", "headerValue", retryAfterHeader, "error", err)
-						retryAfter = 1
-					}
-
-				} else {
-					slog.Debug("psa.apiRequest: no Retry-After header provided, defaulting to 1 second")
-					retryAfter = 1
-				}
-
+				retryAfter = parseRetryAfter(res, 1)
 				slog.Debug("psa.apiRequest: rate limit exceeded, retrying after", "retryAfter", retryAfter, "attempt", attempt)
 				return RateLimitErr{}
 			}
 
 			if res.StatusCode == http.StatusBadGateway || res.StatusCode == http.StatusServiceUnavailable || res.StatusCode == http.StatusInternalServerError || res.StatusCode == http.StatusGatewayTimeout {
-				retryAfter = 10
+				retryAfter = parseRetryAfter(res, 10)
+				slog.Debug("psa.apiRequest: server error, retrying after", "statusCode", res.StatusCode, "retryAfter", retryAfter, "attempt", attempt)
 				return BadGatewayErr{}
 			}
 
 			retryAfter = 10
 			errorText, _ := io.ReadAll(res.Body)
 			slog.Debug("psa.apiRequest: response status", "statusCode", res.StatusCode, "responseBody", string(errorText))
-			return fmt.Errorf("received non-200 response: %s (status code: %d)", res.Status, res.StatusCode)
+			return ApiErr{StatusCode: res.StatusCode, Status: res.Status}
 		}()
 
 		if err == nil {
@@ -175,12 +219,30 @@ func (c *Client) apiRequest(ctx context.Context, method, url string, body io.Rea
 			slog.Debug("psa.apiRequest: non-rate limit or gateway error encountered", "error", err)
 			return *p, err
 		}
+		lastErr = err
 
 		time.Sleep(time.Duration(retryAfter) * time.Second)
 	}
 
 	slog.Debug("psa.apiRequest: max retries reached", "method", method, "url", url, "maxRetries", maxRetries)
-	return PaginationDetails{}, fmt.Errorf("max retries exceeded for API request: %s %s", method, url)
+	return PaginationDetails{}, fmt.Errorf("max retries exceeded for API request: %s %s: %w", method, url, lastErr)
+}
+
+// parseRetryAfter returns the seconds to wait from the response's Retry-After header, or the fallback if it isn't set
+func parseRetryAfter(res *http.Response, fallback int) int {
+	retryAfterHeader := res.Header.Get("Retry-After")
+	if retryAfterHeader == "" {
+		slog.Debug("psa.apiRequest: no Retry-After header provided, using default", "default", fallback)
+		return fallback
+	}
+
+	retryAfter, err := strconv.Atoi(retryAfterHeader)
+	if err != nil {
+		slog.Debug("psa.apiRequest: error parsing Retry-After header", "headerValue", retryAfterHeader, "error", err)
+		return fallback
+	}
+
+	return retryAfter
 }
 
 func basicAuth(username, password string) string {
@@ -6,7 +6,7 @@ import (
 )
 
 func (c *Client) GetBoards(ctx context.Context) ([]Board, error) {
-	url := fmt.Sprintf("%s/service/boards", baseUrl)
+	url := fmt.Sprintf("%s/service/boards", c.baseUrl)
 	var b []Board
 
 	// TODO: Handle Pagination
@@ -18,7 +18,7 @@ func (c *Client) GetBoards(ctx context.Context) ([]Board, error) {
 }
 
 func (c *Client) GetBoardTypes(ctx context.Context, boardId int) ([]BoardType, error) {
-	url := fmt.Sprintf("%s/service/boards/%d/types?page=1&pageSize=100", baseUrl, boardId)
+	url := fmt.Sprintf("%s/service/boards/%d/types?page=1&pageSize=100", c.baseUrl, boardId)
 	var allTypes []BoardType
 	var currentPage []BoardType
 	var pagination PaginationDetails
@@ -47,7 +47,7 @@ func (c *Client) GetBoardTypes(ctx context.Context, boardId int) ([]BoardType, e
 }
 
 func (c *Client) GetBoardStatuses(ctx context.Context, boardId int) ([]Status, error) {
-	url := fmt.Sprintf("%s/service/boards/%d/statuses", baseUrl, boardId)
+	url := fmt.Sprintf("%s/service/boards/%d/statuses", c.baseUrl, boardId)
 	var b []Status
 
 	// TODO: Handle Pagination
@@ -9,8 +9,8 @@ import (
 type CompaniesResp []Company
 
 func (c *Client) GetCompanyByName(ctx context.Context, name string) (*Company, error) {
-	query := url.QueryEscape(fmt.Sprintf("name=\"%s\"", name))
-	u := fmt.Sprintf("%s/company/companies?conditions=%s", baseUrl, query)
+	query := url.QueryEscape("name=" + quoteCondition(name))
+	u := fmt.Sprintf("%s/company/companies?conditions=%s", c.baseUrl, query)
 	cos := CompaniesResp{}
 
 	if _, err := c.ApiRequest(ctx, "GET", u, nil, &cos); err != nil {
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 )
 
 type ContactsResp []Contact
@@ -16,8 +18,17 @@ func (e NoUserFoundErr) Error() string {
 	return "No user was found with the provided email"
 }
 
+// MultipleContactsErr is returned when more than one contact matches a search, so the caller can decide which to use.
+type MultipleContactsErr struct {
+	Contacts []Contact
+}
+
+func (e MultipleContactsErr) Error() string {
+	return fmt.Sprintf("expected 1 contact, got %d", len(e.Contacts))
+}
+
 func (c *Client) PostContact(ctx context.Context, payload *ContactPostBody) (*Contact, error) {
-	u := fmt.Sprintf("%s/company/contacts", baseUrl)
+	u := fmt.Sprintf("%s/company/contacts", c.baseUrl)
 
 	jsonBytes, err := json.Marshal(payload)
 	if err != nil {
@@ -34,9 +45,36 @@ func (c *Client) PostContact(ctx context.Context, payload *ContactPostBody) (*Co
 	return &respContact, nil
 }
 
-func (c *Client) GetContactByEmail(ctx context.Context, email string) (*Contact, error) {
-	query := url.QueryEscape(fmt.Sprintf("communicationItems/type/name=\"email\" AND communicationItems/value=\"%s\"", email))
-	u := fmt.Sprintf("%s/company/contacts?childConditions=%s", baseUrl, query)
+func (c *Client) PostContactNote(ctx context.Context, contactId int, note *ContactNote) error {
+	u := fmt.Sprintf("%s/company/contacts/%d/notes", c.baseUrl, contactId)
+
+	jsonBytes, err := json.Marshal(note)
+	if err != nil {
+		return fmt.Errorf("marshaling contact note to json: %w", err)
+	}
+
+	body := bytes.NewReader(jsonBytes)
+
+	if _, err := c.ApiRequest(ctx, "POST", u, body, nil); err != nil {
+		return fmt.Errorf("an error occured creating the contact note: %w", err)
+	}
+
+	return nil
+}
+
+// GetContactByEmail returns the contact with a communication item matching any of the provided emails.
+func (c *Client) GetContactByEmail(ctx context.Context, emails ...string) (*Contact, error) {
+	if len(emails) == 0 {
+		return nil, errors.New("no email addresses provided")
+	}
+
+	var valueConditions []string
+	for _, email := range emails {
+		valueConditions = append(valueConditions, "communicationItems/value="+quoteCondition(email))
+	}
+
+	query := url.QueryEscape(fmt.Sprintf("communicationItems/type/name=\"email\" AND (%s)", strings.Join(valueConditions, " OR ")))
+	u := fmt.Sprintf("%s/company/contacts?childConditions=%s", c.baseUrl, query)
 	contacts := ContactsResp{}
 
 	if _, err := c.ApiRequest(ctx, "GET", u, nil, &contacts); err != nil {
@@ -48,7 +86,7 @@ func (c *Client) GetContactByEmail(ctx context.Context, email string) (*Contact,
 	}
 
 	if len(contacts) != 1 {
-		return nil, fmt.Errorf("expected 1 contact, got %d", len(contacts))
+		return nil, MultipleContactsErr{Contacts: contacts}
 	}
 
 	return &contacts[0], nil
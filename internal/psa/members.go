@@ -6,7 +6,7 @@ import (
 )
 
 func (c *Client) GetMembers(ctx context.Context) ([]Member, error) {
-	url := fmt.Sprintf("%s/system/members?page=1&pageSize=100", baseUrl)
+	url := fmt.Sprintf("%s/system/members?page=1&pageSize=100", c.baseUrl)
 	var allMembers []Member
 	var currentPage []Member
 	var pagination PaginationDetails
@@ -1,5 +1,7 @@
 package psa
 
+import "time"
+
 type Company struct {
 	Id          int    `json:"id,omitempty"`
 	Name        string `json:"name,omitempty"`
@@ -9,12 +11,29 @@ type Company struct {
 type ContactPostBody struct {
 	FirstName          string              `json:"firstName,omitempty"`
 	LastName           string              `json:"lastName,omitempty"`
+	Title              string              `json:"title,omitempty"`
+	InactiveFlag       bool                `json:"inactiveFlag,omitempty"`
 	Company            Company             `json:"company,omitempty"`
 	CommunicationItems []CommunicationItem `json:"communicationItems,omitempty"`
 }
 
 type Contact struct {
-	Id int `json:"id,omitempty"`
+	Id           int      `json:"id,omitempty"`
+	FirstName    string   `json:"firstName,omitempty"`
+	LastName     string   `json:"lastName,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Company      *Company `json:"company,omitempty"`
+	InactiveFlag bool     `json:"inactiveFlag,omitempty"`
+	Info         *Info    `json:"_info,omitempty"`
+}
+
+type Info struct {
+	LastUpdated time.Time `json:"lastUpdated,omitempty"`
+}
+
+type ContactNote struct {
+	Id   int    `json:"id,omitempty"`
+	Text string `json:"text,omitempty"`
 }
 
 type Ticket struct {
@@ -38,6 +57,7 @@ type CommunicationItem struct {
 	Type              CommunicationItemType `json:"type,omitempty"`
 	Value             string                `json:"value,omitempty"`
 	CommunicationType string                `json:"communicationType,omitempty"`
+	DefaultFlag       bool                  `json:"defaultFlag,omitempty"`
 }
 
 type CommunicationItemType struct {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/url"
+	"strconv"
 )
 
 type PatchPayload []PatchOperation
@@ -17,13 +18,14 @@ type PatchOperation struct {
 	Value any    `json:"value"`
 }
 
-func (c *Client) GetTickets(ctx context.Context, childConditionQuery *string) ([]Ticket, error) {
-	q := ""
-	if childConditionQuery != nil {
-		q = "&customFieldConditions=" + url.QueryEscape(*childConditionQuery)
-	}
+// GetMigratedTickets returns every ticket with a Zendesk ticket ID in the custom field, across every company
+func (c *Client) GetMigratedTickets(ctx context.Context, zendeskIdFieldId int, pageSize int) ([]Ticket, error) {
+	q := url.Values{}
+	q.Set("page", "1")
+	q.Set("pageSize", strconv.Itoa(pageSize))
+	q.Set("customFieldConditions", migratedFieldCondition(zendeskIdFieldId))
 
-	u := fmt.Sprintf("%s/service/tickets?page=1&pageSize=1000%s", baseUrl, q)
+	u := fmt.Sprintf("%s/service/tickets?%s", c.baseUrl, q.Encode())
 	var allTickets []Ticket
 	var currentPage []Ticket
 	var pagination PaginationDetails
@@ -52,7 +54,7 @@ func (c *Client) GetTickets(ctx context.Context, childConditionQuery *string) ([
 }
 
 func (c *Client) GetTicket(ctx context.Context, ticketId int) (*Ticket, error) {
-	u := fmt.Sprintf("%s/service/tickets/%d", baseUrl, ticketId)
+	u := fmt.Sprintf("%s/service/tickets/%d", c.baseUrl, ticketId)
 	t := &Ticket{}
 
 	if _, err := c.ApiRequest(ctx, "GET", u, nil, &t); err != nil {
@@ -63,10 +65,9 @@ func (c *Client) GetTicket(ctx context.Context, ticketId int) (*Ticket, error) {
 }
 
 func (c *Client) PostTicket(ctx context.Context, ticket *Ticket) (*Ticket, error) {
-	u := fmt.Sprintf("%s/service/tickets", baseUrl)
+	u := fmt.Sprintf("%s/service/tickets", c.baseUrl)
 
 	ticketBytes, err := json.Marshal(ticket)
-	slog.Debug("zendesk.PostTicket: body", "body", string(ticketBytes))
 	if err != nil {
 		return nil, fmt.Errorf("marshaling the ticket to json: %w", err)
 	}
@@ -78,11 +79,13 @@ func (c *Client) PostTicket(ctx context.Context, ticket *Ticket) (*Ticket, error
 		return nil, fmt.Errorf("posting the ticket: %w", err)
 	}
 
+	slog.Debug("psa.PostTicket: ticket created", "ticketId", t.Id, "bodySize", len(ticketBytes))
+
 	return t, nil
 }
 
 func (c *Client) UpdateTicketStatus(ctx context.Context, ticket *Ticket, newStatusId int) error {
-	u := fmt.Sprintf("%s/service/tickets/%d", baseUrl, ticket.Id)
+	u := fmt.Sprintf("%s/service/tickets/%d", c.baseUrl, ticket.Id)
 
 	payload := PatchPayload{
 		{
@@ -106,8 +109,46 @@ func (c *Client) UpdateTicketStatus(ctx context.Context, ticket *Ticket, newStat
 	return nil
 }
 
+// CountMigratedTickets returns the number of tickets in a company with a Zendesk ticket ID in the custom field
+func (c *Client) CountMigratedTickets(ctx context.Context, zendeskIdFieldId int, companyId int) (int, error) {
+	q := url.Values{}
+	q.Set("conditions", fmt.Sprintf("company/id=%d", companyId))
+	q.Set("customFieldConditions", migratedFieldCondition(zendeskIdFieldId))
+
+	u := fmt.Sprintf("%s/service/tickets/count?%s", c.baseUrl, q.Encode())
+	r := &struct {
+		Count int `json:"count"`
+	}{}
+
+	if _, err := c.ApiRequest(ctx, "GET", u, nil, r); err != nil {
+		return 0, fmt.Errorf("getting the ticket count: %w", err)
+	}
+
+	return r.Count, nil
+}
+
+// migratedFieldCondition matches tickets with any value in the Zendesk ticket ID field, which only migrated
+// tickets have
+func migratedFieldCondition(zendeskIdFieldId int) string {
+	return fmt.Sprintf("id=%d AND value != null", zendeskIdFieldId)
+}
+
+// GetTicketNoteCount returns the number of notes on a ticket
+func (c *Client) GetTicketNoteCount(ctx context.Context, ticketId int) (int, error) {
+	u := fmt.Sprintf("%s/service/tickets/%d/notes/count", c.baseUrl, ticketId)
+	r := &struct {
+		Count int `json:"count"`
+	}{}
+
+	if _, err := c.ApiRequest(ctx, "GET", u, nil, r); err != nil {
+		return 0, fmt.Errorf("getting the ticket note count: %w", err)
+	}
+
+	return r.Count, nil
+}
+
 func (c *Client) PostTicketNote(ctx context.Context, ticketId int, note *TicketNote) error {
-	u := fmt.Sprintf("%s/service/tickets/%d/notes", baseUrl, ticketId)
+	u := fmt.Sprintf("%s/service/tickets/%d/notes", c.baseUrl, ticketId)
 
 	noteBytes, err := json.Marshal(note)
 	if err != nil {
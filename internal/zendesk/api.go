@@ -1,6 +1,7 @@
 package zendesk
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,11 +10,15 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
 	zendeskApiUrl = "zendesk.com/api/v2"
+
+	// MaxExportPageSize is the most results the search export endpoint returns in one page
+	MaxExportPageSize = 1000
 )
 
 type Client struct {
@@ -26,6 +31,19 @@ type Creds struct {
 	Token     string `mapstructure:"token" json:"token"`
 	Username  string `mapstructure:"username" json:"username"`
 	Subdomain string `mapstructure:"subdomain" json:"subdomain"`
+
+	// BaseUrl overrides the API URL built from the subdomain, e.g. to use a test server
+	BaseUrl string `mapstructure:"base_url" json:"base_url,omitempty"`
+}
+
+// LogValue keeps the API token out of logs
+func (c Creds) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("subdomain", c.Subdomain),
+		slog.String("username", c.Username),
+		slog.Bool("token_set", c.Token != ""),
+		slog.String("base_url", c.BaseUrl),
+	)
 }
 
 type Meta struct {
@@ -41,11 +59,27 @@ func (r RateLimitErr) Error() string {
 	return "rate limit exceeded"
 }
 
+// ApiErr is returned for any non-200 response other than a rate limit
+type ApiErr struct {
+	StatusCode int
+	Status     string
+}
+
+func (e ApiErr) Error() string {
+	return fmt.Sprintf("received non-200 response: %s (status code: %d)", e.Status, e.StatusCode)
+}
+
 func NewClient(creds Creds, httpClient *http.Client) *Client {
 	creds.Username = fmt.Sprintf("%s/token", creds.Username)
+
+	baseUrl := fmt.Sprintf("https://%s.%s", creds.Subdomain, zendeskApiUrl)
+	if creds.BaseUrl != "" {
+		baseUrl = strings.TrimSuffix(creds.BaseUrl, "/")
+	}
+
 	return &Client{
 		creds:      creds,
-		baseUrl:    fmt.Sprintf("https://%s.%s", creds.Subdomain, zendeskApiUrl),
+		baseUrl:    baseUrl,
 		httpClient: httpClient,
 	}
 }
@@ -76,12 +110,26 @@ func (c *Client) apiRequest(ctx context.Context, method, url string, body io.Rea
 	const maxRetries = 3
 	var retryAfter int
 
+	// the body is read once, so every attempt sends all of it
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return fmt.Errorf("an error occured reading the request body: %w", err)
+		}
+	}
+
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
 			slog.Debug("zendesk.apiRequest: making additional attempt", "method", method, "url", url, "attempt", attempt)
 		}
 
-		req, err := http.NewRequestWithContext(ctx, method, url, body)
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 		if err != nil {
 			slog.Debug("zendesk.apiRequest: error creating request", "method", method, "url", url, "error", err)
 			return fmt.Errorf("an error occured creating the request: %w", err)
@@ -136,7 +184,7 @@ func (c *Client) apiRequest(ctx context.Context, method, url string, body io.Rea
 			}
 			retryAfter = 5
 			slog.Debug("zendesk.apiRequest: received non-200 response", "method", method, "url", url, "statusCode", res.StatusCode)
-			return fmt.Errorf("received non-200 response: %s (status code: %d)", res.Status, res.StatusCode)
+			return ApiErr{StatusCode: res.StatusCode, Status: res.Status}
 		}()
 
 		if closeErr == nil {
@@ -152,5 +200,5 @@ func (c *Client) apiRequest(ctx context.Context, method, url string, body io.Rea
 	}
 
 	slog.Debug("zendesk.apiRequest: max retries exceeded", "method", method, "url", url)
-	return fmt.Errorf("max retries exceeded")
+	return fmt.Errorf("max retries exceeded: %w", RateLimitErr{})
 }
@@ -56,6 +56,22 @@ func (c *Client) GetOrganization(ctx context.Context, orgId int64) (Organization
 	return r.Organization, nil
 }
 
+// GetOrganizationUserCount returns the number of users in an organization
+func (c *Client) GetOrganizationUserCount(ctx context.Context, orgId int64) (int, error) {
+	u := fmt.Sprintf("%s/organizations/%d/related.json", c.baseUrl, orgId)
+	var r struct {
+		OrganizationRelated struct {
+			UsersCount int `json:"users_count"`
+		} `json:"organization_related"`
+	}
+
+	if err := c.ApiRequest(ctx, "GET", u, nil, &r); err != nil {
+		return 0, fmt.Errorf("getting the organization user count: %w", err)
+	}
+
+	return r.OrganizationRelated.UsersCount, nil
+}
+
 func (c *Client) UpdateOrganization(ctx context.Context, org *Organization) (*Organization, error) {
 	u := fmt.Sprintf("%s/organizations/%d", c.baseUrl, org.Id)
 
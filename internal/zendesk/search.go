@@ -52,6 +52,24 @@ func (c *Client) searchRequest(ctx context.Context, searchType SearchType, query
 	return nil
 }
 
+func (c *Client) searchCountRequest(ctx context.Context, searchType SearchType, query SearchQuery) (int, error) {
+	queryString, err := buildSearchQueryString(searchType, query)
+	if err != nil {
+		return 0, fmt.Errorf("building query string: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/search/count.json?query=%s", c.baseUrl, queryString)
+	var r struct {
+		Count int `json:"count"`
+	}
+
+	if err := c.ApiRequest(ctx, "GET", u, nil, &r); err != nil {
+		return 0, fmt.Errorf("an error occured counting the resource: %w", err)
+	}
+
+	return r.Count, nil
+}
+
 func buildExportSearchQueryString(searchType SearchType, query SearchQuery) (string, error) {
 	if searchType == "" {
 		return "", errors.New("search type cannot be empty")
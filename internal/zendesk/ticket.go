@@ -3,6 +3,8 @@ package zendesk
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"time"
 )
 
@@ -13,12 +15,14 @@ type TicketSearchResp struct {
 }
 
 type Ticket struct {
-	Id          int       `json:"id"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Subject     string    `json:"subject"`
-	Status      string    `json:"status"`
-	RequesterId int64     `json:"requester_id"`
-	AssigneeId  int64     `json:"assignee_id"`
+	Id             int       `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Subject        string    `json:"subject"`
+	Status         string    `json:"status"`
+	RequesterId    int64     `json:"requester_id"`
+	AssigneeId     int64     `json:"assignee_id"`
+	OrganizationId int64     `json:"organization_id"`
 }
 
 type TicketCommentsResp struct {
@@ -28,12 +32,13 @@ type TicketCommentsResp struct {
 }
 
 type Comment struct {
-	Id        int64     `json:"id"`
-	AuthorId  int64     `json:"author_id"`
-	Body      string    `json:"body"`
-	Public    bool      `json:"public"`
-	CreatedAt time.Time `json:"created_at"`
-	Via       struct {
+	Id          int64        `json:"id"`
+	AuthorId    int64        `json:"author_id"`
+	Body        string       `json:"body"`
+	Public      bool         `json:"public"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Attachments []Attachment `json:"attachments"`
+	Via         struct {
 		Source struct {
 			To struct {
 				EmailCcs []any `json:"email_ccs"`
@@ -42,6 +47,14 @@ type Comment struct {
 	} `json:"via"`
 }
 
+type Attachment struct {
+	Id          int64  `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentUrl  string `json:"content_url"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
 func (c *Client) GetTicketsWithQuery(ctx context.Context, q SearchQuery, pageSize int, limit int) ([]Ticket, error) {
 	var allTickets []Ticket
 	currentPage := &TicketSearchResp{}
@@ -68,6 +81,29 @@ func (c *Client) GetTicketsWithQuery(ctx context.Context, q SearchQuery, pageSiz
 	return allTickets, nil
 }
 
+// CountTicketsWithQuery returns the number of tickets matching the query, without getting them
+func (c *Client) CountTicketsWithQuery(ctx context.Context, q SearchQuery) (int, error) {
+	count, err := c.searchCountRequest(ctx, TicketSearchType, q)
+	if err != nil {
+		return 0, fmt.Errorf("an error occured counting the tickets: %w", err)
+	}
+
+	return count, nil
+}
+
+func (c *Client) GetTicket(ctx context.Context, ticketId int64) (Ticket, error) {
+	url := fmt.Sprintf("%s/tickets/%d", c.baseUrl, ticketId)
+	var r struct {
+		Ticket Ticket `json:"ticket"`
+	}
+
+	if err := c.ApiRequest(ctx, "GET", url, nil, &r); err != nil {
+		return Ticket{}, fmt.Errorf("an error occured getting the ticket: %w", err)
+	}
+
+	return r.Ticket, nil
+}
+
 func (c *Client) GetAllTicketComments(ctx context.Context, ticketId int64) ([]Comment, error) {
 	initialUrl := fmt.Sprintf("%s/tickets/%d/comments.json?page[size]=100", c.baseUrl, ticketId)
 	var allComments []Comment
@@ -93,3 +129,34 @@ func (c *Client) GetAllTicketComments(ctx context.Context, ticketId int64) ([]Co
 
 	return allComments, nil
 }
+
+// DownloadAttachment writes the content of a comment attachment to w. The content URL redirects to the file itself,
+// and the credentials are dropped when following it to another host.
+func (c *Client) DownloadAttachment(ctx context.Context, contentUrl string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", contentUrl, nil)
+	if err != nil {
+		return fmt.Errorf("an error occured creating the attachment request: %w", err)
+	}
+
+	req.SetBasicAuth(c.creds.Username, c.creds.Token)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("an error occured downloading the attachment: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		return RateLimitErr{}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return ApiErr{StatusCode: res.StatusCode, Status: res.Status}
+	}
+
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("an error occured writing the attachment: %w", err)
+	}
+
+	return nil
+}
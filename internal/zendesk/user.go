@@ -25,17 +25,59 @@ type User struct {
 	Id         int    `json:"id"`
 	Name       string `json:"name"`
 	Email      string `json:"email"`
+	Role       string `json:"role,omitempty"`
+	Active     bool   `json:"active,omitempty"`
+	Suspended  bool   `json:"suspended,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+	Notes      string `json:"notes,omitempty"`
+	Details    string `json:"details,omitempty"`
+	TimeZone   string `json:"time_zone,omitempty"`
 	UserFields struct {
 		PSAContactId int `json:"psa_contact"`
+		// Title is not a native Zendesk user property - it is read from a "title" custom user field if one exists
+		Title string `json:"title,omitempty"`
 	} `json:"user_fields"`
 }
 
+type DeletedUserResp struct {
+	DeletedUser User `json:"deleted_user"`
+}
+
+// Inactive reports whether the user is suspended or deleted in Zendesk.
+func (u *User) Inactive() bool {
+	return !u.Active || u.Suspended
+}
+
+type IdentitiesResp struct {
+	Identities []Identity `json:"identities"`
+	Meta       Meta       `json:"meta"`
+	Links      Links      `json:"links"`
+}
+
+type Identity struct {
+	Id       int64  `json:"id"`
+	UserId   int64  `json:"user_id"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Verified bool   `json:"verified"`
+	Primary  bool   `json:"primary"`
+}
+
+const (
+	EmailIdentityType = "email"
+	PhoneIdentityType = "phone_number"
+)
+
 func (c *Client) UpdateUser(ctx context.Context, user *User) (*User, error) {
 	url := fmt.Sprintf("%s/users/%d", c.baseUrl, user.Id)
 
-	b := &UserBody{
-		User: user,
-	}
+	// only send the user fields, so read-only or stale profile values are never written back
+	b := &struct {
+		User struct {
+			UserFields any `json:"user_fields"`
+		} `json:"user"`
+	}{}
+	b.User.UserFields = user.UserFields
 
 	jsonBytes, err := json.Marshal(b)
 	if err != nil {
@@ -63,6 +105,19 @@ func (c *Client) GetUser(ctx context.Context, userId int64) (*User, error) {
 	return &u.User, nil
 }
 
+// GetDeletedUser gets a user that has been soft-deleted in Zendesk, and is no longer returned by GetUser.
+func (c *Client) GetDeletedUser(ctx context.Context, userId int64) (*User, error) {
+	url := fmt.Sprintf("%s/deleted_users/%d", c.baseUrl, userId)
+	u := &DeletedUserResp{}
+
+	if err := c.ApiRequest(ctx, "GET", url, nil, &u); err != nil {
+		return nil, fmt.Errorf("an error occured getting the deleted user: %w", err)
+	}
+
+	u.DeletedUser.Active = false
+	return &u.DeletedUser, nil
+}
+
 func (c *Client) GetOrganizationUsers(ctx context.Context, orgId int64) ([]User, error) {
 	initialUrl := fmt.Sprintf("%s/organizations/%d/users?page[size]=100", c.baseUrl, orgId)
 	var allUsers []User
@@ -110,3 +165,27 @@ func (c *Client) GetAgents(ctx context.Context) ([]User, error) {
 
 	return allAgents, nil
 }
+
+func (c *Client) GetUserIdentities(ctx context.Context, userId int64) ([]Identity, error) {
+	initialUrl := fmt.Sprintf("%s/users/%d/identities?page[size]=100", c.baseUrl, userId)
+	var allIdentities []Identity
+	currentPage := &IdentitiesResp{}
+
+	if err := c.ApiRequest(ctx, "GET", initialUrl, nil, &currentPage); err != nil {
+		return nil, fmt.Errorf("an error occured getting user identities: %w", err)
+	}
+
+	allIdentities = append(allIdentities, currentPage.Identities...)
+
+	for currentPage.Meta.HasMore {
+		nextPage := &IdentitiesResp{}
+		if err := c.ApiRequest(ctx, "GET", currentPage.Links.Next, nil, &nextPage); err != nil {
+			return nil, fmt.Errorf("an error occured getting user identities: %w", err)
+		}
+
+		allIdentities = append(allIdentities, nextPage.Identities...)
+		currentPage = nextPage
+	}
+
+	return allIdentities, nil
+}